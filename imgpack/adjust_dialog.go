@@ -0,0 +1,147 @@
+package imgpack
+
+import (
+	"fmt"
+	"image"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/disintegration/imaging"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// adjustPreviewSize bounds the size of the image used for the live preview,
+// so the preview stays responsive for large scans.
+const adjustPreviewSize = 1200
+
+// newAdjustSlider creates a slider with a label showing its value.
+func newAdjustSlider(name string, minV, maxV, step, value float64,
+	onChanged func(float64)) (*widget.Label, *widget.Slider) {
+
+	label := widget.NewLabel(fmt.Sprintf("%s: %.1f", name, value))
+
+	slider := widget.NewSlider(minV, maxV)
+	slider.Step = step
+	slider.Value = value
+	slider.OnChanged = func(v float64) {
+		label.SetText(fmt.Sprintf("%s: %.1f", name, v))
+		onChanged(v)
+	}
+
+	return label, slider
+}
+
+// showAdjustWindow shows the tonal adjustment window of the selected image,
+// the adjustment is previewed in imgShow until it is applied or cancelled.
+func (iApp *ImgpackApp) showAdjustWindow() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	img, err := iApp.opTable.GetSelectedImg().Img()
+	if err != nil {
+		dialog.ShowError(err, iApp.mainWindow)
+		return
+	}
+
+	preview := image.Image(imaging.Fit(img, adjustPreviewSize, adjustPreviewSize, imaging.Box))
+
+	adj := imgutil.Adjustment{Gamma: 1}
+
+	updatePreview := func() {
+		iApp.imgShow.Image = adj.Apply(preview)
+		iApp.imgShow.Refresh()
+	}
+
+	grayscaleCheck := widget.NewCheck("", func(b bool) {
+		adj.Grayscale = b
+		updatePreview()
+	})
+
+	autoLevelsCheck := widget.NewCheck("", func(b bool) {
+		adj.AutoLevels = b
+		updatePreview()
+	})
+
+	brightnessLabel, brightnessSlider := newAdjustSlider("Brightness", -100, 100, 1, 0,
+		func(v float64) {
+			adj.Brightness = v
+			updatePreview()
+		})
+
+	contrastLabel, contrastSlider := newAdjustSlider("Contrast", -100, 100, 1, 0,
+		func(v float64) {
+			adj.Contrast = v
+			updatePreview()
+		})
+
+	gammaLabel, gammaSlider := newAdjustSlider("Gamma", 0.1, 3, 0.1, 1,
+		func(v float64) {
+			adj.Gamma = v
+			updatePreview()
+		})
+
+	sharpenLabel, sharpenSlider := newAdjustSlider("Sharpen", 0, 5, 0.1, 0,
+		func(v float64) {
+			adj.Sharpen = v
+			updatePreview()
+		})
+
+	blurLabel, blurSlider := newAdjustSlider("Blur", 0, 5, 0.1, 0,
+		func(v float64) {
+			adj.Blur = v
+			updatePreview()
+		})
+
+	content := container.New(layout.NewFormLayout(),
+		widget.NewLabel("Grayscale"),
+		grayscaleCheck,
+		widget.NewLabel("Auto Levels"),
+		autoLevelsCheck,
+		brightnessLabel,
+		brightnessSlider,
+		contrastLabel,
+		contrastSlider,
+		gammaLabel,
+		gammaSlider,
+		sharpenLabel,
+		sharpenSlider,
+		blurLabel,
+		blurSlider,
+	)
+
+	// the adjustment window is a separate window so that it does not
+	// cover the preview in the main window
+	win := iApp.fApp.NewWindow("Adjust")
+
+	applied := false
+
+	applyBtn := widget.NewButton("Apply", func() {
+		applied = true
+		iApp.opTable.Adjust(adj)
+		win.Close()
+	})
+	applyBtn.Importance = widget.HighImportance
+
+	cancelBtn := widget.NewButton("Cancel", func() {
+		win.Close()
+	})
+
+	win.SetOnClosed(func() {
+		if applied && !adj.IsZero() {
+			return
+		}
+
+		iApp.imgShow.Image = img
+		iApp.imgShow.Refresh()
+	})
+	win.SetContent(container.NewBorder(nil,
+		container.NewHBox(layout.NewSpacer(), cancelBtn, applyBtn),
+		nil, nil, content))
+	win.Resize(fyne.NewSize(400, 400))
+	win.Show()
+}
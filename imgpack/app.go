@@ -1,17 +1,23 @@
 package imgpack
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
-	"path"
+	"slices"
+	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
@@ -20,6 +26,7 @@ import (
 	"github.com/VoileLab/goimgpack/imgpack/assets"
 	"github.com/VoileLab/goimgpack/imgpack/imgstable"
 	"github.com/VoileLab/goimgpack/internal/imgutil"
+	"github.com/VoileLab/goimgpack/internal/util"
 )
 
 const appDescription = "A tool to pack images into an archive file."
@@ -35,15 +42,36 @@ type ImgpackApp struct {
 
 	stateBar      *widget.Label
 	imgListWidget *widget.List
-	imgShow       *canvas.Image
+	imgGridWidget *widget.GridWrap
+
+	// listDragGroup and gridDragGroup are the draggable items
+	// of the list and the grid
+	listDragGroup *dragGroup
+	gridDragGroup *dragGroup
+
+	imgShow *canvas.Image
+
+	// imgListPane and imgGridPane are the list and the thumbnail grid
+	// views of the images, only one of them is visible
+	imgListPane      fyne.CanvasObject
+	imgGridPane      fyne.CanvasObject
+	viewToggleAction *widget.ToolbarAction
+
+	// infoPane is the panel next to the preview describing the selected
+	// image, infoGrid holds its rows
+	infoPane fyne.CanvasObject
+	infoGrid *fyne.Container
+
+	thumbnailer *thumbnailer
 
 	opTable *imgstable.ImgsTable
 
 	enableOnSelectImageEnables []Enablable
 
 	// reading progress dialog
-	readingImagesDlg dialog.Dialog
-	savingDlg        dialog.Dialog
+	readingImagesDlg *progressDialog
+	savingDlg        *progressDialog
+	scanningDlg      *progressDialog
 }
 
 func NewImgpackApp() *ImgpackApp {
@@ -68,6 +96,8 @@ func NewImgpackApp() *ImgpackApp {
 			retApp.imgShow.Refresh()
 			retApp.imgListWidget.UnselectAll()
 			retApp.imgListWidget.Refresh()
+			retApp.imgGridWidget.UnselectAll()
+			retApp.updateInfoPanel()
 
 			for _, action := range retApp.enableOnSelectImageEnables {
 				action.Disable()
@@ -76,32 +106,33 @@ func NewImgpackApp() *ImgpackApp {
 			return
 		}
 
-		img := retApp.opTable.GetSelectedImg()
-
 		for _, action := range retApp.enableOnSelectImageEnables {
 			action.Enable()
 		}
 
-		bound := img.Img.Bounds()
-		imgDesc := fmt.Sprintf("filename: %s, format: %s, size: %dx%d",
-			img.Filename, img.Type, bound.Dx(), bound.Dy())
-
-		retApp.stateBar.SetText(imgDesc)
-
-		retApp.imgShow.Resource = nil
-		retApp.imgShow.Image = img.Img
-		retApp.imgShow.Refresh()
+		retApp.showSelectedImg()
 
 		retApp.imgListWidget.Select(retApp.opTable.GetSelectedIdx())
+		retApp.imgGridWidget.Select(retApp.opTable.GetSelectedIdx())
+
+		// the highlights of the images selected along with it are updated
+		retApp.imgListWidget.Refresh()
+		retApp.imgGridWidget.Refresh()
 	})
 
 	retApp.opTable.SetOnSelectImageChange(func() {
-		retApp.imgShow.Image = retApp.opTable.GetSelectedImg().Img
-		retApp.imgShow.Refresh()
+		retApp.thumbnailer.Invalidate(retApp.opTable.GetSelectedImg())
+		retApp.imgGridWidget.RefreshItem(retApp.opTable.GetSelectedIdx())
+		retApp.showSelectedImg()
 	})
 
 	retApp.opTable.SetOnListChange(func() {
+		retApp.thumbnailer.Retain(retApp.opTable.GetImgs())
+		imgutil.RetainCache(retApp.opTable.GetImgs())
 		retApp.imgListWidget.Refresh()
+		retApp.imgGridWidget.Refresh()
+		retApp.listDragGroup.Prune()
+		retApp.gridDragGroup.Prune()
 	})
 
 	mainWindow.SetOnDropped(func(p fyne.Position, u []fyne.URI) {
@@ -112,6 +143,9 @@ func NewImgpackApp() *ImgpackApp {
 
 	retApp.enableOnSelectImageEnables = []Enablable{}
 
+	imgutil.SetCacheBudget(int64(getPreferenceCacheBudget()) << 20)
+	imgutil.UseDiskStore(getPreferenceDiskStore())
+
 	retApp.setupDialogs()
 	retApp.setupMenu()
 	retApp.setupToolbar()
@@ -125,13 +159,9 @@ func NewImgpackApp() *ImgpackApp {
 }
 
 func (iApp *ImgpackApp) setupDialogs() {
-	readingImagesDlg := dialog.NewCustomWithoutButtons(
-		"Reading images...", widget.NewProgressBarInfinite(), iApp.mainWindow)
-	iApp.readingImagesDlg = readingImagesDlg
-
-	savingDlg := dialog.NewCustomWithoutButtons(
-		"Saving...", widget.NewProgressBarInfinite(), iApp.mainWindow)
-	iApp.savingDlg = savingDlg
+	iApp.readingImagesDlg = newProgressDialog("Reading images...", iApp.mainWindow)
+	iApp.savingDlg = newProgressDialog("Saving...", iApp.mainWindow)
+	iApp.scanningDlg = newProgressDialog("Scanning images...", iApp.mainWindow)
 }
 
 func (iApp *ImgpackApp) setupMenu() {
@@ -165,6 +195,12 @@ func (iApp *ImgpackApp) setupMenu() {
 		Icon:   theme.MoveDownIcon(),
 	}
 
+	moveToImgsMenuItem := &fyne.MenuItem{
+		Label:  "Move To Position...",
+		Action: iApp.moveToAction,
+		Icon:   theme.MailForwardIcon(),
+	}
+
 	downloadImgsMenuItem := &fyne.MenuItem{
 		Label:  "Download",
 		Action: iApp.downloadAction,
@@ -183,6 +219,48 @@ func (iApp *ImgpackApp) setupMenu() {
 		Icon:   theme.ContentCutIcon(),
 	}
 
+	cropImgMenuItem := &fyne.MenuItem{
+		Label:  "Crop...",
+		Action: iApp.cropAction,
+		Icon:   theme.ViewFullScreenIcon(),
+	}
+
+	adjustImgMenuItem := &fyne.MenuItem{
+		Label:  "Adjust",
+		Action: iApp.adjustAction,
+		Icon:   theme.ColorPaletteIcon(),
+	}
+
+	historyImgMenuItem := &fyne.MenuItem{
+		Label:  "Edit History...",
+		Action: iApp.historyAction,
+		Icon:   theme.HistoryIcon(),
+	}
+
+	resetImgMenuItem := &fyne.MenuItem{
+		Label:  "Reset To Original",
+		Action: iApp.resetAction,
+		Icon:   theme.ContentUndoIcon(),
+	}
+
+	revealSourceMenuItem := &fyne.MenuItem{
+		Label:  "Reveal Source",
+		Action: iApp.revealSourceAction,
+		Icon:   theme.FolderOpenIcon(),
+	}
+
+	reimportImgMenuItem := &fyne.MenuItem{
+		Label:  "Re-import From Source",
+		Action: iApp.reimportAction,
+		Icon:   theme.ViewRefreshIcon(),
+	}
+
+	splitImgMenuItem := &fyne.MenuItem{
+		Label:  "Toggle Volume Split",
+		Action: iApp.toggleSplitAction,
+		Icon:   theme.ViewRestoreIcon(),
+	}
+
 	iApp.enableOnSelectImageEnables = append(
 		iApp.enableOnSelectImageEnables,
 		&EnablableWrapMenuItem{addImgsMenuItem},
@@ -190,9 +268,17 @@ func (iApp *ImgpackApp) setupMenu() {
 		&EnablableWrapMenuItem{dupImgsMenuItem},
 		&EnablableWrapMenuItem{moveUpImgsMenuItem},
 		&EnablableWrapMenuItem{moveDownImgsMenuItem},
+		&EnablableWrapMenuItem{moveToImgsMenuItem},
 		&EnablableWrapMenuItem{downloadImgsMenuItem},
 		&EnablableWrapMenuItem{rotateImgsMenuItem},
 		&EnablableWrapMenuItem{cutImgMenuItem},
+		&EnablableWrapMenuItem{cropImgMenuItem},
+		&EnablableWrapMenuItem{adjustImgMenuItem},
+		&EnablableWrapMenuItem{historyImgMenuItem},
+		&EnablableWrapMenuItem{resetImgMenuItem},
+		&EnablableWrapMenuItem{revealSourceMenuItem},
+		&EnablableWrapMenuItem{reimportImgMenuItem},
+		&EnablableWrapMenuItem{splitImgMenuItem},
 	)
 
 	menu := fyne.NewMainMenu(
@@ -202,6 +288,17 @@ func (iApp *ImgpackApp) setupMenu() {
 				Icon:   theme.DocumentCreateIcon(),
 				Action: iApp.clearAction,
 			},
+			&fyne.MenuItem{
+				Label:  "Open Project",
+				Icon:   theme.FolderOpenIcon(),
+				Action: iApp.openProjectAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Save Project",
+				Icon:   theme.DocumentSaveIcon(),
+				Action: iApp.saveProjectAction,
+			},
+			fyne.NewMenuItemSeparator(),
 			&fyne.MenuItem{
 				Label:  "Save As Archive",
 				Icon:   theme.DocumentSaveIcon(),
@@ -212,6 +309,31 @@ func (iApp *ImgpackApp) setupMenu() {
 				Icon:   theme.DocumentSaveIcon(),
 				Action: iApp.savePDFAction,
 			},
+			&fyne.MenuItem{
+				Label:  "Save As Animated GIF",
+				Icon:   theme.DocumentSaveIcon(),
+				Action: iApp.saveGIFAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Save As Multi-page TIFF",
+				Icon:   theme.DocumentSaveIcon(),
+				Action: iApp.saveTIFFAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Save To Target Size",
+				Icon:   theme.DocumentSaveIcon(),
+				Action: iApp.saveTargetSizeAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Save As Volumes",
+				Icon:   theme.DocumentSaveIcon(),
+				Action: iApp.saveVolumesAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Export To Folder",
+				Icon:   theme.FolderIcon(),
+				Action: iApp.saveFolderAction,
+			},
 			fyne.NewMenuItemSeparator(),
 			&fyne.MenuItem{
 				Label:  "Quit",
@@ -225,10 +347,35 @@ func (iApp *ImgpackApp) setupMenu() {
 			dupImgsMenuItem,
 			moveUpImgsMenuItem,
 			moveDownImgsMenuItem,
+			moveToImgsMenuItem,
 			downloadImgsMenuItem,
+			splitImgMenuItem,
 			fyne.NewMenuItemSeparator(),
 			rotateImgsMenuItem,
 			cutImgMenuItem,
+			cropImgMenuItem,
+			adjustImgMenuItem,
+			historyImgMenuItem,
+			resetImgMenuItem,
+			fyne.NewMenuItemSeparator(),
+			revealSourceMenuItem,
+			reimportImgMenuItem,
+			fyne.NewMenuItemSeparator(),
+			&fyne.MenuItem{
+				Label:  "Batch Rename...",
+				Icon:   theme.DocumentCreateIcon(),
+				Action: iApp.batchRenameAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Find Duplicates...",
+				Icon:   theme.SearchIcon(),
+				Action: iApp.findDuplicatesAction,
+			},
+			&fyne.MenuItem{
+				Label:  "Find Blank Pages...",
+				Icon:   theme.VisibilityOffIcon(),
+				Action: iApp.findBlankPagesAction,
+			},
 		),
 		fyne.NewMenu("Help",
 			&fyne.MenuItem{
@@ -257,6 +404,9 @@ func (iApp *ImgpackApp) setupToolbar() {
 
 	rotateImgsToolbarAction := widget.NewToolbarAction(theme.MediaReplayIcon(), iApp.rotateAction)
 	cutImgToolbarAction := widget.NewToolbarAction(theme.ContentCutIcon(), iApp.cutAction)
+	cropImgToolbarAction := widget.NewToolbarAction(theme.ViewFullScreenIcon(), iApp.cropAction)
+	adjustImgToolbarAction := widget.NewToolbarAction(theme.ColorPaletteIcon(), iApp.adjustAction)
+	historyImgToolbarAction := widget.NewToolbarAction(theme.HistoryIcon(), iApp.historyAction)
 
 	iApp.enableOnSelectImageEnables = append(
 		iApp.enableOnSelectImageEnables,
@@ -267,8 +417,13 @@ func (iApp *ImgpackApp) setupToolbar() {
 		downloadImgsToolbarAction,
 		rotateImgsToolbarAction,
 		cutImgToolbarAction,
+		cropImgToolbarAction,
+		adjustImgToolbarAction,
+		historyImgToolbarAction,
 	)
 
+	iApp.viewToggleAction = widget.NewToolbarAction(theme.GridIcon(), iApp.toggleViewAction)
+
 	iApp.toolbar = widget.NewToolbar(
 		widget.NewToolbarAction(theme.DocumentCreateIcon(), iApp.clearAction),
 		widget.NewToolbarAction(theme.DocumentSaveIcon(), iApp.saveArchiveAction),
@@ -283,36 +438,56 @@ func (iApp *ImgpackApp) setupToolbar() {
 		widget.NewToolbarSeparator(),
 		rotateImgsToolbarAction,
 		cutImgToolbarAction,
+		cropImgToolbarAction,
+		adjustImgToolbarAction,
+		historyImgToolbarAction,
 		widget.NewToolbarSpacer(),
+		iApp.viewToggleAction,
+		widget.NewToolbarAction(theme.InfoIcon(), iApp.toggleInfoAction),
 		widget.NewToolbarAction(theme.SettingsIcon(), iApp.showPreferences),
 		widget.NewToolbarAction(theme.HelpIcon(), iApp.showAbout),
 	)
 }
 
 func (iApp *ImgpackApp) setupContent() {
+	listDragGroup := iApp.newImgDragGroup()
+	iApp.listDragGroup = listDragGroup
+
 	imgListWidget := widget.NewList(
 		func() int {
 			return iApp.opTable.Len()
 		},
 		func() fyne.CanvasObject {
-			return widget.NewLabel("Item")
+			return listDragGroup.NewItem(widget.NewLabel("Item"))
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			o.(*widget.Label).SetText(iApp.opTable.Get(i).Filename)
+			item := o.(*dragItem)
+			listDragGroup.Bind(item, i)
+			item.SetInSelection(iApp.opTable.InSelection(i))
+			item.content.(*widget.Label).SetText(imgLabel(iApp.opTable.Get(i)))
 		},
 	)
 
 	imgListWidget.OnSelected = func(id widget.ListItemID) {
-		iApp.opTable.Select(int(id))
+		iApp.selectImg(int(id))
 	}
 
 	iApp.imgListWidget = imgListWidget
+	listDragGroup.view = imgListWidget
+
+	iApp.imgListPane = imgListWidget
+	iApp.imgGridPane = iApp.setupImgGrid()
+	iApp.setGridView(getPreferenceGridView())
 
 	imgShow := canvas.NewImageFromImage(assets.ImgPlaceholder)
 	imgShow.FillMode = canvas.ImageFillContain
 	iApp.imgShow = imgShow
 
-	hSplit := container.NewHSplit(imgListWidget, imgShow)
+	preview := container.NewBorder(nil, nil, nil, iApp.setupInfoPanel(), imgShow)
+	iApp.setInfoPanel(getPreferenceInfoPanel())
+
+	hSplit := container.NewHSplit(
+		container.NewStack(iApp.imgListPane, iApp.imgGridPane), preview)
 	hSplit.SetOffset(0.25)
 
 	stateBar := widget.NewLabel("Ready")
@@ -326,11 +501,126 @@ func (iApp *ImgpackApp) setupContent() {
 
 func (iApp *ImgpackApp) Run() {
 	iApp.mainWindow.ShowAndRun()
+
+	if err := imgutil.CloseStore(); err != nil {
+		log.Println("Failed to close the image store:", err)
+	}
+}
+
+// newImgDragGroup creates a drag group which moves the dragged image
+// to the position it is dropped on, the whole selection is moved
+// if the dragged image is in it.
+func (iApp *ImgpackApp) newImgDragGroup() *dragGroup {
+	return &dragGroup{
+		onHover: func(from, to int) {
+			imgs := iApp.dragImgs(from)
+			if len(imgs) > 1 {
+				iApp.stateBar.SetText(fmt.Sprintf("Move %d images to position %d", len(imgs), to+1))
+				return
+			}
+
+			iApp.stateBar.SetText(fmt.Sprintf("Move %s to position %d",
+				iApp.opTable.Get(from).Filename, to+1))
+		},
+		onDrop: func(from, to int) {
+			iApp.opTable.MoveImgsTo(iApp.dragImgs(from), to)
+			iApp.stateBar.SetText(fmt.Sprintf("Moved to position %d", to+1))
+		},
+	}
+}
+
+// dragImgs returns the images moved when the image at idx is dragged.
+func (iApp *ImgpackApp) dragImgs(idx int) []*imgutil.Image {
+	if iApp.opTable.InSelection(idx) {
+		return iApp.opTable.GetSelectedImgs()
+	}
+
+	return []*imgutil.Image{iApp.opTable.Get(idx)}
+}
+
+// selectImg selects the image at idx, Shift selects the range from
+// the selected image and the shortcut modifier adds to the selection.
+func (iApp *ImgpackApp) selectImg(idx int) {
+	var modifiers fyne.KeyModifier
+	if drv, ok := iApp.fApp.Driver().(desktop.Driver); ok {
+		modifiers = drv.CurrentKeyModifiers()
+	}
+
+	switch {
+	case modifiers&fyne.KeyModifierShift != 0:
+		iApp.opTable.SelectRange(idx)
+	case modifiers&fyne.KeyModifierShortcutDefault != 0:
+		iApp.opTable.SelectMore(idx)
+	default:
+		iApp.opTable.Select(idx)
+	}
+}
+
+// imgLabel returns the label of the image in the list and the grid,
+// an animated image of which only the first frame is kept is marked.
+func imgLabel(img *imgutil.Image) string {
+	label := img.Filename
+	if img.Frames > 1 {
+		label = fmt.Sprintf("%s [%d frames]", label, img.Frames)
+	}
+	if img.SplitBefore {
+		label += " [new volume]"
+	}
+
+	return label
+}
+
+// setGridView switches between the list and the thumbnail grid view.
+func (iApp *ImgpackApp) setGridView(grid bool) {
+	setPreferenceGridView(grid)
+
+	if grid {
+		iApp.imgListPane.Hide()
+		iApp.imgGridPane.Show()
+		iApp.viewToggleAction.SetIcon(theme.ListIcon())
+		return
+	}
+
+	iApp.imgGridPane.Hide()
+	iApp.imgListPane.Show()
+	iApp.viewToggleAction.SetIcon(theme.GridIcon())
+}
+
+// showSelectedImg shows the selected image and its description.
+func (iApp *ImgpackApp) showSelectedImg() {
+	img := iApp.opTable.GetSelectedImg()
+
+	bound := img.Bounds()
+	imgDesc := fmt.Sprintf("filename: %s, format: %s, size: %dx%d",
+		img.Filename, img.Type, bound.Dx(), bound.Dy())
+	if img.Frames > 1 {
+		imgDesc += fmt.Sprintf(", first of %d frames", img.Frames)
+	}
+	if img.Color != "" {
+		imgDesc += ", " + img.Color
+	}
+	if img.Provenance.IsKnown() {
+		imgDesc += ", source: " + img.Provenance.String()
+	}
+
+	iApp.stateBar.SetText(imgDesc)
+
+	decoded, err := img.Img()
+	if err != nil {
+		decoded = assets.ImgPlaceholder
+		iApp.stateBar.SetText(fmt.Sprintf("Failed to decode %s: %v", img.Filename, err))
+	}
+
+	iApp.imgShow.Resource = nil
+	iApp.imgShow.Image = decoded
+	iApp.imgShow.Refresh()
+
+	iApp.updateInfoPanel()
 }
 
 func (iApp *ImgpackApp) showPreferences() {
 	dlg := dialog.NewCustom("Preference", "OK", preferenceContent(), iApp.mainWindow)
-	dlg.Resize(fyne.NewSize(400, 300))
+	dlg.Resize(fyne.NewSize(450, 600))
 	dlg.Show()
 }
 
@@ -344,46 +634,60 @@ func (iApp *ImgpackApp) showAbout() {
 }
 
 func (iApp *ImgpackApp) dropFiles(files []fyne.URI) {
-	iApp.readingImagesDlg.Show()
-	defer iApp.readingImagesDlg.Hide()
+	ctx := iApp.readingImagesDlg.Start()
 
-	accImgs := []*imgutil.Image{}
+	go func() {
+		defer iApp.readingImagesDlg.Hide()
 
-	for _, file := range files {
-		fileStat, err := os.Stat(file.Path())
-		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
-			continue
-		}
+		opts := getPreferenceReadOptions()
+		opts.Progress = iApp.readingImagesDlg.SetProgress
+		accImgs := []*imgutil.Image{}
+
+		for _, file := range files {
+			imgs, err := readImgsInPath(ctx, file.Path(), opts)
+			if errors.Is(err, context.Canceled) {
+				iApp.stateBar.SetText("Reading cancelled")
+				return
+			}
 
-		if fileStat.IsDir() {
-			imgs, err := imgutil.ReadImgsInDir(file.Path())
 			if err != nil {
 				dialog.ShowError(err, iApp.mainWindow)
 				continue
 			}
 
 			accImgs = append(accImgs, imgs...)
-			continue
 		}
 
-		f, err := os.Open(file.Path())
-		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
-			continue
-		}
+		iApp.opTable.Insert(accImgs...)
+	}()
+}
+
+// readImgsInPath reads the images in a file or a directory.
+func readImgsInPath(ctx context.Context, p string, opts imgutil.ReadOptions) ([]*imgutil.Image, error) {
+	fileStat, err := os.Stat(p)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
 
-		imgs, err := imgutil.ReadImgsInFile(f, path.Base(file.Path()))
+	if fileStat.IsDir() {
+		imgs, err := imgutil.ReadImgsInDirContext(ctx, p, opts)
 		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
-			continue
+			return nil, util.Errorf("%w", err)
 		}
+		return imgs, nil
+	}
 
-		accImgs = append(accImgs, imgs...)
-
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
 	}
+	defer f.Close()
 
-	iApp.opTable.Insert(accImgs...)
+	imgs, err := imgutil.ReadImgsInFileContext(ctx, f, p, opts)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	return imgs, nil
 }
 
 func (iApp *ImgpackApp) onTabKey(e *fyne.KeyEvent) {
@@ -423,24 +727,103 @@ func (iApp *ImgpackApp) clearAction() {
 		iApp.mainWindow)
 }
 
-func (iApp *ImgpackApp) addAction() {
-	openImgsFile(func(f fyne.URIReadCloser) {
-		iApp.readingImagesDlg.Show()
+// openProjectAction replaces the images with the images of a project file.
+func (iApp *ImgpackApp) openProjectAction() {
+	open := func() {
+		openProjectFile(iApp.loadProject, iApp.mainWindow)
+	}
+
+	if iApp.opTable.Len() == 0 {
+		open()
+		return
+	}
+
+	dialog.ShowConfirm("Open project", "The current images will be replaced. Continue?",
+		func(b bool) {
+			if b {
+				open()
+			}
+		},
+		iApp.mainWindow)
+}
+
+func (iApp *ImgpackApp) loadProject(f fyne.URIReadCloser) {
+	ctx := iApp.readingImagesDlg.Start()
+
+	go func() {
 		defer iApp.readingImagesDlg.Hide()
+		defer f.Close()
 
-		if f.URI() == nil {
-			log.Println("URI is nil")
+		opts := getPreferenceReadOptions()
+		opts.Progress = iApp.readingImagesDlg.SetProgress
+
+		imgs, err := imgutil.LoadProject(ctx, f, opts)
+		if errors.Is(err, context.Canceled) {
+			iApp.stateBar.SetText("Reading cancelled")
 			return
 		}
 
-		filepath := f.URI().Path()
-		imgs, err := imgutil.ReadImgsInFile(f, path.Base(filepath))
 		if err != nil {
 			dialog.ShowError(err, iApp.mainWindow)
 			return
 		}
 
+		iApp.opTable.Clear()
 		iApp.opTable.Insert(imgs...)
+		iApp.stateBar.SetText(fmt.Sprintf("Opened project %s", f.URI().Name()))
+	}()
+}
+
+func (iApp *ImgpackApp) saveProjectAction() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	saveProjectFile("project.imgpack", func(f fyne.URIWriteCloser) {
+		ctx := iApp.savingDlg.Start()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			err := imgutil.SaveProject(ctx, imgs, f, iApp.savingDlg.SetProgress)
+			iApp.finishSaving(f, err)
+		}()
+	}, iApp.mainWindow)
+}
+
+func (iApp *ImgpackApp) addAction() {
+	openImgsFile(func(f fyne.URIReadCloser) {
+		if f.URI() == nil {
+			log.Println("URI is nil")
+			f.Close()
+			return
+		}
+
+		ctx := iApp.readingImagesDlg.Start()
+
+		go func() {
+			defer iApp.readingImagesDlg.Hide()
+			defer f.Close()
+
+			opts := getPreferenceReadOptions()
+			opts.Progress = iApp.readingImagesDlg.SetProgress
+
+			filepath := f.URI().Path()
+			imgs, err := imgutil.ReadImgsInFileContext(ctx, f, filepath, opts)
+			if errors.Is(err, context.Canceled) {
+				iApp.stateBar.SetText("Reading cancelled")
+				return
+			}
+
+			if err != nil {
+				dialog.ShowError(err, iApp.mainWindow)
+				return
+			}
+
+			iApp.opTable.Insert(imgs...)
+		}()
 	}, iApp.mainWindow)
 }
 
@@ -451,17 +834,14 @@ func (iApp *ImgpackApp) downloadAction() {
 
 	img := iApp.opTable.GetSelectedImg()
 	saveImgFile(img.Filename+".jpg", func(f fyne.URIWriteCloser) {
-		iApp.savingDlg.Show()
-		defer iApp.savingDlg.Hide()
+		iApp.savingDlg.Start()
 
-		err := imgutil.SaveImg(img, f, getPreferenceJPGQuality())
-		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
-			return
-		}
-		f.Close()
+		go func() {
+			defer iApp.savingDlg.Hide()
 
-		iApp.stateBar.SetText("Saved successfully")
+			err := imgutil.SaveImg(img, f, getPreferenceSaveOptions())
+			iApp.finishSaving(f, err)
+		}()
 	}, iApp.mainWindow)
 }
 
@@ -481,6 +861,35 @@ func (iApp *ImgpackApp) moveDownAction() {
 	iApp.opTable.MoveDown()
 }
 
+func (iApp *ImgpackApp) moveToAction() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	posEntry := widget.NewEntry()
+	posEntry.SetText(strconv.Itoa(iApp.opTable.GetSelectedIdx() + 1))
+	posEntry.Validator = func(s string) error {
+		pos, err := strconv.Atoi(s)
+		if err != nil || pos < 1 || pos > iApp.opTable.Len() {
+			return fmt.Errorf("position must be between 1 and %d", iApp.opTable.Len())
+		}
+		return nil
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Position", posEntry),
+	}
+
+	dialog.ShowForm("Move to position", "Move", "Cancel", items, func(b bool) {
+		if !b || !iApp.opTable.IsSelected() {
+			return
+		}
+
+		pos, _ := strconv.Atoi(posEntry.Text)
+		iApp.opTable.MoveImgsTo(iApp.opTable.GetSelectedImgs(), pos-1)
+	}, iApp.mainWindow)
+}
+
 func (iApp *ImgpackApp) saveArchiveAction() {
 	if iApp.opTable.Len() == 0 {
 		iApp.stateBar.SetText("No image to save")
@@ -488,20 +897,41 @@ func (iApp *ImgpackApp) saveArchiveAction() {
 	}
 
 	saveArchiveFile("output.cbz", func(f fyne.URIWriteCloser) {
-		iApp.savingDlg.Show()
-		defer iApp.savingDlg.Hide()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+		prependDigit := getPreferencePrependDigit()
 
-		err := imgutil.SaveImgsAsZip(
-			iApp.opTable.GetImgs(), f,
-			getPreferencePrependDigit(),
-			getPreferenceJPGQuality())
-		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
+		opts := getPreferenceSaveOptions()
+		opts.Book = strings.TrimSuffix(f.URI().Name(), f.URI().Extension())
+
+		save := func() {
+			ctx := iApp.savingDlg.Start()
+
+			go func() {
+				defer iApp.savingDlg.Hide()
+
+				opts.Progress = iApp.savingDlg.SetProgress
+
+				err := imgutil.SaveImgsAsZipContext(ctx, imgs, f, prependDigit, opts)
+				iApp.finishSaving(f, err)
+			}()
+		}
+
+		_, collisions := imgutil.ZipEntryNames(imgs, prependDigit, opts)
+		if collisions == 0 {
+			save()
 			return
 		}
-		f.Close()
 
-		iApp.stateBar.SetText("Saved successfully")
+		msg := fmt.Sprintf("%d images have the same name as an earlier image.\n"+
+			"They will be saved with the suffixes _2, _3...", collisions)
+		dialog.ShowConfirm("Duplicate Names", msg, func(ok bool) {
+			if !ok {
+				iApp.finishSaving(f, context.Canceled)
+				return
+			}
+
+			save()
+		}, iApp.mainWindow)
 	}, iApp.mainWindow)
 }
 
@@ -512,19 +942,73 @@ func (iApp *ImgpackApp) savePDFAction() {
 	}
 
 	savePDFFile("output.pdf", func(f fyne.URIWriteCloser) {
-		iApp.savingDlg.Show()
-		defer iApp.savingDlg.Hide()
+		ctx := iApp.savingDlg.Start()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
 
-		err := imgutil.SaveImgsAsPDF(
-			iApp.opTable.GetImgs(), f, getPreferenceJPGQuality())
-		if err != nil {
-			dialog.ShowError(err, iApp.mainWindow)
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			opts := getPreferenceSaveOptions()
+			opts.Progress = iApp.savingDlg.SetProgress
+
+			err := imgutil.SaveImgsAsPDFContext(ctx, imgs, f, opts)
+			iApp.finishSaving(f, err)
+		}()
+	}, iApp.mainWindow)
+}
+
+// finishSaving closes the output file and reports the result.
+// The file is removed if the saving failed or was cancelled,
+// so no half-written output is left.
+func (iApp *ImgpackApp) finishSaving(f fyne.URIWriteCloser, err error) {
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		if delErr := storage.Delete(f.URI()); delErr != nil {
+			log.Println("Failed to remove the output file:", delErr)
+		}
+
+		if errors.Is(err, context.Canceled) {
+			iApp.stateBar.SetText("Saving cancelled")
 			return
 		}
-		f.Close()
 
-		iApp.stateBar.SetText("Saved successfully")
-	}, iApp.mainWindow)
+		dialog.ShowError(err, iApp.mainWindow)
+		return
+	}
+
+	iApp.stateBar.SetText("Saved successfully")
+}
+
+func (iApp *ImgpackApp) saveGIFAction() {
+	iApp.showGIFDialog()
+}
+
+func (iApp *ImgpackApp) saveTIFFAction() {
+	iApp.showTIFFDialog()
+}
+
+func (iApp *ImgpackApp) saveTargetSizeAction() {
+	iApp.showTargetSizeDialog()
+}
+
+func (iApp *ImgpackApp) saveVolumesAction() {
+	iApp.showVolumeDialog()
+}
+
+func (iApp *ImgpackApp) saveFolderAction() {
+	iApp.showFolderDialog()
+}
+
+func (iApp *ImgpackApp) toggleViewAction() {
+	iApp.setGridView(!getPreferenceGridView())
+}
+
+func (iApp *ImgpackApp) toggleInfoAction() {
+	iApp.setInfoPanel(!getPreferenceInfoPanel())
 }
 
 func (iApp *ImgpackApp) rotateAction() {
@@ -534,3 +1018,43 @@ func (iApp *ImgpackApp) rotateAction() {
 func (iApp *ImgpackApp) cutAction() {
 	iApp.opTable.Cut()
 }
+
+func (iApp *ImgpackApp) cropAction() {
+	iApp.showCropDialog()
+}
+
+func (iApp *ImgpackApp) adjustAction() {
+	iApp.showAdjustWindow()
+}
+
+func (iApp *ImgpackApp) historyAction() {
+	iApp.showHistoryWindow()
+}
+
+func (iApp *ImgpackApp) batchRenameAction() {
+	iApp.showRenameDialog()
+}
+
+func (iApp *ImgpackApp) findDuplicatesAction() {
+	iApp.showDuplicateDialog()
+}
+
+func (iApp *ImgpackApp) findBlankPagesAction() {
+	iApp.showBlankDialog()
+}
+
+func (iApp *ImgpackApp) toggleSplitAction() {
+	iApp.opTable.ToggleSplit()
+}
+
+func (iApp *ImgpackApp) revealSourceAction() {
+	iApp.revealSource()
+}
+
+func (iApp *ImgpackApp) reimportAction() {
+	iApp.reimportSelected()
+}
+
+func (iApp *ImgpackApp) resetAction() {
+	iApp.opTable.Reset()
+}
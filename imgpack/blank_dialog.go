@@ -0,0 +1,226 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+const (
+	// blankThumbnailSize is the size of the thumbnails of the blank pages
+	blankThumbnailSize = 140
+
+	// maxBlankInkThreshold is the largest ink threshold of the slider
+	maxBlankInkThreshold = 128
+
+	// maxBlankMargin is the largest margin percentage of the slider
+	maxBlankMargin = 20
+
+	// maxBlankCoverage is the largest coverage percentage of the slider
+	maxBlankCoverage = 5
+
+	// maxBlankDeviation is the largest deviation of the slider
+	maxBlankDeviation = 30
+)
+
+// newBlankSlider creates a slider whose value is shown by a label on its right.
+func newBlankSlider(minValue, maxValue, step, value float64, format string,
+	onChanged func(float64)) (*widget.Slider, fyne.CanvasObject) {
+
+	label := widget.NewLabel(fmt.Sprintf(format, value))
+	slider := widget.NewSlider(minValue, maxValue)
+	slider.Step = step
+	slider.Value = value
+	slider.OnChanged = func(v float64) {
+		label.SetText(fmt.Sprintf(format, v))
+		if onChanged != nil {
+			onChanged(v)
+		}
+	}
+
+	return slider, container.NewBorder(nil, nil, nil, label, slider)
+}
+
+// showBlankDialog asks how the ink is told from the scanner noise,
+// then analyzes the images and shows the blank pages found.
+func (iApp *ImgpackApp) showBlankDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to analyze")
+		return
+	}
+
+	inkSlider, inkRow := newBlankSlider(1, maxBlankInkThreshold, 1,
+		float64(getPreferenceBlankInkThreshold()), "%.0f", nil)
+	marginSlider, marginRow := newBlankSlider(0, maxBlankMargin, 1,
+		getPreferenceBlankMargin(), "%.0f%%", nil)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Ink Threshold", inkRow),
+		widget.NewFormItem("Margin", marginRow),
+	}
+	items[0].HintText = "How much darker than the paper a pixel is ink"
+	items[1].HintText = "Ignored border of each side, where scanners leave shadows"
+
+	dlg := dialog.NewForm("Find Blank Pages", "Analyze", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		inkThreshold := int(inkSlider.Value)
+		margin := marginSlider.Value
+		setPreferenceBlankInkThreshold(inkThreshold)
+		setPreferenceBlankMargin(margin)
+
+		iApp.findBlankPages(imgutil.BlankOptions{
+			InkThreshold: inkThreshold,
+			Margin:       margin,
+			Workers:      getPreferenceDecodeWorkers(),
+		})
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(450, 280))
+	dlg.Show()
+}
+
+// findBlankPages analyzes the images in the background
+// and shows the blank pages found.
+func (iApp *ImgpackApp) findBlankPages(opts imgutil.BlankOptions) {
+	ctx := iApp.scanningDlg.Start()
+	imgs := slices.Clone(iApp.opTable.GetImgs())
+
+	go func() {
+		defer iApp.scanningDlg.Hide()
+
+		opts.Progress = iApp.scanningDlg.SetProgress
+
+		stats, err := imgutil.AnalyzeBlankPages(ctx, imgs, opts)
+		if errors.Is(err, context.Canceled) {
+			iApp.stateBar.SetText("Scanning cancelled")
+			return
+		}
+
+		if err != nil {
+			dialog.ShowError(err, iApp.mainWindow)
+			return
+		}
+
+		iApp.showBlankPages(imgs, stats)
+	}()
+}
+
+// showBlankPages lists the pages under the thresholds, which are updated
+// live, and removes the checked ones.
+func (iApp *ImgpackApp) showBlankPages(imgs []*imgutil.Image, stats []imgutil.BlankStats) {
+	thresholds := imgutil.BlankThresholds{
+		MaxCoverage:  getPreferenceBlankMaxCoverage(),
+		MaxDeviation: getPreferenceBlankMaxDeviation(),
+	}
+
+	// the thumbnails are kept while the thresholds change
+	cards := make(map[int]fyne.CanvasObject)
+	unchecked := make(map[int]bool)
+
+	pagesBox := container.NewGridWrap(fyne.NewSize(blankThumbnailSize+20, blankThumbnailSize+120))
+	countLabel := widget.NewLabel("")
+
+	var blanks []int
+	update := func() {
+		blanks = blanks[:0]
+		var objects []fyne.CanvasObject
+		for i, stat := range stats {
+			if !thresholds.IsBlank(stat) {
+				continue
+			}
+
+			card, ok := cards[i]
+			if !ok {
+				card = iApp.blankCard(imgs[i], i, stat, func(checked bool) {
+					unchecked[i] = !checked
+				})
+				cards[i] = card
+			}
+
+			blanks = append(blanks, i)
+			objects = append(objects, card)
+		}
+
+		pagesBox.Objects = objects
+		pagesBox.Refresh()
+		countLabel.SetText(fmt.Sprintf("%d of %d pages are blank", len(blanks), len(imgs)))
+	}
+
+	_, coverageRow := newBlankSlider(0, maxBlankCoverage, 0.05, thresholds.MaxCoverage, "%.2f%%",
+		func(v float64) {
+			thresholds.MaxCoverage = v
+			setPreferenceBlankMaxCoverage(v)
+			update()
+		})
+	_, deviationRow := newBlankSlider(0, maxBlankDeviation, 0.5, thresholds.MaxDeviation, "%.1f",
+		func(v float64) {
+			thresholds.MaxDeviation = v
+			setPreferenceBlankMaxDeviation(v)
+			update()
+		})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Max Ink Coverage", coverageRow),
+		widget.NewFormItem("Max Deviation", deviationRow),
+	)
+
+	var dlg dialog.Dialog
+	removeBtn := widget.NewButtonWithIcon("Remove Checked", theme.DeleteIcon(), func() {
+		var removed []*imgutil.Image
+		for _, i := range blanks {
+			if !unchecked[i] {
+				removed = append(removed, imgs[i])
+			}
+		}
+
+		if len(removed) == 0 {
+			return
+		}
+
+		iApp.opTable.DeleteImgs(removed...)
+		iApp.stateBar.SetText(fmt.Sprintf("Removed %d blank pages", len(removed)))
+		dlg.Hide()
+	})
+	removeBtn.Importance = widget.DangerImportance
+
+	update()
+
+	content := container.NewBorder(
+		container.NewVBox(form, countLabel),
+		removeBtn, nil, nil,
+		container.NewVScroll(pagesBox))
+
+	dlg = dialog.NewCustom("Blank Pages", "Close", content, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(800, 600))
+	dlg.Show()
+}
+
+// blankCard shows the thumbnail, the position and the ink statistics
+// of a blank page, with a check to remove it.
+func (iApp *ImgpackApp) blankCard(img *imgutil.Image, idx int, stat imgutil.BlankStats,
+	onChecked func(bool)) fyne.CanvasObject {
+
+	thumbImg := iApp.newThumbnailImage(img, blankThumbnailSize)
+
+	check := widget.NewCheck(fmt.Sprintf("#%d", idx+1), onChecked)
+	check.SetChecked(true)
+
+	nameLabel := widget.NewLabel(img.Filename)
+	nameLabel.Truncation = fyne.TextTruncateEllipsis
+
+	statLabel := widget.NewLabel(fmt.Sprintf("ink %.2f%%, dev %.1f", stat.Coverage, stat.Deviation))
+	statLabel.Truncation = fyne.TextTruncateEllipsis
+
+	return container.NewBorder(nil, container.NewVBox(check, nameLabel, statLabel), nil, nil, thumbImg)
+}
@@ -0,0 +1,78 @@
+package imgpack
+
+import (
+	"image"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"github.com/disintegration/imaging"
+)
+
+// maxCropMargin is the largest margin percentage of each side
+const maxCropMargin = 45
+
+// cropMargins are the percentages of the sides of an image cropped away
+type cropMargins struct {
+	left, top, right, bottom float64
+}
+
+// rect returns the rectangle of an image of size kept by the margins
+func (m cropMargins) rect(size image.Point) image.Rectangle {
+	return image.Rect(
+		int(float64(size.X)*m.left/100),
+		int(float64(size.Y)*m.top/100),
+		size.X-int(float64(size.X)*m.right/100),
+		size.Y-int(float64(size.Y)*m.bottom/100),
+	)
+}
+
+// showCropDialog asks the margins cropped from the selected image,
+// the crop is previewed in imgShow until it is applied or cancelled.
+func (iApp *ImgpackApp) showCropDialog() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	img, err := iApp.opTable.GetSelectedImg().Img()
+	if err != nil {
+		dialog.ShowError(err, iApp.mainWindow)
+		return
+	}
+
+	preview := imaging.Fit(img, adjustPreviewSize, adjustPreviewSize, imaging.Box)
+
+	var margins cropMargins
+	updatePreview := func() {
+		iApp.imgShow.Image = imaging.Crop(preview, margins.rect(preview.Bounds().Size()))
+		iApp.imgShow.Refresh()
+	}
+
+	newMarginSlider := func(name string, margin *float64) []fyne.CanvasObject {
+		label, slider := newAdjustSlider(name, 0, maxCropMargin, 0.5, 0, func(v float64) {
+			*margin = v
+			updatePreview()
+		})
+		return []fyne.CanvasObject{label, slider}
+	}
+
+	var objs []fyne.CanvasObject
+	objs = append(objs, newMarginSlider("Left %", &margins.left)...)
+	objs = append(objs, newMarginSlider("Top %", &margins.top)...)
+	objs = append(objs, newMarginSlider("Right %", &margins.right)...)
+	objs = append(objs, newMarginSlider("Bottom %", &margins.bottom)...)
+	content := container.New(layout.NewFormLayout(), objs...)
+
+	dlg := dialog.NewCustomConfirm("Crop", "Apply", "Cancel", content, func(b bool) {
+		// the cropped image is shown again once the crop is applied
+		iApp.imgShow.Image = img
+		iApp.imgShow.Refresh()
+
+		if b {
+			iApp.opTable.Crop(margins.rect(img.Bounds().Size()))
+		}
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(400, 300))
+	dlg.Show()
+}
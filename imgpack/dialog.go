@@ -25,6 +25,7 @@ func openImgsFile(cb func(fyne.URIReadCloser), w fyne.Window) {
 
 	dlg.SetFilter(storage.NewExtensionFileFilter(slices.Concat(
 		imgutil.SupportedImageExts,
+		imgutil.SupportedVectorExts,
 		imgutil.SupportedArchiveExts,
 		imgutil.SupportedPDFExts)))
 	dlg.Resize(fyne.NewSize(600, 600))
@@ -91,3 +92,82 @@ func savePDFFile(defaultName string, cb func(fyne.URIWriteCloser), w fyne.Window
 	dlg.Resize(fyne.NewSize(600, 600))
 	dlg.Show()
 }
+
+func openProjectFile(cb func(fyne.URIReadCloser), w fyne.Window) {
+	dlg := dialog.NewFileOpen(func(f fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		if f == nil {
+			return
+		}
+
+		cb(f)
+	}, w)
+
+	dlg.SetFilter(storage.NewExtensionFileFilter(imgutil.SupportedProjectExts))
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
+
+func saveProjectFile(defaultName string, cb func(fyne.URIWriteCloser), w fyne.Window) {
+	dlg := dialog.NewFileSave(func(f fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		if f == nil {
+			return
+		}
+
+		cb(f)
+	}, w)
+
+	dlg.SetFileName(defaultName)
+	dlg.SetFilter(storage.NewExtensionFileFilter(imgutil.SupportedProjectExts))
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
+
+func saveGIFFile(defaultName string, cb func(fyne.URIWriteCloser), w fyne.Window) {
+	dlg := dialog.NewFileSave(func(f fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		if f == nil {
+			return
+		}
+
+		cb(f)
+	}, w)
+
+	dlg.SetFileName(defaultName)
+	dlg.SetFilter(storage.NewExtensionFileFilter([]string{".gif"}))
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
+
+func saveTIFFFile(defaultName string, cb func(fyne.URIWriteCloser), w fyne.Window) {
+	dlg := dialog.NewFileSave(func(f fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+
+		if f == nil {
+			return
+		}
+
+		cb(f)
+	}, w)
+
+	dlg.SetFileName(defaultName)
+	dlg.SetFilter(storage.NewExtensionFileFilter([]string{".tiff", ".tif"}))
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
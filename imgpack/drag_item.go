@@ -0,0 +1,227 @@
+package imgpack
+
+import (
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// dragScrollEdge is the height of the edges of the view
+	// where a dragged item scrolls it
+	dragScrollEdge = 32
+
+	// dragScrollStep is how far the view is scrolled on each tick
+	dragScrollStep = 20
+
+	// dragScrollInterval is the interval of the scroll ticks
+	dragScrollInterval = 50 * time.Millisecond
+)
+
+// dragItem is an item of the image list or grid which can be dragged
+// onto another item to move its image there.
+type dragItem struct {
+	widget.BaseWidget
+
+	content fyne.CanvasObject
+	group   *dragGroup
+
+	// selBg highlights the item when its image is in the selection
+	selBg *canvas.Rectangle
+
+	// id is the index of the image shown by the item
+	id int
+
+	dragging bool
+}
+
+func (d *dragItem) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewStack(d.selBg, d.content))
+}
+
+// SetInSelection sets whether the item is highlighted as selected.
+func (d *dragItem) SetInSelection(selected bool) {
+	if selected {
+		d.selBg.Show()
+	} else {
+		d.selBg.Hide()
+	}
+}
+
+func (d *dragItem) Dragged(e *fyne.DragEvent) {
+	if !d.dragging {
+		d.dragging = true
+		d.group.startDrag(d.id)
+	}
+
+	d.group.dragTo(e.AbsolutePosition)
+}
+
+func (d *dragItem) DragEnd() {
+	if !d.dragging {
+		return
+	}
+	d.dragging = false
+
+	d.group.endDrag()
+}
+
+// scrollView is a list or grid which can be scrolled to an offset.
+type scrollView interface {
+	fyne.CanvasObject
+
+	ScrollToOffset(offset float32)
+	GetScrollOffset() float32
+}
+
+// dragGroup is the set of items of a list or grid,
+// an item is dropped onto the item of the group under the pointer.
+// The view is scrolled while an item is dragged near its edges.
+type dragGroup struct {
+	items map[*dragItem]struct{}
+	view  scrollView
+
+	// from is the index of the image of the dragged item,
+	// which is kept since the item is reused when it is scrolled away
+	from int
+
+	mu      sync.Mutex
+	dragPos fyne.Position
+	stop    chan struct{}
+
+	// onHover is called while an item is dragged over another item
+	onHover func(from, to int)
+
+	// onDrop is called when the item from is dropped onto the item to
+	onDrop func(from, to int)
+}
+
+// NewItem creates an item of the group showing content.
+func (g *dragGroup) NewItem(content fyne.CanvasObject) *dragItem {
+	selBg := canvas.NewRectangle(theme.Color(theme.ColorNameSelection))
+	selBg.Hide()
+
+	item := &dragItem{content: content, group: g, selBg: selBg}
+	item.ExtendBaseWidget(item)
+
+	return item
+}
+
+// Bind sets the index of the image shown by the item,
+// the items are bound again when they are reused.
+func (g *dragGroup) Bind(item *dragItem, id int) {
+	if g.items == nil {
+		g.items = make(map[*dragItem]struct{})
+	}
+
+	item.id = id
+	g.items[item] = struct{}{}
+}
+
+// Prune forgets the items which are not shown anymore,
+// e.g. the items released by the list when it shrinks.
+func (g *dragGroup) Prune() {
+	driver := fyne.CurrentApp().Driver()
+
+	for item := range g.items {
+		if item.dragging {
+			continue
+		}
+
+		if !item.Visible() || driver.AbsolutePositionForObject(item).IsZero() {
+			delete(g.items, item)
+		}
+	}
+}
+
+func (g *dragGroup) startDrag(from int) {
+	g.from = from
+	g.stop = make(chan struct{})
+
+	go g.autoScroll(g.stop)
+}
+
+func (g *dragGroup) dragTo(pos fyne.Position) {
+	g.mu.Lock()
+	g.dragPos = pos
+	g.mu.Unlock()
+
+	if to, ok := g.itemAt(pos); ok && g.onHover != nil {
+		g.onHover(g.from, to)
+	}
+}
+
+func (g *dragGroup) endDrag() {
+	close(g.stop)
+
+	g.mu.Lock()
+	pos := g.dragPos
+	g.mu.Unlock()
+
+	if to, ok := g.itemAt(pos); ok && to != g.from {
+		g.onDrop(g.from, to)
+	}
+}
+
+// autoScroll scrolls the view while the dragged item is near its edges
+// until stop is closed.
+func (g *dragGroup) autoScroll(stop chan struct{}) {
+	if g.view == nil {
+		return
+	}
+
+	ticker := time.NewTicker(dragScrollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		g.mu.Lock()
+		pos := g.dragPos
+		g.mu.Unlock()
+
+		viewPos := fyne.CurrentApp().Driver().AbsolutePositionForObject(g.view)
+		height := g.view.Size().Height
+
+		switch {
+		case pos.Y < viewPos.Y+dragScrollEdge:
+			g.view.ScrollToOffset(max(g.view.GetScrollOffset()-dragScrollStep, 0))
+		case pos.Y > viewPos.Y+height-dragScrollEdge:
+			g.view.ScrollToOffset(g.view.GetScrollOffset() + dragScrollStep)
+		}
+	}
+}
+
+// itemAt returns the index of the image of the visible item at pos.
+func (g *dragGroup) itemAt(pos fyne.Position) (int, bool) {
+	driver := fyne.CurrentApp().Driver()
+
+	for item := range g.items {
+		if !item.Visible() {
+			continue
+		}
+
+		// items which are not on the canvas are at the origin
+		itemPos := driver.AbsolutePositionForObject(item)
+		if itemPos.IsZero() {
+			continue
+		}
+
+		size := item.Size()
+		if pos.X >= itemPos.X && pos.X < itemPos.X+size.Width &&
+			pos.Y >= itemPos.Y && pos.Y < itemPos.Y+size.Height {
+			return item.id, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,174 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+const (
+	// duplicateThumbnailSize is the size of the thumbnails of the groups
+	duplicateThumbnailSize = 160
+
+	// maxDuplicateThreshold is the largest threshold of the slider
+	maxDuplicateThreshold = 20
+)
+
+// showDuplicateDialog asks the hash and the threshold,
+// then searches the duplicate images and shows the groups found.
+func (iApp *ImgpackApp) showDuplicateDialog() {
+	if iApp.opTable.Len() < 2 {
+		iApp.stateBar.SetText("Not enough images to compare")
+		return
+	}
+
+	methodSelect := widget.NewSelect(imgutil.HashMethodNames, nil)
+	methodSelect.SetSelectedIndex(int(getPreferenceDuplicateMethod()))
+
+	thresholdLabel := widget.NewLabel(fmt.Sprint(getPreferenceDuplicateThreshold()))
+	thresholdSlider := widget.NewSlider(0, maxDuplicateThreshold)
+	thresholdSlider.Step = 1
+	thresholdSlider.Value = float64(getPreferenceDuplicateThreshold())
+	thresholdSlider.OnChanged = func(v float64) {
+		thresholdLabel.SetText(fmt.Sprint(int(v)))
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Hash", methodSelect),
+		widget.NewFormItem("Threshold",
+			container.NewBorder(nil, nil, nil, thresholdLabel, thresholdSlider)),
+	}
+	items[1].HintText = "Different bits allowed, 0 only matches identical pages"
+
+	dlg := dialog.NewForm("Find Duplicates", "Find", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		method := imgutil.HashMethod(methodSelect.SelectedIndex())
+		threshold := int(thresholdSlider.Value)
+		setPreferenceDuplicateMethod(method)
+		setPreferenceDuplicateThreshold(threshold)
+
+		iApp.findDuplicates(imgutil.DuplicateOptions{
+			Method:    method,
+			Threshold: threshold,
+			Workers:   getPreferenceDecodeWorkers(),
+		})
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(450, 250))
+	dlg.Show()
+}
+
+// findDuplicates searches the duplicate images in the background
+// and shows the groups found.
+func (iApp *ImgpackApp) findDuplicates(opts imgutil.DuplicateOptions) {
+	ctx := iApp.scanningDlg.Start()
+	imgs := slices.Clone(iApp.opTable.GetImgs())
+
+	go func() {
+		defer iApp.scanningDlg.Hide()
+
+		opts.Progress = iApp.scanningDlg.SetProgress
+
+		groups, err := imgutil.FindDuplicates(ctx, imgs, opts)
+		if errors.Is(err, context.Canceled) {
+			iApp.stateBar.SetText("Scanning cancelled")
+			return
+		}
+
+		if err != nil {
+			dialog.ShowError(err, iApp.mainWindow)
+			return
+		}
+
+		if len(groups) == 0 {
+			iApp.stateBar.SetText("No duplicate found")
+			return
+		}
+
+		iApp.showDuplicateGroups(imgs, groups)
+	}()
+}
+
+// showDuplicateGroups shows the groups of duplicate images side by side,
+// the extras of a group are the images after its first one.
+func (iApp *ImgpackApp) showDuplicateGroups(imgs []*imgutil.Image, groups [][]int) {
+	groupsBox := container.NewVBox()
+	var dlg dialog.Dialog
+
+	remaining := make(map[*fyne.Container][]*imgutil.Image)
+	removeExtras := func(groupObj *fyne.Container) {
+		group := remaining[groupObj]
+		delete(remaining, groupObj)
+		groupsBox.Remove(groupObj)
+
+		iApp.opTable.DeleteImgs(group[1:]...)
+		iApp.stateBar.SetText(fmt.Sprintf("Removed %d duplicate images", len(group)-1))
+
+		if len(remaining) == 0 {
+			dlg.Hide()
+		}
+	}
+
+	for i, group := range groups {
+		groupImgs := make([]*imgutil.Image, len(group))
+		cards := container.NewHBox()
+		for j, idx := range group {
+			groupImgs[j] = imgs[idx]
+			cards.Add(iApp.duplicateCard(imgs[idx], idx, j == 0))
+		}
+
+		groupObj := container.NewVBox()
+		removeBtn := widget.NewButtonWithIcon("Remove Extras", theme.DeleteIcon(), func() {
+			removeExtras(groupObj)
+		})
+		groupObj.Add(container.NewBorder(nil, nil,
+			widget.NewLabel(fmt.Sprintf("Group %d: %d images", i+1, len(group))), removeBtn))
+		groupObj.Add(container.NewHScroll(cards))
+		groupObj.Add(widget.NewSeparator())
+
+		remaining[groupObj] = groupImgs
+		groupsBox.Add(groupObj)
+	}
+
+	removeAllBtn := widget.NewButtonWithIcon("Remove All Extras", theme.DeleteIcon(), func() {
+		for _, groupObj := range slices.Clone(groupsBox.Objects) {
+			removeExtras(groupObj.(*fyne.Container))
+		}
+	})
+	removeAllBtn.Importance = widget.DangerImportance
+
+	content := container.NewBorder(nil, removeAllBtn, nil, nil, container.NewVScroll(groupsBox))
+
+	dlg = dialog.NewCustom(fmt.Sprintf("Duplicates (%d groups)", len(groups)), "Close",
+		content, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(800, 600))
+	dlg.Show()
+}
+
+// duplicateCard shows the thumbnail and the position of an image of a group,
+// the first image of the group is the one kept.
+func (iApp *ImgpackApp) duplicateCard(img *imgutil.Image, idx int, kept bool) fyne.CanvasObject {
+	thumbImg := iApp.newThumbnailImage(img, duplicateThumbnailSize)
+
+	status := "extra"
+	if kept {
+		status = "kept"
+	}
+
+	label := widget.NewLabel(fmt.Sprintf("#%d %s\n(%s)", idx+1, img.Filename, status))
+	label.Alignment = fyne.TextAlignCenter
+	label.Truncation = fyne.TextTruncateEllipsis
+
+	return container.NewBorder(nil, label, nil, nil, thumbImg)
+}
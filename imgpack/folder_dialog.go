@@ -0,0 +1,97 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// showFolderDialog asks the encoder and what is done with the existing files,
+// then exports the images as files in a chosen folder.
+func (iApp *ImgpackApp) showFolderDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	encoderSelect := widget.NewSelect(imgutil.ImageEncoderNames, nil)
+	encoderSelect.SetSelectedIndex(int(getPreferenceFolderEncoder()))
+
+	existingSelect := widget.NewSelect(imgutil.ExistingPolicyNames, nil)
+	existingSelect.SetSelectedIndex(int(getPreferenceFolderExisting()))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", encoderSelect),
+		widget.NewFormItem("Existing Files", existingSelect),
+	}
+	items[0].HintText = "JPEG and TIFF use the export preferences"
+
+	dlg := dialog.NewForm("Export To Folder", "Choose Folder", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		encoder := imgutil.ImageEncoder(encoderSelect.SelectedIndex())
+		existing := imgutil.ExistingPolicy(existingSelect.SelectedIndex())
+		setPreferenceFolderEncoder(encoder)
+		setPreferenceFolderExisting(existing)
+
+		iApp.saveToFolder(imgutil.FolderOptions{
+			Encoder:         encoder,
+			TIFFCompression: getPreferenceTIFFCompression(),
+			PrependDigit:    getPreferencePrependDigit(),
+			Existing:        existing,
+		})
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(400, 250))
+	dlg.Show()
+}
+
+// saveToFolder exports the images as files in a chosen folder.
+func (iApp *ImgpackApp) saveToFolder(folderOpts imgutil.FolderOptions) {
+	dlg := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, iApp.mainWindow)
+			return
+		}
+
+		if dir == nil {
+			return
+		}
+
+		ctx := iApp.savingDlg.Start()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			opts := getPreferenceSaveOptions()
+			opts.Progress = iApp.savingDlg.SetProgress
+			opts.Book = filepath.Base(dir.Path())
+
+			result, err := imgutil.SaveImgsToFolderContext(ctx, imgs, dir.Path(), opts, folderOpts)
+			if errors.Is(err, context.Canceled) {
+				iApp.stateBar.SetText(fmt.Sprintf("Saving cancelled, %d files written", result.Written))
+				return
+			}
+
+			if err != nil {
+				dialog.ShowError(err, iApp.mainWindow)
+				return
+			}
+
+			iApp.stateBar.SetText(fmt.Sprintf("Saved %d files to %s, %d skipped, %d renamed",
+				result.Written, dir.Name(), result.Skipped, result.Renamed))
+		}()
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
@@ -0,0 +1,120 @@
+package imgpack
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// gifColorsOptions are the palette sizes that can be chosen for GIF export
+var gifColorsOptions = []string{"256", "128", "64", "32", "16"}
+
+// showGIFDialog asks the animation options,
+// then saves the images as the frames of an animated GIF.
+func (iApp *ImgpackApp) showGIFDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	delayEntry := widget.NewEntry()
+	delayEntry.SetText(strconv.Itoa(getPreferenceGIFDelay()))
+	delayEntry.Validator = func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return errors.New("delay must be a non-negative integer")
+		}
+		return nil
+	}
+
+	frameDelayCheck := widget.NewCheck("Keep the delay of imported GIF frames", nil)
+	frameDelayCheck.SetChecked(getPreferenceGIFFrameDelay())
+
+	loopEntry := widget.NewEntry()
+	loopEntry.SetText(strconv.Itoa(getPreferenceGIFLoopCount()))
+	loopEntry.Validator = func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < -1 {
+			return errors.New("loop count must be -1 or more")
+		}
+		return nil
+	}
+
+	colorsSelect := widget.NewSelect(gifColorsOptions, nil)
+	colorsSelect.SetSelected(strconv.Itoa(getPreferenceGIFColors()))
+	if colorsSelect.SelectedIndex() < 0 {
+		colorsSelect.SetSelectedIndex(0)
+	}
+
+	ditherCheck := widget.NewCheck("Dithering", nil)
+	ditherCheck.SetChecked(getPreferenceGIFDither())
+
+	resizeCheck := widget.NewCheck("Resize as set in the preference", nil)
+	resizeCheck.SetChecked(getPreferenceGIFResize())
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Delay (ms)", delayEntry),
+		widget.NewFormItem("", frameDelayCheck),
+		widget.NewFormItem("Loop Count", loopEntry),
+		widget.NewFormItem("Colors", colorsSelect),
+		widget.NewFormItem("", ditherCheck),
+		widget.NewFormItem("", resizeCheck),
+	}
+	items[2].HintText = "0 loops forever, -1 plays once"
+
+	dlg := dialog.NewForm("Save As Animated GIF", "Save", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		delay, _ := strconv.Atoi(delayEntry.Text)
+		loopCount, _ := strconv.Atoi(loopEntry.Text)
+		colors, _ := strconv.Atoi(colorsSelect.Selected)
+
+		setPreferenceGIFDelay(delay)
+		setPreferenceGIFFrameDelay(frameDelayCheck.Checked)
+		setPreferenceGIFLoopCount(loopCount)
+		setPreferenceGIFColors(colors)
+		setPreferenceGIFDither(ditherCheck.Checked)
+		setPreferenceGIFResize(resizeCheck.Checked)
+
+		iApp.saveGIF(imgutil.GIFOptions{
+			// the GIF delay is in 100ths of a second
+			Delay:         (delay + 5) / 10,
+			UseFrameDelay: frameDelayCheck.Checked,
+			LoopCount:     loopCount,
+			Colors:        colors,
+			Dither:        ditherCheck.Checked,
+		}, resizeCheck.Checked)
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(450, 400))
+	dlg.Show()
+}
+
+// saveGIF saves the images as the frames of an animated GIF with gifOpts,
+// the images are resized as set in the preference if resize is set.
+func (iApp *ImgpackApp) saveGIF(gifOpts imgutil.GIFOptions, resize bool) {
+	saveGIFFile("output.gif", func(f fyne.URIWriteCloser) {
+		ctx := iApp.savingDlg.Start()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			opts := getPreferenceSaveOptions()
+			opts.Progress = iApp.savingDlg.SetProgress
+			if !resize {
+				opts.Resize = imgutil.ResizeOptions{}
+			}
+
+			err := imgutil.SaveImgsAsGIFContext(ctx, imgs, f, opts, gifOpts)
+			iApp.finishSaving(f, err)
+		}()
+	}, iApp.mainWindow)
+}
@@ -0,0 +1,118 @@
+package imgpack
+
+import (
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// showHistoryWindow shows the edit operations of the selected image,
+// the operations can be reordered or removed and the changes are shown
+// in imgShow at once.
+func (iApp *ImgpackApp) showHistoryWindow() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	img := iApp.opTable.GetSelectedImg()
+	ops := img.Ops()
+	selected := -1
+
+	win := iApp.fApp.NewWindow(fmt.Sprintf("Edit History - %s", img.Filename))
+
+	opList := widget.NewList(
+		func() int {
+			return len(ops)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Operation")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(fmt.Sprintf("%d. %s", i+1, ops[i]))
+		},
+	)
+
+	var removeBtn *widget.Button
+
+	// a split cannot be removed since the other half is a separate image
+	opList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		if ops[id].Kind == imgutil.OpSplit {
+			removeBtn.Disable()
+		} else {
+			removeBtn.Enable()
+		}
+	}
+
+	opList.OnUnselected = func(id widget.ListItemID) {
+		selected = -1
+		removeBtn.Enable()
+	}
+
+	// setOps applies the operations to the image,
+	// the window is closed if another image was selected meanwhile
+	setOps := func(newOps []imgutil.Op, newSelected int) {
+		if iApp.opTable.GetSelectedImg() != img {
+			win.Close()
+			return
+		}
+
+		ops = newOps
+		iApp.opTable.SetOps(ops)
+
+		opList.Refresh()
+		if newSelected >= 0 && newSelected < len(ops) {
+			opList.Select(newSelected)
+		} else {
+			opList.UnselectAll()
+		}
+	}
+
+	swap := func(to int) {
+		if selected < 0 || to < 0 || to >= len(ops) {
+			return
+		}
+
+		newOps := slices.Clone(ops)
+		newOps[selected], newOps[to] = newOps[to], newOps[selected]
+		setOps(newOps, to)
+	}
+
+	moveUpBtn := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		swap(selected - 1)
+	})
+
+	moveDownBtn := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+		swap(selected + 1)
+	})
+
+	removeBtn = widget.NewButtonWithIcon("Remove", theme.DeleteIcon(), func() {
+		if selected < 0 || ops[selected].Kind == imgutil.OpSplit {
+			return
+		}
+
+		setOps(slices.Delete(slices.Clone(ops), selected, selected+1), selected)
+	})
+
+	resetBtn := widget.NewButtonWithIcon("Reset To Original", theme.ContentUndoIcon(), func() {
+		setOps(imgutil.ResetOps(ops), -1)
+	})
+
+	closeBtn := widget.NewButton("Close", func() {
+		win.Close()
+	})
+
+	win.SetContent(container.NewBorder(nil,
+		container.NewHBox(moveUpBtn, moveDownBtn, removeBtn, resetBtn,
+			layout.NewSpacer(), closeBtn),
+		nil, nil, opList))
+	win.Resize(fyne.NewSize(450, 350))
+	win.Show()
+}
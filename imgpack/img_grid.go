@@ -0,0 +1,244 @@
+package imgpack
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"slices"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/disintegration/imaging"
+
+	"github.com/VoileLab/goimgpack/imgpack/assets"
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+const (
+	minThumbnailSize = 64
+	maxThumbnailSize = 256
+)
+
+// thumbnail is a cached thumbnail of an image,
+// img is nil while the thumbnail is being generated
+type thumbnail struct {
+	img  image.Image
+	size int
+}
+
+// thumbnailer generates the thumbnails of images in the background
+// and caches them.
+type thumbnailer struct {
+	mu     sync.Mutex
+	thumbs map[*imgutil.Image]*thumbnail
+
+	// sem bounds the number of thumbnails generated concurrently
+	sem chan struct{}
+
+	// onReady is called when the thumbnail of img is generated
+	onReady func(img *imgutil.Image)
+
+	// waiters are called once with the thumbnail of their image
+	// when it is generated
+	waiters map[*imgutil.Image][]func(image.Image)
+}
+
+func newThumbnailer(onReady func(img *imgutil.Image)) *thumbnailer {
+	return &thumbnailer{
+		thumbs:  make(map[*imgutil.Image]*thumbnail),
+		sem:     make(chan struct{}, max(runtime.NumCPU()/2, 1)),
+		onReady: onReady,
+		waiters: make(map[*imgutil.Image][]func(image.Image)),
+	}
+}
+
+// Get returns the thumbnail of img which fits in size x size.
+// If the thumbnail is not ready yet, nil is returned and the thumbnail
+// is generated in the background.
+func (t *thumbnailer) Get(img *imgutil.Image, size int) image.Image {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.get(img, size)
+}
+
+// GetFunc calls f with the thumbnail of img which fits in size x size,
+// at once if it is ready and again when the one generated is ready.
+func (t *thumbnailer) GetFunc(img *imgutil.Image, size int, f func(image.Image)) {
+	t.mu.Lock()
+	thumbImg := t.get(img, size)
+	if t.thumbs[img].img == nil {
+		t.waiters[img] = append(t.waiters[img], f)
+	}
+	t.mu.Unlock()
+
+	if thumbImg != nil {
+		f(thumbImg)
+	}
+}
+
+// get is Get with t.mu held
+func (t *thumbnailer) get(img *imgutil.Image, size int) image.Image {
+	thumb, ok := t.thumbs[img]
+	if ok && thumb.size >= size {
+		return thumb.img
+	}
+
+	newThumb := &thumbnail{size: size}
+	t.thumbs[img] = newThumb
+	go t.generate(img, newThumb)
+
+	if ok {
+		// the smaller thumbnail is shown until the new one is ready
+		return thumb.img
+	}
+
+	return nil
+}
+
+func (t *thumbnailer) generate(img *imgutil.Image, thumb *thumbnail) {
+	t.sem <- struct{}{}
+	defer func() { <-t.sem }()
+
+	decoded, err := img.Img()
+	if err != nil {
+		decoded = assets.ImgPlaceholder
+	}
+
+	thumbImg := imaging.Fit(decoded, thumb.size, thumb.size, imaging.Linear)
+
+	t.mu.Lock()
+	current := t.thumbs[img] == thumb
+	var waiters []func(image.Image)
+	if current {
+		thumb.img = thumbImg
+		waiters = t.waiters[img]
+		delete(t.waiters, img)
+	}
+	t.mu.Unlock()
+
+	// the image was changed while its thumbnail was generated
+	if !current {
+		return
+	}
+
+	t.onReady(img)
+	for _, f := range waiters {
+		f(thumbImg)
+	}
+}
+
+// Invalidate drops the thumbnail of img, it is generated again on next Get.
+func (t *thumbnailer) Invalidate(img *imgutil.Image) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.thumbs, img)
+}
+
+// Retain drops the thumbnails of the images not in imgs.
+func (t *thumbnailer) Retain(imgs []*imgutil.Image) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for img := range t.thumbs {
+		if !slices.Contains(imgs, img) {
+			delete(t.thumbs, img)
+			delete(t.waiters, img)
+		}
+	}
+}
+
+// newThumbnailImage creates an image showing the thumbnail of img
+// which fits in size x size, the placeholder is shown until it is ready.
+func (iApp *ImgpackApp) newThumbnailImage(img *imgutil.Image, size int) *canvas.Image {
+	thumbImg := canvas.NewImageFromImage(assets.ImgPlaceholder)
+	thumbImg.FillMode = canvas.ImageFillContain
+	thumbImg.SetMinSize(fyne.NewSize(float32(size), float32(size)))
+
+	iApp.thumbnailer.GetFunc(img, size, func(thumb image.Image) {
+		thumbImg.Image = thumb
+		thumbImg.Refresh()
+	})
+
+	return thumbImg
+}
+
+// setupImgGrid creates the thumbnail grid of the images
+// with a slider adjusting the thumbnail size.
+func (iApp *ImgpackApp) setupImgGrid() fyne.CanvasObject {
+	iApp.thumbnailer = newThumbnailer(func(img *imgutil.Image) {
+		idx := slices.Index(iApp.opTable.GetImgs(), img)
+		if idx >= 0 {
+			iApp.imgGridWidget.RefreshItem(idx)
+		}
+	})
+
+	gridDragGroup := iApp.newImgDragGroup()
+	iApp.gridDragGroup = gridDragGroup
+
+	imgGridWidget := widget.NewGridWrap(
+		func() int {
+			return iApp.opTable.Len()
+		},
+		func() fyne.CanvasObject {
+			size := float32(getPreferenceThumbnailSize())
+
+			thumb := canvas.NewImageFromImage(assets.ImgPlaceholder)
+			thumb.FillMode = canvas.ImageFillContain
+			thumb.SetMinSize(fyne.NewSize(size, size))
+
+			label := widget.NewLabel("Item")
+			label.Alignment = fyne.TextAlignCenter
+			label.Truncation = fyne.TextTruncateEllipsis
+
+			return gridDragGroup.NewItem(container.NewBorder(nil, label, nil, nil, thumb))
+		},
+		func(i widget.GridWrapItemID, o fyne.CanvasObject) {
+			img := iApp.opTable.Get(i)
+
+			item := o.(*dragItem)
+			gridDragGroup.Bind(item, i)
+			item.SetInSelection(iApp.opTable.InSelection(i))
+
+			objs := item.content.(*fyne.Container).Objects
+			thumb := objs[0].(*canvas.Image)
+			label := objs[1].(*widget.Label)
+
+			label.SetText(imgLabel(img))
+
+			thumb.Image = iApp.thumbnailer.Get(img, getPreferenceThumbnailSize())
+			if thumb.Image == nil {
+				thumb.Image = assets.ImgPlaceholder
+			}
+			thumb.Refresh()
+		},
+	)
+
+	imgGridWidget.OnSelected = func(id widget.GridWrapItemID) {
+		iApp.selectImg(int(id))
+	}
+
+	iApp.imgGridWidget = imgGridWidget
+	gridDragGroup.view = imgGridWidget
+
+	sizeLabel := widget.NewLabel(fmt.Sprintf("%dpx", getPreferenceThumbnailSize()))
+
+	sizeSlider := widget.NewSlider(minThumbnailSize, maxThumbnailSize)
+	sizeSlider.Step = 16
+	sizeSlider.Value = float64(getPreferenceThumbnailSize())
+	sizeSlider.OnChangeEnded = func(v float64) {
+		setPreferenceThumbnailSize(int(v))
+		imgGridWidget.Refresh()
+	}
+	sizeSlider.OnChanged = func(v float64) {
+		sizeLabel.SetText(fmt.Sprintf("%dpx", int(v)))
+	}
+
+	return container.NewBorder(
+		container.NewBorder(nil, nil, nil, sizeLabel, sizeSlider),
+		nil, nil, nil, imgGridWidget)
+}
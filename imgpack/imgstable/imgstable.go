@@ -5,13 +5,15 @@ import (
 	"slices"
 
 	"github.com/VoileLab/goimgpack/internal/imgutil"
-	"github.com/disintegration/imaging"
 )
 
 type ImgsTable struct {
 	selIdx *int
 	imgs   []*imgutil.Image
 
+	// selMore are the images selected along with the selected image
+	selMore map[*imgutil.Image]bool
+
 	onSelectIndexChange func()
 	onSelectImageChange func()
 	onListChange        func()
@@ -19,6 +21,7 @@ type ImgsTable struct {
 
 func New() *ImgsTable {
 	return &ImgsTable{
+		selMore:             make(map[*imgutil.Image]bool),
 		onSelectIndexChange: func() {},
 		onSelectImageChange: func() {},
 		onListChange:        func() {},
@@ -61,11 +64,49 @@ func (t *ImgsTable) GetImgs() []*imgutil.Image {
 	return t.imgs
 }
 
+// Select selects the image at idx alone.
 func (t *ImgsTable) Select(idx int) {
 	if idx < 0 || idx >= len(t.imgs) {
 		return
 	}
 
+	clear(t.selMore)
+	t.selectIdx(idx)
+}
+
+// SelectMore adds the image at idx to the selection
+// and makes it the selected image.
+func (t *ImgsTable) SelectMore(idx int) {
+	if idx < 0 || idx >= len(t.imgs) {
+		return
+	}
+
+	if t.selIdx != nil {
+		t.selMore[t.imgs[*t.selIdx]] = true
+	}
+	t.selectIdx(idx)
+}
+
+// SelectRange adds the images from the selected image to idx
+// to the selection and makes the image at idx the selected image.
+func (t *ImgsTable) SelectRange(idx int) {
+	if idx < 0 || idx >= len(t.imgs) {
+		return
+	}
+
+	if t.selIdx == nil {
+		t.Select(idx)
+		return
+	}
+
+	lo, hi := min(*t.selIdx, idx), max(*t.selIdx, idx)
+	for _, img := range t.imgs[lo : hi+1] {
+		t.selMore[img] = true
+	}
+	t.selectIdx(idx)
+}
+
+func (t *ImgsTable) selectIdx(idx int) {
 	preIdx := t.selIdx
 	t.selIdx = &idx
 
@@ -74,6 +115,24 @@ func (t *ImgsTable) Select(idx int) {
 	}
 }
 
+// InSelection reports whether the image at idx is selected,
+// either as the selected image or along with it.
+func (t *ImgsTable) InSelection(idx int) bool {
+	return (t.selIdx != nil && *t.selIdx == idx) || t.selMore[t.imgs[idx]]
+}
+
+// GetSelectedImgs returns the selected images in the order of the table.
+func (t *ImgsTable) GetSelectedImgs() []*imgutil.Image {
+	var imgs []*imgutil.Image
+	for i, img := range t.imgs {
+		if t.InSelection(i) {
+			imgs = append(imgs, img)
+		}
+	}
+
+	return imgs
+}
+
 func (t *ImgsTable) IsSelected() bool {
 	return t.selIdx != nil
 }
@@ -93,6 +152,7 @@ func (t *ImgsTable) GetSelectedImg() *imgutil.Image {
 func (t *ImgsTable) Unselect() {
 	preIdx := t.selIdx
 	t.selIdx = nil
+	clear(t.selMore)
 
 	if preIdx != nil {
 		t.onSelectIndexChange()
@@ -103,6 +163,7 @@ func (t *ImgsTable) Unselect() {
 func (t *ImgsTable) Insert(imgs ...*imgutil.Image) {
 	if t.selIdx == nil {
 		t.imgs = append(t.imgs, imgs...)
+		t.onListChange()
 		return
 	}
 
@@ -126,6 +187,7 @@ func (t *ImgsTable) Delete() {
 	}
 
 	idx := *t.selIdx
+	delete(t.selMore, t.imgs[idx])
 	t.imgs = slices.Delete(t.imgs, idx, idx+1)
 	t.onListChange()
 
@@ -134,6 +196,38 @@ func (t *ImgsTable) Delete() {
 	}
 }
 
+// DeleteImgs removes the given images from the table,
+// the selection stays on the same image if it is not removed.
+func (t *ImgsTable) DeleteImgs(imgs ...*imgutil.Image) {
+	var selImg *imgutil.Image
+	if t.selIdx != nil {
+		selImg = t.imgs[*t.selIdx]
+	}
+
+	t.imgs = slices.DeleteFunc(t.imgs, func(img *imgutil.Image) bool {
+		return slices.Contains(imgs, img)
+	})
+	for _, img := range imgs {
+		delete(t.selMore, img)
+	}
+	t.onListChange()
+
+	if selImg == nil {
+		return
+	}
+
+	idx := slices.Index(t.imgs, selImg)
+	if idx < 0 {
+		t.Unselect()
+		return
+	}
+
+	if idx != *t.selIdx {
+		t.selIdx = &idx
+		t.onSelectIndexChange()
+	}
+}
+
 // Duplicate duplicates the selected image.
 func (t *ImgsTable) Duplicate() {
 	if t.selIdx == nil {
@@ -144,6 +238,7 @@ func (t *ImgsTable) Duplicate() {
 	img := t.imgs[idx]
 
 	newImg := img.Clone()
+	newImg.SplitBefore = false
 
 	t.imgs = slices.Insert(t.imgs, idx+1, newImg)
 	t.onListChange()
@@ -193,9 +288,7 @@ func (t *ImgsTable) Rotate() {
 		return
 	}
 
-	img := t.imgs[*t.selIdx]
-	img.Img = imaging.Rotate90(img.Img)
-
+	t.imgs[*t.selIdx].AddOp(imgutil.Op{Kind: imgutil.OpRotate})
 	t.onSelectImageChange()
 }
 
@@ -209,27 +302,161 @@ func (t *ImgsTable) Cut() {
 	idx := *t.selIdx
 	img := t.imgs[idx]
 	filename := img.Filename
-	imgType := img.Type
 
-	imgWidth := img.Img.Bounds().Dx()
-	imgHeight := img.Img.Bounds().Dy()
+	newImg := img.Clone()
+	newImg.Filename = filename + "_2"
+	newImg.SplitBefore = false
+	newImg.AddOp(imgutil.Op{Kind: imgutil.OpSplit, Part: 2})
 
-	spWidth := imgWidth / 2
+	img.Filename = filename + "_1"
+	img.AddOp(imgutil.Op{Kind: imgutil.OpSplit, Part: 1})
 
-	img1 := imaging.Crop(img.Img, image.Rect(0, 0, spWidth, imgHeight))
-	img2 := imaging.Crop(img.Img, image.Rect(spWidth, 0, imgWidth, imgHeight))
+	t.imgs = slices.Insert(t.imgs, idx+1, newImg)
 
-	img.Filename = filename + "_1"
-	img.Img = img1
+	t.onSelectImageChange()
+	t.onListChange()
+}
 
-	newImg := &imgutil.Image{
-		Filename: filename + "_2",
-		Img:      img2,
-		Type:     imgType,
+// Adjust applies the tonal adjustment to the selected image.
+func (t *ImgsTable) Adjust(adj imgutil.Adjustment) {
+	if t.selIdx == nil || adj.IsZero() {
+		return
 	}
 
-	t.imgs = slices.Insert(t.imgs, idx+1, newImg)
+	t.imgs[*t.selIdx].AddOp(imgutil.NewAdjustOp(adj))
+	t.onSelectImageChange()
+}
 
+// Crop crops the selected image to rect,
+// which is in the coordinates of the image as it is shown.
+func (t *ImgsTable) Crop(rect image.Rectangle) {
+	if t.selIdx == nil {
+		return
+	}
+
+	img := t.imgs[*t.selIdx]
+	bounds := img.Bounds()
+	rect = rect.Intersect(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if rect.Empty() || rect.Size() == bounds.Size() {
+		return
+	}
+
+	img.AddOp(imgutil.NewCropOp(rect))
+	t.onSelectImageChange()
+}
+
+// SetOps replaces the edit operations of the selected image.
+func (t *ImgsTable) SetOps(ops []imgutil.Op) {
+	if t.selIdx == nil {
+		return
+	}
+
+	t.imgs[*t.selIdx].SetOps(ops)
+	t.onSelectImageChange()
+}
+
+// ToggleSplit toggles the volume split marker of the selected image.
+func (t *ImgsTable) ToggleSplit() {
+	if t.selIdx == nil {
+		return
+	}
+
+	img := t.imgs[*t.selIdx]
+	img.SplitBefore = !img.SplitBefore
+	t.onListChange()
+}
+
+// Rename sets the filenames of the images to names.
+func (t *ImgsTable) Rename(imgs []*imgutil.Image, names []string) {
+	for i, img := range imgs {
+		img.Filename = names[i]
+	}
+	t.onListChange()
+
+	if t.selIdx != nil && slices.Contains(imgs, t.imgs[*t.selIdx]) {
+		t.onSelectImageChange()
+	}
+}
+
+// ReplaceImg replaces the image old with img wherever it is now,
+// it returns false if old is not in the table anymore.
+func (t *ImgsTable) ReplaceImg(old, img *imgutil.Image) bool {
+	idx := slices.Index(t.imgs, old)
+	if idx < 0 {
+		return false
+	}
+
+	t.imgs[idx] = img
+	if t.selMore[old] {
+		delete(t.selMore, old)
+		t.selMore[img] = true
+	}
+
+	t.onListChange()
+	if t.selIdx != nil && *t.selIdx == idx {
+		t.onSelectImageChange()
+	}
+
+	return true
+}
+
+// Reset removes the edit operations of the selected image except its splits.
+func (t *ImgsTable) Reset() {
+	if t.selIdx == nil {
+		return
+	}
+
+	t.imgs[*t.selIdx].Reset()
 	t.onSelectImageChange()
+}
+
+// MoveTo moves the image at index from to index to,
+// the selection stays on the same image.
+func (t *ImgsTable) MoveTo(from, to int) {
+	if from < 0 || from >= len(t.imgs) || to < 0 || to >= len(t.imgs) || from == to {
+		return
+	}
+
+	t.MoveImgsTo([]*imgutil.Image{t.imgs[from]}, to)
+}
+
+// MoveImgsTo moves the images together in the order of the table,
+// so that the first of them is at index to, or as near as possible.
+// The selection stays on the same image.
+func (t *ImgsTable) MoveImgsTo(imgs []*imgutil.Image, to int) {
+	var moved, rest []*imgutil.Image
+	for _, img := range t.imgs {
+		if slices.Contains(imgs, img) {
+			moved = append(moved, img)
+		} else {
+			rest = append(rest, img)
+		}
+	}
+
+	if len(moved) == 0 {
+		return
+	}
+
+	to = min(max(to, 0), len(rest))
+	newImgs := slices.Concat(rest[:to], moved, rest[to:])
+	if slices.Equal(newImgs, t.imgs) {
+		return
+	}
+
+	var selImg *imgutil.Image
+	if t.selIdx != nil {
+		selImg = t.imgs[*t.selIdx]
+	}
+
+	t.imgs = newImgs
+
+	if selImg != nil {
+		idx := slices.Index(t.imgs, selImg)
+		if idx != *t.selIdx {
+			t.selIdx = &idx
+			t.onSelectIndexChange()
+		}
+	}
+
 	t.onListChange()
 }
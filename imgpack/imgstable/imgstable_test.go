@@ -0,0 +1,165 @@
+package imgstable
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// newTestTable creates a table of images named by the letters of names,
+// with the image at sel selected, and counts the callbacks
+func newTestTable(names string, sel int) (table *ImgsTable, listChanges, indexChanges *int) {
+	table = New()
+	for _, name := range names {
+		table.Insert(&imgutil.Image{Filename: string(name)})
+	}
+	if sel >= 0 {
+		table.Select(sel)
+	}
+
+	listChanges, indexChanges = new(int), new(int)
+	table.SetOnListChange(func() { *listChanges++ })
+	table.SetOnSelectIndexChange(func() { *indexChanges++ })
+
+	return table, listChanges, indexChanges
+}
+
+// tableNames returns the names of the images of the table
+func tableNames(table *ImgsTable) string {
+	var names strings.Builder
+	for _, img := range table.GetImgs() {
+		names.WriteString(img.Filename)
+	}
+	return names.String()
+}
+
+func TestMoveTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		sel      int
+		want     string
+		wantSel  int
+	}{
+		{"down", 1, 3, 1, "acdbe", 3},
+		{"up", 3, 0, 3, "dabce", 0},
+		{"to the end", 0, 4, 2, "bcdea", 1},
+		{"other image down", 0, 2, 1, "bcade", 0},
+		{"other image up", 4, 1, 2, "aebcd", 3},
+		{"no selection", 2, 0, -1, "cabde", -1},
+		{"same index", 2, 2, 2, "abcde", 2},
+		{"to out of range", 1, 5, 1, "abcde", 1},
+		{"negative to", 1, -1, 1, "abcde", 1},
+		{"from out of range", 5, 0, 1, "abcde", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, listChanges, indexChanges := newTestTable("abcde", tt.sel)
+			table.MoveTo(tt.from, tt.to)
+
+			if got := tableNames(table); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+
+			changed := tt.want != "abcde"
+			if (*listChanges == 1) != changed || *listChanges > 1 {
+				t.Errorf("the list change is reported %d times", *listChanges)
+			}
+
+			if tt.wantSel < 0 {
+				if table.IsSelected() {
+					t.Errorf("got selected index %d", table.GetSelectedIdx())
+				}
+				return
+			}
+			if got := table.GetSelectedIdx(); got != tt.wantSel {
+				t.Errorf("got selected index %d, want %d", got, tt.wantSel)
+			}
+			if (*indexChanges == 1) != (tt.sel != tt.wantSel) || *indexChanges > 1 {
+				t.Errorf("the index change is reported %d times", *indexChanges)
+			}
+		})
+	}
+}
+
+func TestMoveImgsTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		moved   string
+		to      int
+		sel     int
+		want    string
+		wantSel int
+	}{
+		{"to the front", "bd", 0, 3, "bdace", 1},
+		{"to the end", "ac", 3, 0, "bdeac", 3},
+		{"into the middle", "ae", 1, 4, "baecd", 2},
+		{"in the order of the table", "eb", 0, 1, "beacd", 0},
+		{"to out of range", "ab", 10, 2, "cdeab", 0},
+		{"negative to", "de", -3, 0, "deabc", 2},
+		{"already there", "bc", 1, 2, "abcde", 2},
+		{"all images", "abcde", 2, 4, "abcde", 4},
+		{"nothing", "", 0, 1, "abcde", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, listChanges, indexChanges := newTestTable("abcde", tt.sel)
+
+			var moved []*imgutil.Image
+			for _, img := range table.GetImgs() {
+				if strings.Contains(tt.moved, img.Filename) {
+					moved = append(moved, img)
+				}
+			}
+			// the order of the images given does not matter
+			slices.Reverse(moved)
+
+			table.MoveImgsTo(moved, tt.to)
+
+			if got := tableNames(table); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if got := table.GetSelectedIdx(); got != tt.wantSel {
+				t.Errorf("got selected index %d, want %d", got, tt.wantSel)
+			}
+
+			if (*listChanges == 1) != (tt.want != "abcde") || *listChanges > 1 {
+				t.Errorf("the list change is reported %d times", *listChanges)
+			}
+			if (*indexChanges == 1) != (tt.sel != tt.wantSel) || *indexChanges > 1 {
+				t.Errorf("the index change is reported %d times", *indexChanges)
+			}
+		})
+	}
+}
+
+func TestMoveImgsToSelection(t *testing.T) {
+	table, _, _ := newTestTable("abcdef", 1)
+	table.SelectMore(3)
+	table.SelectMore(4)
+
+	// the selected images move together and stay selected
+	table.MoveImgsTo(table.GetSelectedImgs(), 0)
+
+	if got := tableNames(table); got != "bdeacf" {
+		t.Errorf("got %s, want bdeacf", got)
+	}
+	if got := table.GetSelectedIdx(); got != 2 {
+		t.Errorf("got selected index %d, want 2", got)
+	}
+	for i := range table.Len() {
+		if table.InSelection(i) != (i < 3) {
+			t.Errorf("the selection of image %d is %v", i, table.InSelection(i))
+		}
+	}
+
+	// images which are not in the table are ignored
+	table.MoveImgsTo([]*imgutil.Image{{Filename: "x"}, table.Get(5)}, 0)
+	if got := tableNames(table); got != "fbdeac" {
+		t.Errorf("got %s, want fbdeac", got)
+	}
+}
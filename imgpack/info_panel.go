@@ -0,0 +1,104 @@
+package imgpack
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// infoPanelWidth is the minimum width of the info panel
+const infoPanelWidth = 260
+
+// setupInfoPanel creates the panel describing the selected image
+// and its metadata, which is shown next to the preview.
+func (iApp *ImgpackApp) setupInfoPanel() fyne.CanvasObject {
+	iApp.infoGrid = container.New(layout.NewFormLayout())
+
+	scroll := container.NewVScroll(iApp.infoGrid)
+	scroll.SetMinSize(fyne.NewSize(infoPanelWidth, 0))
+	iApp.infoPane = scroll
+
+	return scroll
+}
+
+// setInfoPanel shows or hides the info panel.
+func (iApp *ImgpackApp) setInfoPanel(show bool) {
+	setPreferenceInfoPanel(show)
+
+	if !show {
+		iApp.infoPane.Hide()
+		return
+	}
+
+	iApp.infoPane.Show()
+	iApp.updateInfoPanel()
+}
+
+// updateInfoPanel lists the properties and the metadata of the selected image.
+func (iApp *ImgpackApp) updateInfoPanel() {
+	if !iApp.infoPane.Visible() {
+		return
+	}
+
+	var objects []fyne.CanvasObject
+	add := func(name, value string) {
+		valueLabel := widget.NewLabel(value)
+		valueLabel.Wrapping = fyne.TextWrapWord
+		objects = append(objects, widget.NewLabelWithStyle(name, fyne.TextAlignLeading,
+			fyne.TextStyle{Bold: true}), valueLabel)
+	}
+
+	if iApp.opTable.IsSelected() {
+		img := iApp.opTable.GetSelectedImg()
+		bound := img.Bounds()
+
+		add("Filename", img.Filename)
+		add("Format", img.Type)
+		add("Size", fmt.Sprintf("%dx%d", bound.Dx(), bound.Dy()))
+		if img.Color != "" {
+			add("Color", img.Color)
+		}
+		if img.Frames > 1 {
+			add("Frames", fmt.Sprint(img.Frames))
+		}
+		if img.IsEdited() {
+			add("Edits", fmt.Sprint(len(img.Ops())))
+		}
+
+		prov := img.Provenance
+		if prov.IsKnown() {
+			add("Source", prov.Path)
+		}
+		if prov.Entry != "" {
+			add("Entry", prov.Entry)
+		}
+		if prov.Page > 0 {
+			add("Page", fmt.Sprint(prov.Page))
+		}
+		if prov.Object > 0 {
+			add("PDF Object", fmt.Sprint(prov.Object))
+		}
+		if prov.Frame > 0 {
+			add("Frame", fmt.Sprint(prov.Frame))
+		}
+		if prov.Size > 0 {
+			add("Original Size", util.FormatSize(prov.Size))
+		}
+
+		fields := img.Metadata().Fields()
+		if len(fields) == 0 {
+			add("Metadata", "None")
+		}
+		for _, field := range fields {
+			add(field.Name, field.Value)
+		}
+	}
+
+	iApp.infoGrid.Objects = objects
+	iApp.infoGrid.Refresh()
+}
@@ -1,12 +1,75 @@
 package imgpack
 
 import (
+	"runtime"
+
 	"fyne.io/fyne/v2"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
 )
 
 const (
 	PreferencePrependDigitKey = "prepend_digit"
 	PreferenceJPGQualityKey   = "jpg_quality"
+	PreferencePassthroughKey  = "passthrough"
+	PreferenceMetadataModeKey = "metadata_mode"
+	PreferenceEmbedSRGBKey    = "embed_srgb"
+
+	PreferenceEntryNameTemplateKey = "entry_name_template"
+	PreferenceRenameTemplateKey    = "rename_template"
+
+	PreferenceResizeModeKey      = "resize_mode"
+	PreferenceResizeMaxWidthKey  = "resize_max_width"
+	PreferenceResizeMaxHeightKey = "resize_max_height"
+	PreferenceResizeWidthKey     = "resize_width"
+	PreferenceResizePercentKey   = "resize_percent"
+	PreferenceResizeFilterKey    = "resize_filter"
+	PreferenceResizeSharpenKey   = "resize_sharpen"
+
+	PreferenceExpandGIFFramesKey = "expand_gif_frames"
+
+	PreferenceSVGDPIKey        = "svg_dpi"
+	PreferenceSVGWidthKey      = "svg_width"
+	PreferenceSVGBackgroundKey = "svg_background"
+
+	PreferenceDecodeWorkersKey = "decode_workers"
+	PreferenceEncodeWorkersKey = "encode_workers"
+
+	PreferenceCacheBudgetKey = "cache_budget"
+	PreferenceDiskStoreKey   = "disk_store"
+
+	PreferenceGridViewKey      = "grid_view"
+	PreferenceThumbnailSizeKey = "thumbnail_size"
+	PreferenceInfoPanelKey     = "info_panel"
+
+	PreferenceTargetSizeKey      = "target_size"
+	PreferenceTargetDownscaleKey = "target_downscale"
+
+	PreferenceGIFDelayKey      = "gif_delay"
+	PreferenceGIFFrameDelayKey = "gif_frame_delay"
+	PreferenceGIFLoopCountKey  = "gif_loop_count"
+	PreferenceGIFColorsKey     = "gif_colors"
+	PreferenceGIFDitherKey     = "gif_dither"
+	PreferenceGIFResizeKey     = "gif_resize"
+
+	PreferenceTIFFCompressionKey = "tiff_compression"
+
+	PreferenceFolderEncoderKey  = "folder_encoder"
+	PreferenceFolderExistingKey = "folder_existing"
+
+	PreferenceVolumeFormatKey   = "volume_format"
+	PreferenceVolumeExistingKey = "volume_existing"
+	PreferenceSplitModeKey      = "split_mode"
+	PreferenceSplitPagesKey     = "split_pages"
+	PreferenceSplitMaxSizeKey   = "split_max_size"
+
+	PreferenceDuplicateMethodKey    = "duplicate_method"
+	PreferenceDuplicateThresholdKey = "duplicate_threshold"
+
+	PreferenceBlankInkThresholdKey = "blank_ink_threshold"
+	PreferenceBlankMarginKey       = "blank_margin"
+	PreferenceBlankMaxCoverageKey  = "blank_max_coverage"
+	PreferenceBlankMaxDeviationKey = "blank_max_deviation"
 )
 
 func getPreferencePrependDigit() bool {
@@ -25,6 +88,470 @@ func setPreferenceJPGQuality(value int) {
 	fyne.CurrentApp().Preferences().SetInt(PreferenceJPGQualityKey, value)
 }
 
+func getPreferencePassthrough() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferencePassthroughKey, false)
+}
+
+func setPreferencePassthrough(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferencePassthroughKey, value)
+}
+
+func getPreferenceMetadataMode() imgutil.MetadataMode {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceMetadataModeKey, 0)
+	if value < 0 || value >= len(imgutil.MetadataModeNames) {
+		return imgutil.MetadataStrip
+	}
+
+	return imgutil.MetadataMode(value)
+}
+
+func setPreferenceMetadataMode(value imgutil.MetadataMode) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceMetadataModeKey, int(value))
+}
+
+func getPreferenceEmbedSRGB() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceEmbedSRGBKey, false)
+}
+
+func setPreferenceEmbedSRGB(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceEmbedSRGBKey, value)
+}
+
+// getPreferenceEntryNameTemplate returns the template of the entry names
+// of the archives, empty means the filenames of the images.
+func getPreferenceEntryNameTemplate() string {
+	return fyne.CurrentApp().Preferences().StringWithFallback(PreferenceEntryNameTemplateKey, "")
+}
+
+func setPreferenceEntryNameTemplate(value string) {
+	fyne.CurrentApp().Preferences().SetString(PreferenceEntryNameTemplateKey, value)
+}
+
+// getPreferenceEntryNames returns the parsed template of the entry names
+// of the archives, nil if it is empty or invalid.
+func getPreferenceEntryNames() *imgutil.NameTemplate {
+	tmpl, err := imgutil.ParseNameTemplate(getPreferenceEntryNameTemplate())
+	if err != nil {
+		return nil
+	}
+
+	return tmpl
+}
+
+func getPreferenceRenameTemplate() string {
+	return fyne.CurrentApp().Preferences().StringWithFallback(PreferenceRenameTemplateKey, "{book}_{n:03}")
+}
+
+func setPreferenceRenameTemplate(value string) {
+	fyne.CurrentApp().Preferences().SetString(PreferenceRenameTemplateKey, value)
+}
+
+func getPreferenceResizeMode() imgutil.ResizeMode {
+	return imgutil.ResizeMode(fyne.CurrentApp().Preferences().IntWithFallback(
+		PreferenceResizeModeKey, int(imgutil.ResizeNone)))
+}
+
+func setPreferenceResizeMode(value imgutil.ResizeMode) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceResizeModeKey, int(value))
+}
+
+func getPreferenceResizeMaxWidth() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceResizeMaxWidthKey, 1600)
+}
+
+func setPreferenceResizeMaxWidth(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceResizeMaxWidthKey, value)
+}
+
+func getPreferenceResizeMaxHeight() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceResizeMaxHeightKey, 2400)
+}
+
+func setPreferenceResizeMaxHeight(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceResizeMaxHeightKey, value)
+}
+
+func getPreferenceResizeWidth() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceResizeWidthKey, 1600)
+}
+
+func setPreferenceResizeWidth(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceResizeWidthKey, value)
+}
+
+func getPreferenceResizePercent() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceResizePercentKey, 50)
+}
+
+func setPreferenceResizePercent(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceResizePercentKey, value)
+}
+
+func getPreferenceResizeFilter() string {
+	return fyne.CurrentApp().Preferences().StringWithFallback(
+		PreferenceResizeFilterKey, imgutil.ResampleFilterNames[0])
+}
+
+func setPreferenceResizeFilter(value string) {
+	fyne.CurrentApp().Preferences().SetString(PreferenceResizeFilterKey, value)
+}
+
+func getPreferenceResizeSharpen() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceResizeSharpenKey, 0)
+}
+
+func setPreferenceResizeSharpen(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceResizeSharpenKey, value)
+}
+
+func getPreferenceDecodeWorkers() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(
+		PreferenceDecodeWorkersKey, runtime.NumCPU())
+}
+
+func setPreferenceDecodeWorkers(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceDecodeWorkersKey, value)
+}
+
+func getPreferenceEncodeWorkers() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(
+		PreferenceEncodeWorkersKey, runtime.NumCPU())
+}
+
+func setPreferenceEncodeWorkers(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceEncodeWorkersKey, value)
+}
+
+// getPreferenceCacheBudget returns the memory budget of decoded images in MB.
+func getPreferenceCacheBudget() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceCacheBudgetKey, 1024)
+}
+
+func setPreferenceCacheBudget(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceCacheBudgetKey, value)
+	imgutil.SetCacheBudget(int64(value) << 20)
+}
+
+func getPreferenceDiskStore() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceDiskStoreKey, true)
+}
+
+func setPreferenceDiskStore(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceDiskStoreKey, value)
+	imgutil.UseDiskStore(value)
+}
+
+func getPreferenceGridView() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceGridViewKey, false)
+}
+
+func setPreferenceGridView(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceGridViewKey, value)
+}
+
+func getPreferenceInfoPanel() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceInfoPanelKey, false)
+}
+
+func setPreferenceInfoPanel(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceInfoPanelKey, value)
+}
+
+func getPreferenceThumbnailSize() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceThumbnailSizeKey, 128)
+}
+
+func setPreferenceThumbnailSize(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceThumbnailSizeKey, value)
+}
+
+func getPreferenceExpandGIFFrames() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceExpandGIFFramesKey, false)
+}
+
+func setPreferenceExpandGIFFrames(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceExpandGIFFramesKey, value)
+}
+
+func getPreferenceSVGDPI() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceSVGDPIKey, 150)
+}
+
+func setPreferenceSVGDPI(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceSVGDPIKey, value)
+}
+
+// getPreferenceSVGWidth returns the width of rasterized SVG images,
+// zero means the width follows the DPI.
+func getPreferenceSVGWidth() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceSVGWidthKey, 0)
+}
+
+func setPreferenceSVGWidth(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceSVGWidthKey, value)
+}
+
+// getPreferenceSVGBackground returns the name of the background
+// of rasterized SVG images, one of svgBackgroundNames.
+func getPreferenceSVGBackground() string {
+	return fyne.CurrentApp().Preferences().StringWithFallback(
+		PreferenceSVGBackgroundKey, svgBackgroundNames[0])
+}
+
+func setPreferenceSVGBackground(value string) {
+	fyne.CurrentApp().Preferences().SetString(PreferenceSVGBackgroundKey, value)
+}
+
+// getPreferenceReadOptions collects the import options from the preference.
+func getPreferenceReadOptions() imgutil.ReadOptions {
+	return imgutil.ReadOptions{
+		Workers:         getPreferenceDecodeWorkers(),
+		ExpandGIFFrames: getPreferenceExpandGIFFrames(),
+		SVG: imgutil.SVGOptions{
+			DPI:        float64(getPreferenceSVGDPI()),
+			Width:      getPreferenceSVGWidth(),
+			Background: svgBackgrounds[getPreferenceSVGBackground()],
+		},
+	}
+}
+
+// getPreferenceTargetSize returns the last target size of export in MB.
+func getPreferenceTargetSize() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceTargetSizeKey, 20)
+}
+
+func setPreferenceTargetSize(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceTargetSizeKey, value)
+}
+
+func getPreferenceTargetDownscale() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceTargetDownscaleKey, false)
+}
+
+func setPreferenceTargetDownscale(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceTargetDownscaleKey, value)
+}
+
+// getPreferenceGIFDelay returns the delay of the frames of GIF export in ms.
+func getPreferenceGIFDelay() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceGIFDelayKey, 100)
+}
+
+func setPreferenceGIFDelay(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceGIFDelayKey, value)
+}
+
+func getPreferenceGIFFrameDelay() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceGIFFrameDelayKey, true)
+}
+
+func setPreferenceGIFFrameDelay(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceGIFFrameDelayKey, value)
+}
+
+func getPreferenceGIFLoopCount() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceGIFLoopCountKey, 0)
+}
+
+func setPreferenceGIFLoopCount(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceGIFLoopCountKey, value)
+}
+
+func getPreferenceGIFColors() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceGIFColorsKey, 256)
+}
+
+func setPreferenceGIFColors(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceGIFColorsKey, value)
+}
+
+func getPreferenceGIFDither() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceGIFDitherKey, true)
+}
+
+func setPreferenceGIFDither(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceGIFDitherKey, value)
+}
+
+func getPreferenceGIFResize() bool {
+	return fyne.CurrentApp().Preferences().BoolWithFallback(PreferenceGIFResizeKey, true)
+}
+
+func setPreferenceGIFResize(value bool) {
+	fyne.CurrentApp().Preferences().SetBool(PreferenceGIFResizeKey, value)
+}
+
+func getPreferenceTIFFCompression() imgutil.TIFFCompression {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceTIFFCompressionKey, 0)
+	if value < 0 || value >= len(imgutil.TIFFCompressionNames) {
+		return imgutil.TIFFCompressionLZW
+	}
+
+	return imgutil.TIFFCompression(value)
+}
+
+func setPreferenceTIFFCompression(value imgutil.TIFFCompression) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceTIFFCompressionKey, int(value))
+}
+
+func getPreferenceFolderEncoder() imgutil.ImageEncoder {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceFolderEncoderKey, 0)
+	if value < 0 || value >= len(imgutil.ImageEncoderNames) {
+		return imgutil.EncoderJPEG
+	}
+
+	return imgutil.ImageEncoder(value)
+}
+
+func setPreferenceFolderEncoder(value imgutil.ImageEncoder) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceFolderEncoderKey, int(value))
+}
+
+func getPreferenceFolderExisting() imgutil.ExistingPolicy {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceFolderExistingKey,
+		int(imgutil.ExistingRename))
+	if value < 0 || value >= len(imgutil.ExistingPolicyNames) {
+		return imgutil.ExistingRename
+	}
+
+	return imgutil.ExistingPolicy(value)
+}
+
+func setPreferenceFolderExisting(value imgutil.ExistingPolicy) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceFolderExistingKey, int(value))
+}
+
+func getPreferenceVolumeFormat() imgutil.VolumeFormat {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceVolumeFormatKey, 0)
+	if value < 0 || value >= len(imgutil.VolumeFormatNames) {
+		return imgutil.VolumeCBZ
+	}
+
+	return imgutil.VolumeFormat(value)
+}
+
+func setPreferenceVolumeFormat(value imgutil.VolumeFormat) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceVolumeFormatKey, int(value))
+}
+
+func getPreferenceVolumeExisting() imgutil.ExistingPolicy {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceVolumeExistingKey,
+		int(imgutil.ExistingRename))
+	if value < 0 || value >= len(imgutil.ExistingPolicyNames) {
+		return imgutil.ExistingRename
+	}
+
+	return imgutil.ExistingPolicy(value)
+}
+
+func setPreferenceVolumeExisting(value imgutil.ExistingPolicy) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceVolumeExistingKey, int(value))
+}
+
+func getPreferenceSplitMode() imgutil.SplitMode {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceSplitModeKey, 0)
+	if value < 0 || value >= len(imgutil.SplitModeNames) {
+		return imgutil.SplitPages
+	}
+
+	return imgutil.SplitMode(value)
+}
+
+func setPreferenceSplitMode(value imgutil.SplitMode) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceSplitModeKey, int(value))
+}
+
+func getPreferenceSplitPages() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceSplitPagesKey, 200)
+}
+
+func setPreferenceSplitPages(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceSplitPagesKey, value)
+}
+
+// getPreferenceSplitMaxSize returns the largest size of a volume in MB.
+func getPreferenceSplitMaxSize() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceSplitMaxSizeKey, 100)
+}
+
+func setPreferenceSplitMaxSize(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceSplitMaxSizeKey, value)
+}
+
+func getPreferenceDuplicateMethod() imgutil.HashMethod {
+	value := fyne.CurrentApp().Preferences().IntWithFallback(PreferenceDuplicateMethodKey, 0)
+	if value < 0 || value >= len(imgutil.HashMethodNames) {
+		return imgutil.HashDHash
+	}
+
+	return imgutil.HashMethod(value)
+}
+
+func setPreferenceDuplicateMethod(value imgutil.HashMethod) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceDuplicateMethodKey, int(value))
+}
+
+func getPreferenceDuplicateThreshold() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceDuplicateThresholdKey, 5)
+}
+
+func setPreferenceDuplicateThreshold(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceDuplicateThresholdKey, value)
+}
+
+func getPreferenceBlankInkThreshold() int {
+	return fyne.CurrentApp().Preferences().IntWithFallback(PreferenceBlankInkThresholdKey, 48)
+}
+
+func setPreferenceBlankInkThreshold(value int) {
+	fyne.CurrentApp().Preferences().SetInt(PreferenceBlankInkThresholdKey, value)
+}
+
+func getPreferenceBlankMargin() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceBlankMarginKey, 5)
+}
+
+func setPreferenceBlankMargin(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceBlankMarginKey, value)
+}
+
+func getPreferenceBlankMaxCoverage() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceBlankMaxCoverageKey, 0.5)
+}
+
+func setPreferenceBlankMaxCoverage(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceBlankMaxCoverageKey, value)
+}
+
+func getPreferenceBlankMaxDeviation() float64 {
+	return fyne.CurrentApp().Preferences().FloatWithFallback(PreferenceBlankMaxDeviationKey, 4)
+}
+
+func setPreferenceBlankMaxDeviation(value float64) {
+	fyne.CurrentApp().Preferences().SetFloat(PreferenceBlankMaxDeviationKey, value)
+}
+
+// getPreferenceSaveOptions collects the export options from the preference.
+func getPreferenceSaveOptions() imgutil.SaveOptions {
+	return imgutil.SaveOptions{
+		Quality: getPreferenceJPGQuality(),
+		Resize: imgutil.ResizeOptions{
+			Mode:      getPreferenceResizeMode(),
+			MaxWidth:  getPreferenceResizeMaxWidth(),
+			MaxHeight: getPreferenceResizeMaxHeight(),
+			Width:     getPreferenceResizeWidth(),
+			Percent:   getPreferenceResizePercent(),
+			Filter:    imgutil.ResampleFilterByName(getPreferenceResizeFilter()),
+			Sharpen:   getPreferenceResizeSharpen(),
+		},
+		Passthrough: getPreferencePassthrough(),
+		Metadata:    getPreferenceMetadataMode(),
+		EmbedSRGB:   getPreferenceEmbedSRGB(),
+		EntryNames:  getPreferenceEntryNames(),
+		Workers:     getPreferenceEncodeWorkers(),
+	}
+}
+
 // GetPreferenceScale returns the scale factor of the application.
 func GetPreferenceScale() float64 {
 	conf, err := getConf()
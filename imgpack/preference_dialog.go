@@ -2,13 +2,48 @@ package imgpack
 
 import (
 	"fmt"
+	"image/color"
+	"runtime"
+	"slices"
+	"strconv"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
 )
 
+// resizeModeNames are the names of imgutil.ResizeMode shown in the preference,
+// indexed by the mode.
+var resizeModeNames = []string{"None", "Fit in max size", "Fixed width", "Percentage"}
+
+// svgBackgroundNames are the backgrounds of rasterized SVG images
+// shown in the preference, svgBackgrounds maps them to their colors.
+var svgBackgroundNames = []string{"Transparent", "White", "Black"}
+
+var svgBackgrounds = map[string]color.Color{
+	"White": color.White,
+	"Black": color.Black,
+}
+
+// newIntEntry creates an entry which only accepts non-negative integers.
+func newIntEntry(value int, onChanged func(int)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(value))
+	entry.OnChanged = func(s string) {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return
+		}
+
+		onChanged(v)
+	}
+
+	return entry
+}
+
 func preferenceContent() fyne.CanvasObject {
 	addDigitCheck := widget.NewCheck("", setPreferencePrependDigit)
 	addDigitCheck.SetChecked(getPreferencePrependDigit())
@@ -24,6 +59,79 @@ func preferenceContent() fyne.CanvasObject {
 		jpgQualitySliderLabel.SetText(fmt.Sprintf("JPG Quality: %d", int(v)))
 	}
 
+	passthroughCheck := widget.NewCheck("", setPreferencePassthrough)
+	passthroughCheck.SetChecked(getPreferencePassthrough())
+
+	metadataModeSelect := widget.NewSelect(imgutil.MetadataModeNames, func(s string) {
+		setPreferenceMetadataMode(imgutil.MetadataMode(slices.Index(imgutil.MetadataModeNames, s)))
+	})
+	metadataModeSelect.SetSelectedIndex(int(getPreferenceMetadataMode()))
+
+	embedSRGBCheck := widget.NewCheck("", setPreferenceEmbedSRGB)
+	embedSRGBCheck.SetChecked(getPreferenceEmbedSRGB())
+
+	// an empty template names the entries by the filenames
+	entryNamesEntry := widget.NewEntry()
+	entryNamesEntry.SetPlaceHolder("Filenames, or e.g. {book}_{n:03}")
+	entryNamesEntry.SetText(getPreferenceEntryNameTemplate())
+	entryNamesEntry.Validator = func(s string) error {
+		if s == "" {
+			return nil
+		}
+
+		_, err := imgutil.ParseNameTemplate(s)
+		return err
+	}
+	entryNamesEntry.OnChanged = func(s string) {
+		if entryNamesEntry.Validator(s) == nil {
+			setPreferenceEntryNameTemplate(s)
+		}
+	}
+
+	expandGIFFramesCheck := widget.NewCheck("", setPreferenceExpandGIFFrames)
+	expandGIFFramesCheck.SetChecked(getPreferenceExpandGIFFrames())
+
+	svgDPIEntry := newIntEntry(getPreferenceSVGDPI(), func(v int) {
+		if v > 0 {
+			setPreferenceSVGDPI(v)
+		}
+	})
+	svgWidthEntry := newIntEntry(getPreferenceSVGWidth(), setPreferenceSVGWidth)
+
+	svgBackgroundSelect := widget.NewSelect(svgBackgroundNames, setPreferenceSVGBackground)
+	svgBackgroundSelect.SetSelected(getPreferenceSVGBackground())
+
+	decodeWorkersLabel := widget.NewLabel(
+		fmt.Sprintf("Decode Workers: %d", getPreferenceDecodeWorkers()))
+
+	decodeWorkersSlider := widget.NewSlider(1, float64(max(runtime.NumCPU()*2, 8)))
+	decodeWorkersSlider.Step = 1
+	decodeWorkersSlider.Value = float64(getPreferenceDecodeWorkers())
+	decodeWorkersSlider.OnChanged = func(v float64) {
+		setPreferenceDecodeWorkers(int(v))
+		decodeWorkersLabel.SetText(fmt.Sprintf("Decode Workers: %d", int(v)))
+	}
+
+	encodeWorkersLabel := widget.NewLabel(
+		fmt.Sprintf("Encode Workers: %d", getPreferenceEncodeWorkers()))
+
+	encodeWorkersSlider := widget.NewSlider(1, float64(max(runtime.NumCPU()*2, 8)))
+	encodeWorkersSlider.Step = 1
+	encodeWorkersSlider.Value = float64(getPreferenceEncodeWorkers())
+	encodeWorkersSlider.OnChanged = func(v float64) {
+		setPreferenceEncodeWorkers(int(v))
+		encodeWorkersLabel.SetText(fmt.Sprintf("Encode Workers: %d", int(v)))
+	}
+
+	cacheBudgetEntry := newIntEntry(getPreferenceCacheBudget(), func(v int) {
+		if v > 0 {
+			setPreferenceCacheBudget(v)
+		}
+	})
+
+	diskStoreCheck := widget.NewCheck("", setPreferenceDiskStore)
+	diskStoreCheck.SetChecked(getPreferenceDiskStore())
+
 	appScaleLabel := widget.NewLabel(
 		fmt.Sprintf("App Scale: %.2f", GetPreferenceScale()))
 
@@ -36,11 +144,106 @@ func preferenceContent() fyne.CanvasObject {
 			fmt.Sprintf("App Scale: %.2f\n(Take effect after restart)", v))
 	}
 
+	maxWidthEntry := newIntEntry(getPreferenceResizeMaxWidth(), setPreferenceResizeMaxWidth)
+	maxHeightEntry := newIntEntry(getPreferenceResizeMaxHeight(), setPreferenceResizeMaxHeight)
+	widthEntry := newIntEntry(getPreferenceResizeWidth(), setPreferenceResizeWidth)
+
+	percentLabel := widget.NewLabel(
+		fmt.Sprintf("Resize Percent: %d%%", getPreferenceResizePercent()))
+
+	percentSlider := widget.NewSlider(1, 100)
+	percentSlider.Step = 1
+	percentSlider.Value = float64(getPreferenceResizePercent())
+	percentSlider.OnChanged = func(v float64) {
+		setPreferenceResizePercent(int(v))
+		percentLabel.SetText(fmt.Sprintf("Resize Percent: %d%%", int(v)))
+	}
+
+	filterSelect := widget.NewSelect(imgutil.ResampleFilterNames, setPreferenceResizeFilter)
+	filterSelect.SetSelected(getPreferenceResizeFilter())
+
+	sharpenLabel := widget.NewLabel(
+		fmt.Sprintf("Sharpen: %.1f", getPreferenceResizeSharpen()))
+
+	sharpenSlider := widget.NewSlider(0, 3)
+	sharpenSlider.Step = 0.1
+	sharpenSlider.Value = getPreferenceResizeSharpen()
+	sharpenSlider.OnChanged = func(v float64) {
+		setPreferenceResizeSharpen(v)
+		sharpenLabel.SetText(fmt.Sprintf("Sharpen: %.1f", v))
+	}
+
+	// only the fields of the selected mode are editable
+	updateResizeFields := func(mode imgutil.ResizeMode) {
+		fields := map[fyne.Disableable]bool{
+			maxWidthEntry:  mode == imgutil.ResizeFit,
+			maxHeightEntry: mode == imgutil.ResizeFit,
+			widthEntry:     mode == imgutil.ResizeWidth,
+			percentSlider:  mode == imgutil.ResizePercent,
+			filterSelect:   mode != imgutil.ResizeNone,
+			sharpenSlider:  mode != imgutil.ResizeNone,
+		}
+
+		for field, enabled := range fields {
+			if enabled {
+				field.Enable()
+			} else {
+				field.Disable()
+			}
+		}
+	}
+
+	resizeModeSelect := widget.NewSelect(resizeModeNames, func(s string) {
+		mode := imgutil.ResizeMode(slices.Index(resizeModeNames, s))
+		setPreferenceResizeMode(mode)
+		updateResizeFields(mode)
+	})
+	resizeModeSelect.SetSelectedIndex(int(getPreferenceResizeMode()))
+	updateResizeFields(getPreferenceResizeMode())
+
 	return container.New(layout.NewFormLayout(),
 		widget.NewLabel("Add digit to filename"),
 		addDigitCheck,
+		widget.NewLabel("Archive entry names"),
+		entryNamesEntry,
 		jpgQualitySliderLabel,
 		jpgQualitySlider,
+		widget.NewLabel("Copy unedited JPG as is"),
+		passthroughCheck,
+		widget.NewLabel("Metadata (EXIF/XMP)"),
+		metadataModeSelect,
+		widget.NewLabel("Embed sRGB profile"),
+		embedSRGBCheck,
+		widget.NewLabel("Resize on export"),
+		resizeModeSelect,
+		widget.NewLabel("Max Width"),
+		maxWidthEntry,
+		widget.NewLabel("Max Height"),
+		maxHeightEntry,
+		widget.NewLabel("Width"),
+		widthEntry,
+		percentLabel,
+		percentSlider,
+		widget.NewLabel("Resampling"),
+		filterSelect,
+		sharpenLabel,
+		sharpenSlider,
+		widget.NewLabel("Import GIF frames as pages"),
+		expandGIFFramesCheck,
+		widget.NewLabel("SVG DPI"),
+		svgDPIEntry,
+		widget.NewLabel("SVG Width (0 = by DPI)"),
+		svgWidthEntry,
+		widget.NewLabel("SVG Background"),
+		svgBackgroundSelect,
+		decodeWorkersLabel,
+		decodeWorkersSlider,
+		encodeWorkersLabel,
+		encodeWorkersSlider,
+		widget.NewLabel("Image Cache (MB)"),
+		cacheBudgetEntry,
+		widget.NewLabel("Keep sources on disk"),
+		diskStoreCheck,
 		appScaleLabel,
 		appScaleSlider,
 	)
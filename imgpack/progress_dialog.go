@@ -0,0 +1,98 @@
+package imgpack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// progressDialog shows the progress of a task as "done/total"
+// with a Cancel button which cancels the context of the task.
+type progressDialog struct {
+	dlg      dialog.Dialog
+	bar      *widget.ProgressBar
+	infinite *widget.ProgressBarInfinite
+
+	mu     sync.Mutex
+	done   int
+	total  int
+	cancel context.CancelFunc
+}
+
+func newProgressDialog(title string, w fyne.Window) *progressDialog {
+	d := &progressDialog{
+		bar:      widget.NewProgressBar(),
+		infinite: widget.NewProgressBarInfinite(),
+	}
+
+	d.bar.TextFormatter = func() string {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		return fmt.Sprintf("%d/%d", d.done, d.total)
+	}
+
+	d.dlg = dialog.NewCustom(title, "Cancel", container.NewStack(d.bar, d.infinite), w)
+	d.dlg.SetOnClosed(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.cancel != nil {
+			d.cancel()
+			d.cancel = nil
+		}
+	})
+
+	return d
+}
+
+// Start shows the dialog and returns a context which is cancelled
+// when the user presses Cancel.
+func (d *progressDialog) Start() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.mu.Lock()
+	d.done, d.total = 0, 0
+	d.cancel = cancel
+	d.mu.Unlock()
+
+	d.bar.Hide()
+	d.infinite.Show()
+	d.infinite.Start()
+	d.dlg.Show()
+
+	return ctx
+}
+
+// SetProgress updates the progress, the progress is shown as
+// an infinite progress bar while the total is unknown (zero).
+// It can be used as an imgutil.ProgressFunc.
+func (d *progressDialog) SetProgress(done, total int) {
+	d.mu.Lock()
+	d.done, d.total = done, total
+	d.mu.Unlock()
+
+	if total <= 0 {
+		return
+	}
+
+	if d.infinite.Visible() {
+		d.infinite.Stop()
+		d.infinite.Hide()
+		d.bar.Show()
+	}
+
+	d.bar.Max = float64(total)
+	d.bar.SetValue(float64(done))
+}
+
+// Hide hides the dialog.
+func (d *progressDialog) Hide() {
+	d.infinite.Stop()
+	d.dlg.Hide()
+}
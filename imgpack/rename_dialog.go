@@ -0,0 +1,127 @@
+package imgpack
+
+import (
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// renameScopes are the images a batch rename applies to
+var renameScopes = []string{"All Images", "Selected Images"}
+
+// renameTemplateHint lists the fields of the name templates
+const renameTemplateHint = "{book} {n} {orig} {page} {w} {h}, pad numbers as {n:03}"
+
+// showRenameDialog renames the images by a template,
+// the new names are previewed as the template is typed.
+func (iApp *ImgpackApp) showRenameDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to rename")
+		return
+	}
+
+	allImgs := slices.Clone(iApp.opTable.GetImgs())
+	imgs := allImgs
+
+	var names []string
+
+	templateEntry := widget.NewEntry()
+	templateEntry.SetText(getPreferenceRenameTemplate())
+
+	bookEntry := widget.NewEntry()
+	bookEntry.SetText(imgutil.BookName(allImgs))
+	bookEntry.SetPlaceHolder("book")
+
+	start := 1
+
+	errLabel := widget.NewLabel("")
+	errLabel.Wrapping = fyne.TextWrapWord
+	errLabel.Importance = widget.DangerImportance
+
+	preview := widget.NewList(
+		func() int {
+			return len(names)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("Name")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(fmt.Sprintf("%s → %s", imgs[i].Filename, names[i]))
+		},
+	)
+
+	var renameBtn *widget.Button
+	updatePreview := func() {
+		tmpl, err := imgutil.ParseNameTemplate(templateEntry.Text)
+		if err != nil {
+			names = nil
+			errLabel.SetText(err.Error())
+			errLabel.Show()
+			renameBtn.Disable()
+		} else {
+			names = tmpl.Names(imgs, bookEntry.Text, start)
+			errLabel.Hide()
+			renameBtn.Enable()
+		}
+
+		preview.Refresh()
+	}
+
+	startEntry := newIntEntry(start, func(v int) {
+		start = v
+		updatePreview()
+	})
+
+	scopeRadio := widget.NewRadioGroup(renameScopes, func(s string) {
+		imgs = allImgs
+		if s == renameScopes[1] {
+			imgs = iApp.opTable.GetSelectedImgs()
+		}
+		updatePreview()
+	})
+	scopeRadio.Horizontal = true
+	scopeRadio.Required = true
+	if !iApp.opTable.IsSelected() {
+		scopeRadio.Disable()
+	}
+
+	var dlg dialog.Dialog
+	renameBtn = widget.NewButton("Rename", func() {
+		setPreferenceRenameTemplate(templateEntry.Text)
+		iApp.opTable.Rename(imgs, names)
+		iApp.stateBar.SetText(fmt.Sprintf("Renamed %d images", len(imgs)))
+		dlg.Hide()
+	})
+	renameBtn.Importance = widget.HighImportance
+
+	templateEntry.OnChanged = func(string) { updatePreview() }
+	bookEntry.OnChanged = func(string) { updatePreview() }
+
+	scopeRadio.SetSelected(renameScopes[0])
+
+	templateItem := widget.NewFormItem("Template", templateEntry)
+	templateItem.HintText = renameTemplateHint
+	form := widget.NewForm(
+		templateItem,
+		widget.NewFormItem("Book", bookEntry),
+		widget.NewFormItem("Start Number", startEntry),
+		widget.NewFormItem("Apply To", scopeRadio),
+	)
+
+	content := container.NewBorder(
+		container.NewVBox(form, errLabel, widget.NewLabel("Preview")),
+		renameBtn, nil, nil,
+		preview)
+
+	dlg = dialog.NewCustom("Batch Rename", "Cancel", content, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(600, 550))
+	dlg.Show()
+}
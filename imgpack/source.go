@@ -0,0 +1,80 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// revealSource opens the folder containing the file
+// the selected image was read from.
+func (iApp *ImgpackApp) revealSource() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	img := iApp.opTable.GetSelectedImg()
+	if !img.Provenance.IsKnown() {
+		iApp.stateBar.SetText(fmt.Sprintf("The source of %s is unknown", img.Filename))
+		return
+	}
+
+	dirURL, err := url.Parse(storage.NewFileURI(filepath.Dir(img.Provenance.Path)).String())
+	if err != nil {
+		dialog.ShowError(err, iApp.mainWindow)
+		return
+	}
+
+	if err := iApp.fApp.OpenURL(dirURL); err != nil {
+		dialog.ShowError(err, iApp.mainWindow)
+	}
+}
+
+// reimportSelected reads the selected image again from its source
+// in the background and replaces it, its edit operations are dropped.
+func (iApp *ImgpackApp) reimportSelected() {
+	if !iApp.opTable.IsSelected() {
+		return
+	}
+
+	img := iApp.opTable.GetSelectedImg()
+	if !img.Provenance.IsKnown() {
+		iApp.stateBar.SetText(fmt.Sprintf("The source of %s is unknown", img.Filename))
+		return
+	}
+
+	ctx := iApp.readingImagesDlg.Start()
+
+	go func() {
+		defer iApp.readingImagesDlg.Hide()
+
+		opts := getPreferenceReadOptions()
+		opts.Progress = iApp.readingImagesDlg.SetProgress
+
+		newImg, err := imgutil.Reimport(ctx, img, opts)
+		if errors.Is(err, context.Canceled) {
+			iApp.stateBar.SetText("Reading cancelled")
+			return
+		}
+
+		if err != nil {
+			dialog.ShowError(err, iApp.mainWindow)
+			return
+		}
+
+		// the image may have been moved or removed in the meantime
+		if !iApp.opTable.ReplaceImg(img, newImg) {
+			iApp.stateBar.SetText(fmt.Sprintf("%s was removed before it was re-imported", img.Filename))
+			return
+		}
+
+		iApp.stateBar.SetText(fmt.Sprintf("Re-imported %s from %s", img.Filename, img.Provenance))
+	}()
+}
@@ -0,0 +1,134 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+const (
+	targetFormatArchive = "Archive"
+	targetFormatPDF     = "PDF"
+)
+
+// showTargetSizeDialog asks the format and the target size of the export,
+// then saves the images with the highest quality that fits the target size.
+func (iApp *ImgpackApp) showTargetSizeDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	formatRadio := widget.NewRadioGroup([]string{targetFormatArchive, targetFormatPDF}, nil)
+	formatRadio.Horizontal = true
+	formatRadio.Required = true
+	formatRadio.SetSelected(targetFormatArchive)
+
+	sizeEntry := widget.NewEntry()
+	sizeEntry.SetText(strconv.FormatFloat(getPreferenceTargetSize(), 'f', -1, 64))
+	sizeEntry.Validator = func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return errors.New("target size must be a positive number")
+		}
+		return nil
+	}
+
+	downscaleCheck := widget.NewCheck("Downscale if needed", nil)
+	downscaleCheck.SetChecked(getPreferenceTargetDownscale())
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", formatRadio),
+		widget.NewFormItem("Target Size (MB)", sizeEntry),
+		widget.NewFormItem("", downscaleCheck),
+	}
+
+	dlg := dialog.NewForm("Save To Target Size", "Save", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		targetMB, _ := strconv.ParseFloat(sizeEntry.Text, 64)
+		setPreferenceTargetSize(targetMB)
+		setPreferenceTargetDownscale(downscaleCheck.Checked)
+
+		iApp.saveToTargetSize(formatRadio.Selected,
+			int64(targetMB*1024*1024), downscaleCheck.Checked)
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(400, 250))
+	dlg.Show()
+}
+
+// saveToTargetSize saves the images in format with the highest qualities
+// that fit in target bytes, and reports the quality and the size reached.
+func (iApp *ImgpackApp) saveToTargetSize(format string, target int64, allowDownscale bool) {
+	imgs := slices.Clone(iApp.opTable.GetImgs())
+
+	var save imgutil.Saver
+	saveFile := saveArchiveFile
+	defaultName := "output.cbz"
+
+	switch format {
+	case targetFormatPDF:
+		save = func(ctx context.Context, w io.Writer, opts imgutil.SaveOptions) error {
+			return imgutil.SaveImgsAsPDFContext(ctx, imgs, w, opts)
+		}
+		saveFile = savePDFFile
+		defaultName = "output.pdf"
+	default:
+		prependDigit := getPreferencePrependDigit()
+		save = func(ctx context.Context, w io.Writer, opts imgutil.SaveOptions) error {
+			return imgutil.SaveImgsAsZipContext(ctx, imgs, w, prependDigit, opts)
+		}
+	}
+
+	saveFile(defaultName, func(f fyne.URIWriteCloser) {
+		ctx := iApp.savingDlg.Start()
+
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			opts := getPreferenceSaveOptions()
+			opts.Book = strings.TrimSuffix(f.URI().Name(), f.URI().Extension())
+			opts.Progress = iApp.savingDlg.SetProgress
+
+			opts, size, err := imgutil.FitTargetSize(ctx, save, opts, target, allowDownscale)
+			reached := !errors.Is(err, imgutil.ErrTargetSizeNotReached)
+			if err != nil && reached {
+				iApp.finishSaving(f, err)
+				return
+			}
+
+			err = save(ctx, f, opts)
+			iApp.finishSaving(f, err)
+			if err != nil {
+				return
+			}
+
+			quality := strconv.Itoa(opts.Quality)
+			if opts.MaxImageSize > 0 {
+				quality += fmt.Sprintf(" (lower over %s per image)", util.FormatSize(opts.MaxImageSize))
+			}
+
+			report := fmt.Sprintf("JPG Quality: %s, Scale: %d%%, Size: %s",
+				quality, int(opts.Scale*100+0.5), util.FormatSize(size))
+			if !reached {
+				report = "Target size not reached. " + report
+			}
+
+			iApp.stateBar.SetText(report)
+			dialog.ShowInformation("Saved", report, iApp.mainWindow)
+		}()
+	}, iApp.mainWindow)
+}
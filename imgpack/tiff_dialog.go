@@ -0,0 +1,59 @@
+package imgpack
+
+import (
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// showTIFFDialog asks the compression of the pages,
+// then saves the images as the pages of a multi-page TIFF.
+func (iApp *ImgpackApp) showTIFFDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	compressionSelect := widget.NewSelect(imgutil.TIFFCompressionNames, nil)
+	compressionSelect.SetSelectedIndex(int(getPreferenceTIFFCompression()))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Compression", compressionSelect),
+	}
+
+	dlg := dialog.NewForm("Save As Multi-page TIFF", "Save", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		compression := imgutil.TIFFCompression(compressionSelect.SelectedIndex())
+		setPreferenceTIFFCompression(compression)
+
+		iApp.saveTIFF(compression)
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(400, 200))
+	dlg.Show()
+}
+
+// saveTIFF saves the images as the pages of a multi-page TIFF
+// whose pages are compressed with compression.
+func (iApp *ImgpackApp) saveTIFF(compression imgutil.TIFFCompression) {
+	saveTIFFFile("output.tiff", func(f fyne.URIWriteCloser) {
+		ctx := iApp.savingDlg.Start()
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+
+		go func() {
+			defer iApp.savingDlg.Hide()
+
+			opts := getPreferenceSaveOptions()
+			opts.Progress = iApp.savingDlg.SetProgress
+
+			err := imgutil.SaveImgsAsTIFFContext(ctx, imgs, f, opts, compression)
+			iApp.finishSaving(f, err)
+		}()
+	}, iApp.mainWindow)
+}
@@ -0,0 +1,196 @@
+package imgpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/VoileLab/goimgpack/internal/imgutil"
+)
+
+// showVolumeDialog asks how the images are split into volumes,
+// then saves the volumes in a chosen folder.
+func (iApp *ImgpackApp) showVolumeDialog() {
+	if iApp.opTable.Len() == 0 {
+		iApp.stateBar.SetText("No image to save")
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(imgutil.BookName(iApp.opTable.GetImgs()))
+	if nameEntry.Text == "" {
+		nameEntry.SetText("book")
+	}
+	nameEntry.Validator = func(s string) error {
+		if s == "" {
+			return errors.New("name must not be empty")
+		}
+		return nil
+	}
+
+	formatSelect := widget.NewSelect(imgutil.VolumeFormatNames, nil)
+	formatSelect.SetSelectedIndex(int(getPreferenceVolumeFormat()))
+
+	existingSelect := widget.NewSelect(imgutil.ExistingPolicyNames, nil)
+	existingSelect.SetSelectedIndex(int(getPreferenceVolumeExisting()))
+
+	pagesEntry := widget.NewEntry()
+	pagesEntry.SetText(strconv.Itoa(getPreferenceSplitPages()))
+	pagesEntry.Validator = func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			return errors.New("page count must be a positive integer")
+		}
+		return nil
+	}
+
+	sizeEntry := widget.NewEntry()
+	sizeEntry.SetText(strconv.FormatFloat(getPreferenceSplitMaxSize(), 'f', -1, 64))
+	sizeEntry.Validator = func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return errors.New("max size must be a positive number")
+		}
+		return nil
+	}
+
+	// only the field of the selected mode is editable
+	modeSelect := widget.NewSelect(imgutil.SplitModeNames, func(s string) {
+		mode := imgutil.SplitMode(slices.Index(imgutil.SplitModeNames, s))
+		pagesEntry.Disable()
+		sizeEntry.Disable()
+
+		switch mode {
+		case imgutil.SplitPages:
+			pagesEntry.Enable()
+		case imgutil.SplitSize:
+			sizeEntry.Enable()
+		}
+	})
+	modeSelect.SetSelectedIndex(int(getPreferenceSplitMode()))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("Split By", modeSelect),
+		widget.NewFormItem("Pages Per Volume", pagesEntry),
+		widget.NewFormItem("Max Size (MB)", sizeEntry),
+		widget.NewFormItem("Existing Files", existingSelect),
+	}
+	items[0].HintText = "The volumes are named like name_v01"
+	items[2].HintText = "Split markers are set with Edit > Toggle Volume Split"
+
+	dlg := dialog.NewForm("Save As Volumes", "Choose Folder", "Cancel", items, func(b bool) {
+		if !b {
+			return
+		}
+
+		format := imgutil.VolumeFormat(formatSelect.SelectedIndex())
+		existing := imgutil.ExistingPolicy(existingSelect.SelectedIndex())
+		mode := imgutil.SplitMode(modeSelect.SelectedIndex())
+		pages, _ := strconv.Atoi(pagesEntry.Text)
+		maxMB, _ := strconv.ParseFloat(sizeEntry.Text, 64)
+
+		setPreferenceVolumeFormat(format)
+		setPreferenceVolumeExisting(existing)
+		setPreferenceSplitMode(mode)
+		if pages > 0 {
+			setPreferenceSplitPages(pages)
+		}
+		if maxMB > 0 {
+			setPreferenceSplitMaxSize(maxMB)
+		}
+
+		iApp.saveVolumes(nameEntry.Text, imgutil.SplitOptions{
+			Mode:         mode,
+			Pages:        pages,
+			MaxSize:      int64(maxMB * 1024 * 1024),
+			Format:       format,
+			PrependDigit: getPreferencePrependDigit(),
+			Existing:     existing,
+		})
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(450, 450))
+	dlg.Show()
+}
+
+// saveVolumes saves the images as the volumes named name in a chosen folder.
+// The user is warned first when images of the CBZ volumes may have
+// the same name as an earlier image.
+func (iApp *ImgpackApp) saveVolumes(name string, splitOpts imgutil.SplitOptions) {
+	dlg := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, iApp.mainWindow)
+			return
+		}
+
+		if dir == nil {
+			return
+		}
+
+		imgs := slices.Clone(iApp.opTable.GetImgs())
+		opts := getPreferenceSaveOptions()
+
+		save := func() {
+			ctx := iApp.savingDlg.Start()
+
+			go func() {
+				defer iApp.savingDlg.Hide()
+
+				opts.Progress = iApp.savingDlg.SetProgress
+
+				result, err := imgutil.SaveImgsAsVolumesContext(ctx, imgs, dir.Path(), name, opts, splitOpts)
+				if errors.Is(err, context.Canceled) {
+					iApp.stateBar.SetText("Saving cancelled")
+					return
+				}
+
+				if err != nil {
+					dialog.ShowError(err, iApp.mainWindow)
+					return
+				}
+
+				msg := fmt.Sprintf("Saved %d volumes to %s", len(result.Paths), dir.Name())
+				if result.Skipped > 0 {
+					msg += fmt.Sprintf(", skipped %d existing", result.Skipped)
+				}
+				if result.Renamed > 0 {
+					msg += fmt.Sprintf(", renamed %d", result.Renamed)
+				}
+				iApp.stateBar.SetText(msg)
+			}()
+		}
+
+		if splitOpts.Format != imgutil.VolumeCBZ {
+			save()
+			return
+		}
+
+		// the names are unique in every volume if they are unique in the book,
+		// so the names of the whole book bound the collisions of the volumes
+		_, collisions := imgutil.ZipEntryNames(imgs, splitOpts.PrependDigit, opts)
+		if collisions == 0 {
+			save()
+			return
+		}
+
+		msg := fmt.Sprintf("Up to %d images have the same name as an earlier image of their volume.\n"+
+			"They will be saved with the suffixes _2, _3...", collisions)
+		dialog.ShowConfirm("Duplicate Names", msg, func(ok bool) {
+			if !ok {
+				iApp.stateBar.SetText("Saving cancelled")
+				return
+			}
+
+			save()
+		}, iApp.mainWindow)
+	}, iApp.mainWindow)
+	dlg.Resize(fyne.NewSize(600, 600))
+	dlg.Show()
+}
@@ -0,0 +1,161 @@
+package imgutil
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// autoLevelsClip is the fraction of pixels clipped at each end of the
+// histogram by auto-levels, so that noise does not limit the stretch.
+const autoLevelsClip = 0.005
+
+// Adjustment stores the tonal adjustments applied to an image
+type Adjustment struct {
+	// Grayscale converts the image to grayscale
+	Grayscale bool `json:"grayscale,omitempty"`
+
+	// AutoLevels stretches the tonal range of the image to the full range
+	AutoLevels bool `json:"autoLevels,omitempty"`
+
+	// Brightness is in range [-100, 100], zero means no change
+	Brightness float64 `json:"brightness,omitempty"`
+
+	// Contrast is in range [-100, 100], zero means no change
+	Contrast float64 `json:"contrast,omitempty"`
+
+	// Gamma is the gamma correction, zero or one means no change
+	Gamma float64 `json:"gamma,omitempty"`
+
+	// Sharpen is the sigma of the sharpening, zero means no sharpening
+	Sharpen float64 `json:"sharpen,omitempty"`
+
+	// Blur is the sigma of the gaussian blur, zero means no blurring
+	Blur float64 `json:"blur,omitempty"`
+}
+
+// IsZero reports whether the adjustment changes nothing
+func (a Adjustment) IsZero() bool {
+	return !a.Grayscale && !a.AutoLevels &&
+		a.Brightness == 0 && a.Contrast == 0 &&
+		(a.Gamma == 0 || a.Gamma == 1) &&
+		a.Sharpen == 0 && a.Blur == 0
+}
+
+// String lists the adjustments which change the image
+func (a Adjustment) String() string {
+	var parts []string
+	if a.Grayscale {
+		parts = append(parts, "grayscale")
+	}
+	if a.AutoLevels {
+		parts = append(parts, "auto levels")
+	}
+	if a.Brightness != 0 {
+		parts = append(parts, fmt.Sprintf("brightness %.0f", a.Brightness))
+	}
+	if a.Contrast != 0 {
+		parts = append(parts, fmt.Sprintf("contrast %.0f", a.Contrast))
+	}
+	if a.Gamma != 0 && a.Gamma != 1 {
+		parts = append(parts, fmt.Sprintf("gamma %.1f", a.Gamma))
+	}
+	if a.Blur > 0 {
+		parts = append(parts, fmt.Sprintf("blur %.1f", a.Blur))
+	}
+	if a.Sharpen > 0 {
+		parts = append(parts, fmt.Sprintf("sharpen %.1f", a.Sharpen))
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Apply returns a new image with the adjustment applied
+func (a Adjustment) Apply(img image.Image) image.Image {
+	if a.IsZero() {
+		return img
+	}
+
+	var dst image.Image = img
+	if a.Grayscale {
+		dst = imaging.Grayscale(dst)
+	}
+
+	if a.AutoLevels {
+		dst = autoLevels(dst)
+	}
+
+	if a.Brightness != 0 {
+		dst = imaging.AdjustBrightness(dst, a.Brightness)
+	}
+
+	if a.Contrast != 0 {
+		dst = imaging.AdjustContrast(dst, a.Contrast)
+	}
+
+	if a.Gamma != 0 && a.Gamma != 1 {
+		dst = imaging.AdjustGamma(dst, a.Gamma)
+	}
+
+	if a.Blur > 0 {
+		dst = imaging.Blur(dst, a.Blur)
+	}
+
+	if a.Sharpen > 0 {
+		dst = imaging.Sharpen(dst, a.Sharpen)
+	}
+
+	return dst
+}
+
+// autoLevels stretches the luminance range of the image to [0, 255].
+// The same stretch is applied to every channel to keep the color balance.
+func autoLevels(img image.Image) image.Image {
+	src := imaging.Clone(img)
+
+	var hist [256]int
+	total := len(src.Pix) / 4
+	for i := 0; i < len(src.Pix); i += 4 {
+		r, g, b := int(src.Pix[i]), int(src.Pix[i+1]), int(src.Pix[i+2])
+		hist[(299*r+587*g+114*b)/1000]++
+	}
+
+	clip := int(float64(total) * autoLevelsClip)
+
+	lo, acc := 0, 0
+	for ; lo < 255; lo++ {
+		acc += hist[lo]
+		if acc > clip {
+			break
+		}
+	}
+
+	hi := 255
+	acc = 0
+	for ; hi > 0; hi-- {
+		acc += hist[hi]
+		if acc > clip {
+			break
+		}
+	}
+
+	if hi <= lo {
+		return src
+	}
+
+	scale := 255 / float64(hi-lo)
+	stretch := func(v uint8) uint8 {
+		return uint8(min(max((float64(v)-float64(lo))*scale, 0), 255) + 0.5)
+	}
+
+	return imaging.AdjustFunc(src, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{stretch(c.R), stretch(c.G), stretch(c.B), c.A}
+	})
+}
@@ -0,0 +1,143 @@
+package imgutil
+
+import (
+	"context"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+const (
+	// blankAnalysisSize bounds the sides of the image analyzed, the
+	// downscaling averages the pixels which smooths the scanner noise
+	blankAnalysisSize = 512
+
+	// blankPaperPercentile is the percentile of the luminance taken as
+	// the brightness of the paper, so that tinted paper is not ink
+	blankPaperPercentile = 0.9
+
+	// blankMinPaper is the least luminance of the paper, a darker page
+	// has no paper to speak of and its dark pixels are counted as ink
+	blankMinPaper = 160
+)
+
+// BlankOptions stores how the pages are analyzed for blank detection
+type BlankOptions struct {
+	// InkThreshold is how much darker than the paper (0-255)
+	// a pixel is to be counted as ink
+	InkThreshold int
+
+	// Margin is the percentage of each side of the page which is ignored,
+	// where the scanners leave shadows and the edges of the sheet
+	Margin float64
+
+	// Workers is the number of images analyzed concurrently,
+	// zero means one per CPU
+	Workers int
+
+	// Progress is called after each image is analyzed, it may be nil
+	Progress ProgressFunc
+}
+
+// BlankStats is the ink statistics of a page
+type BlankStats struct {
+	// Coverage is the percentage of the pixels of the page which are ink
+	Coverage float64
+
+	// Deviation is the standard deviation of the luminance (0-255)
+	Deviation float64
+}
+
+// BlankThresholds stores the limits under which a page is blank
+type BlankThresholds struct {
+	// MaxCoverage is the largest ink coverage percentage of a blank page
+	MaxCoverage float64
+
+	// MaxDeviation is the largest luminance deviation of a blank page,
+	// it catches the pages of a single color such as separator sheets
+	MaxDeviation float64
+}
+
+// IsBlank reports whether the page of the stats is blank: it has
+// very low ink coverage or very low luminance variation
+func (t BlankThresholds) IsBlank(stats BlankStats) bool {
+	return stats.Coverage <= t.MaxCoverage || stats.Deviation <= t.MaxDeviation
+}
+
+// AnalyzeBlank returns the ink statistics of the image
+func AnalyzeBlank(img image.Image, opts BlankOptions) BlankStats {
+	bounds := img.Bounds()
+	marginX := int(float64(bounds.Dx()) * opts.Margin / 100)
+	marginY := int(float64(bounds.Dy()) * opts.Margin / 100)
+	inner := image.Rect(bounds.Min.X+marginX, bounds.Min.Y+marginY,
+		bounds.Max.X-marginX, bounds.Max.Y-marginY)
+	if inner.Empty() {
+		inner = bounds
+	}
+
+	small := imaging.Fit(imaging.Crop(img, inner), blankAnalysisSize, blankAnalysisSize, imaging.Box)
+	flat := flattenOnWhite(small)
+
+	values := make([]float64, 0, len(flat.Pix)/4)
+	var sum float64
+	for i := 0; i < len(flat.Pix); i += 4 {
+		p := flat.Pix[i : i+3]
+		v := 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+		values = append(values, v)
+		sum += v
+	}
+
+	if len(values) == 0 {
+		return BlankStats{}
+	}
+
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	paper := max(sorted[int(float64(len(sorted)-1)*blankPaperPercentile)], blankMinPaper)
+
+	ink := 0
+	for _, v := range values {
+		if v < paper-float64(opts.InkThreshold) {
+			ink++
+		}
+	}
+
+	return BlankStats{
+		Coverage:  float64(ink) * 100 / float64(len(values)),
+		Deviation: math.Sqrt(variance),
+	}
+}
+
+// AnalyzeBlankPages returns the ink statistics of every image,
+// it stops when ctx is done and reports the progress to opts.Progress.
+func AnalyzeBlankPages(ctx context.Context, imgs []*Image, opts BlankOptions) ([]BlankStats, error) {
+	stats := make([]BlankStats, len(imgs))
+	counter := newProgressCounter(len(imgs), opts.Progress)
+
+	err := parallelDo(ctx, len(imgs), opts.Workers, func(i int) error {
+		decoded, err := imgs[i].Img()
+		if err != nil {
+			return util.Errorf("%s: %w", imgs[i].Filename, err)
+		}
+
+		stats[i] = AnalyzeBlank(decoded, opts)
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return stats, nil
+}
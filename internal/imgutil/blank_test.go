@@ -0,0 +1,62 @@
+package imgutil
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestAnalyzeBlank(t *testing.T) {
+	opts := BlankOptions{InkThreshold: 48, Margin: 5}
+	thresholds := BlankThresholds{MaxCoverage: 0.5, MaxDeviation: 4}
+
+	gray := func(v uint8) color.NRGBA { return color.NRGBA{v, v, v, 255} }
+
+	tests := []struct {
+		name string
+		fill func(x, y int) color.NRGBA
+		want bool
+	}{
+		{"white page", func(x, y int) color.NRGBA {
+			// the scanner noise
+			return gray(uint8(245 + (x*7+y*13)%10))
+		}, true},
+		{"tinted paper", func(x, y int) color.NRGBA {
+			return color.NRGBA{240, 225, 180, 255}
+		}, true},
+		{"black separator", func(x, y int) color.NRGBA {
+			return gray(0)
+		}, true},
+		{"text", func(x, y int) color.NRGBA {
+			if y%20 < 4 && x > 40 && x < 360 {
+				return gray(20)
+			}
+			return gray(250)
+		}, false},
+		{"black page with a white block", func(x, y int) color.NRGBA {
+			if x >= 150 && x < 240 && y >= 150 && y < 240 {
+				return gray(255)
+			}
+			return gray(0)
+		}, false},
+		{"dark page with light text", func(x, y int) color.NRGBA {
+			if y%20 < 4 && x > 40 && x < 360 {
+				return gray(230)
+			}
+			return gray(40)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := newTestImg(t, 400, 400, tt.fill).Img()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stats := AnalyzeBlank(img, opts)
+			if got := thresholds.IsBlank(stats); got != tt.want {
+				t.Errorf("blank is %v with %+v, want %v", got, stats, tt.want)
+			}
+		})
+	}
+}
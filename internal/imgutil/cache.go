@@ -0,0 +1,114 @@
+package imgutil
+
+import (
+	"container/list"
+	"image"
+	"sync"
+)
+
+// defaultCacheBudget is the default memory budget of the decoded images
+const defaultCacheBudget = 1 << 30
+
+// imageCache is a LRU cache of decoded images bounded by a memory budget
+type imageCache struct {
+	mu     sync.Mutex
+	budget int64
+	used   int64
+	lru    *list.List
+	items  map[any]*list.Element
+}
+
+type cacheEntry struct {
+	key  any
+	img  image.Image
+	size int64
+}
+
+var imgCache = &imageCache{
+	budget: defaultCacheBudget,
+	lru:    list.New(),
+	items:  make(map[any]*list.Element),
+}
+
+// SetCacheBudget sets the memory budget in bytes of the decoded images
+// kept in memory. The least recently used images are dropped first.
+func SetCacheBudget(budget int64) {
+	imgCache.mu.Lock()
+	defer imgCache.mu.Unlock()
+
+	imgCache.budget = budget
+	imgCache.evict()
+}
+
+// imageSize estimates the memory used by a decoded image
+func imageSize(img image.Image) int64 {
+	bounds := img.Bounds()
+	return int64(bounds.Dx()) * int64(bounds.Dy()) * 4
+}
+
+// RetainCache drops the decoded images of the sources not used by imgs,
+// e.g. the images removed from the list.
+func RetainCache(imgs []*Image) {
+	srcs := make(map[*source]bool, len(imgs))
+	for _, img := range imgs {
+		srcs[img.src] = true
+	}
+
+	imgCache.mu.Lock()
+	defer imgCache.mu.Unlock()
+
+	for key, elem := range imgCache.items {
+		var src *source
+		switch k := key.(type) {
+		case *source:
+			src = k
+		case renderKey:
+			src = k.src
+		}
+
+		if !srcs[src] {
+			imgCache.remove(elem)
+		}
+	}
+}
+
+func (c *imageCache) get(key any) (image.Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).img, true
+}
+
+func (c *imageCache) put(key any, img image.Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+
+	entry := &cacheEntry{key: key, img: img, size: imageSize(img)}
+	c.items[key] = c.lru.PushFront(entry)
+	c.used += entry.size
+	c.evict()
+}
+
+// evict drops the least recently used images until the budget is met,
+// the most recently used image is always kept.
+func (c *imageCache) evict() {
+	for c.used > c.budget && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *imageCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.used -= entry.size
+}
@@ -6,45 +6,71 @@ import (
 	_ "image/png"
 
 	_ "golang.org/x/image/bmp"
-	_ "golang.org/x/image/tiff"
+	"golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 
 	"bytes"
 	"image"
-	"io"
 
 	"github.com/VoileLab/goimgpack/internal/util"
-	"github.com/disintegration/imaging"
 )
 
-var SupportedImageExts = []string{".png", ".jpg", ".jpeg", ".webp", ".bmp", ".tiff", ".gif"}
+var SupportedImageExts = []string{".png", ".jpg", ".jpeg", ".webp", ".bmp", ".tiff", ".tif", ".gif"}
 
-const formatJPEG = "jpeg"
+const (
+	formatJPEG = "jpeg"
+	formatPNG  = "png"
+	formatTIFF = "tiff"
+)
 
-func decodeImage(r io.Reader) (image.Image, string, error) {
-	bs, err := io.ReadAll(r)
-	if err != nil {
-		return nil, "", util.Errorf("%w", err)
+// decodeImage decodes the image upright with its colors converted to sRGB,
+// the description of the color conversion is empty if there is none
+func decodeImage(bs []byte) (image.Image, string, string, error) {
+	var img image.Image
+	var imgType string
+	var err error
+	if isTIFF(bs) {
+		// the TIFF decoder reads the whole offsets of a broken file
+		// into memory unless it is given an io.ReaderAt, which
+		// image.Decode hides behind a buffered reader
+		img, err = tiff.Decode(bytes.NewReader(bs))
+		imgType = formatTIFF
+	} else {
+		img, imgType, err = image.Decode(bytes.NewReader(bs))
 	}
-
-	_, imgType, err := image.DecodeConfig(bytes.NewReader(bs))
 	if err != nil {
-		return nil, "", util.Errorf("%w", err)
+		return nil, "", "", util.Errorf("%w", err)
 	}
 
-	var img image.Image
+	// the colors are converted first since the CMYK values are lost
+	// when the image is transformed
+	img, conversion := toSRGB(img, readICCProfile(bs))
 
-	if imgType == formatJPEG {
-		// We should handle the orientation of the image
-		img, err = imaging.Decode(bytes.NewReader(bs),
-			imaging.AutoOrientation(true))
+	// We should handle the orientation of the image
+	img = orientImage(img, readMetadata(bs).orientation())
+
+	return img, imgType, conversion, nil
+}
+
+// decodeBounds returns the bounds of the image decodeImage decodes,
+// only the header of the image is read
+func decodeBounds(bs []byte) (image.Rectangle, error) {
+	var config image.Config
+	var err error
+	if isTIFF(bs) {
+		config, err = tiff.DecodeConfig(bytes.NewReader(bs))
 	} else {
-		img, _, err = image.Decode(bytes.NewReader(bs))
+		config, _, err = image.DecodeConfig(bytes.NewReader(bs))
 	}
-
 	if err != nil {
-		return nil, "", util.Errorf("%w", err)
+		return image.Rectangle{}, util.Errorf("%w", err)
+	}
+
+	// the orientations from 5 swap the width and the height
+	w, h := config.Width, config.Height
+	if readMetadata(bs).orientation() >= 5 {
+		w, h = h, w
 	}
 
-	return img, imgType, nil
+	return image.Rect(0, 0, w, h), nil
 }
@@ -0,0 +1,463 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EXIF tags used to read, filter and rewrite the metadata
+const (
+	exifTagImageDescription = 0x010e
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagXResolution      = 0x011a
+	exifTagYResolution      = 0x011b
+	exifTagResolutionUnit   = 0x0128
+	exifTagSoftware         = 0x0131
+	exifTagDateTime         = 0x0132
+	exifTagArtist           = 0x013b
+	exifTagYCbCrPositioning = 0x0213
+	exifTagCopyright        = 0x8298
+	exifTagExposureTime     = 0x829a
+	exifTagFNumber          = 0x829d
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagISO              = 0x8827
+	exifTagDateTimeOriginal = 0x9003
+	exifTagFocalLength      = 0x920a
+	exifTagMakerNote        = 0x927c
+	exifTagPixelXDimension  = 0xa002
+	exifTagPixelYDimension  = 0xa003
+	exifTagInteropIFD       = 0xa005
+)
+
+// EXIF tags of the GPS IFD
+const (
+	exifTagGPSLatitudeRef  = 0x0001
+	exifTagGPSLatitude     = 0x0002
+	exifTagGPSLongitudeRef = 0x0003
+	exifTagGPSLongitude    = 0x0004
+)
+
+// exifMaxFields bounds the number of fields read from an IFD
+const exifMaxFields = 1000
+
+// exifTypeSizes are the sizes of the EXIF field types indexed by the type
+var exifTypeSizes = []int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// exifIFD0Tags are the tags of IFD0 kept when the metadata is copied,
+// the tags describing the layout of the image data are left out since
+// the image is encoded again
+var exifIFD0Tags = []uint16{
+	exifTagImageDescription, exifTagMake, exifTagModel, exifTagOrientation,
+	exifTagXResolution, exifTagYResolution, exifTagResolutionUnit,
+	exifTagSoftware, exifTagDateTime, exifTagArtist, exifTagYCbCrPositioning,
+	exifTagCopyright, exifTagExifIFD, exifTagGPSIFD,
+}
+
+var errInvalidExif = errors.New("invalid EXIF data")
+
+// exifField is a field of an IFD,
+// value is the raw value in the byte order of the EXIF data
+type exifField struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+type exifIFD []exifField
+
+func (ifd exifIFD) find(tag uint16) *exifField {
+	for i := range ifd {
+		if ifd[i].tag == tag {
+			return &ifd[i]
+		}
+	}
+
+	return nil
+}
+
+// byteOrder reads and appends the values of a TIFF structure
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// exifData is a parsed EXIF structure,
+// only the IFDs describing the primary image are kept
+type exifData struct {
+	order byteOrder
+
+	ifd0, exif, gps, interop exifIFD
+}
+
+// parseExif parses the TIFF structure of EXIF data
+func parseExif(tiff []byte) (*exifData, error) {
+	if len(tiff) < 8 {
+		return nil, errInvalidExif
+	}
+
+	d := &exifData{}
+	switch string(tiff[:2]) {
+	case "II":
+		d.order = binary.LittleEndian
+	case "MM":
+		d.order = binary.BigEndian
+	default:
+		return nil, errInvalidExif
+	}
+
+	if d.order.Uint16(tiff[2:]) != 42 {
+		return nil, errInvalidExif
+	}
+
+	var err error
+	d.ifd0, err = d.readIFD(tiff, d.order.Uint32(tiff[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	// the sub-IFDs are optional, a broken one is dropped
+	d.exif = d.readSubIFD(tiff, d.ifd0, exifTagExifIFD)
+	d.gps = d.readSubIFD(tiff, d.ifd0, exifTagGPSIFD)
+	d.interop = d.readSubIFD(tiff, d.exif, exifTagInteropIFD)
+
+	return d, nil
+}
+
+func (d *exifData) readSubIFD(tiff []byte, parent exifIFD, tag uint16) exifIFD {
+	field := parent.find(tag)
+	if field == nil || len(field.value) < 4 {
+		return nil
+	}
+
+	ifd, err := d.readIFD(tiff, d.order.Uint32(field.value))
+	if err != nil {
+		return nil
+	}
+
+	return ifd
+}
+
+// readIFD reads the fields of the IFD at offset with their values
+func (d *exifData) readIFD(tiff []byte, offset uint32) (exifIFD, error) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, errInvalidExif
+	}
+
+	count := int(d.order.Uint16(tiff[offset:]))
+	if count > exifMaxFields {
+		return nil, errInvalidExif
+	}
+
+	ifd := make(exifIFD, 0, count)
+	for i := range count {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil, errInvalidExif
+		}
+
+		field := exifField{
+			tag:   d.order.Uint16(tiff[entry:]),
+			typ:   d.order.Uint16(tiff[entry+2:]),
+			count: d.order.Uint32(tiff[entry+4:]),
+		}
+
+		if int(field.typ) >= len(exifTypeSizes) || field.typ == 0 {
+			continue
+		}
+
+		size := uint64(exifTypeSizes[field.typ]) * uint64(field.count)
+		valueOffset := uint64(entry + 8)
+		if size > 4 {
+			valueOffset = uint64(d.order.Uint32(tiff[entry+8:]))
+		}
+
+		if valueOffset+size > uint64(len(tiff)) {
+			continue
+		}
+
+		// the value is copied so the data of the image is not kept alive
+		field.value = bytes.Clone(tiff[valueOffset : valueOffset+size])
+		ifd = append(ifd, field)
+	}
+
+	return ifd, nil
+}
+
+// orientation returns the orientation of the image, 1 if unknown
+func (d *exifData) orientation() int {
+	if d == nil {
+		return 1
+	}
+
+	field := d.ifd0.find(exifTagOrientation)
+	if field == nil || field.typ != tiffShort || len(field.value) < 2 {
+		return 1
+	}
+
+	orientation := int(d.order.Uint16(field.value))
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+
+	return orientation
+}
+
+// encode builds the TIFF structure of the metadata for an upright image,
+// the orientation is reset and the GPS IFD is left out unless keepGPS.
+// The maker note is left out since its internal offsets cannot be moved,
+// and the pixel dimensions since the image may be resized or edited.
+// nil is returned if nothing is left.
+func (d *exifData) encode(keepGPS bool) []byte {
+	ifd0 := slices.DeleteFunc(slices.Clone(d.ifd0), func(f exifField) bool {
+		return !slices.Contains(exifIFD0Tags, f.tag)
+	})
+
+	if field := ifd0.find(exifTagOrientation); field != nil {
+		field.typ, field.count = tiffShort, 1
+		field.value = d.order.AppendUint16(nil, 1)
+	}
+
+	exif := slices.DeleteFunc(slices.Clone(d.exif), func(f exifField) bool {
+		return f.tag == exifTagMakerNote ||
+			f.tag == exifTagPixelXDimension || f.tag == exifTagPixelYDimension
+	})
+	interop := d.interop
+	gps := d.gps
+	if !keepGPS {
+		gps = nil
+	}
+
+	// the pointers are placed first so the sizes of the IFDs are known
+	exif = setExifPointer(exif, exifTagInteropIFD, len(interop) > 0)
+	ifd0 = setExifPointer(ifd0, exifTagExifIFD, len(exif) > 0)
+	ifd0 = setExifPointer(ifd0, exifTagGPSIFD, len(gps) > 0)
+	if len(ifd0) == 0 {
+		return nil
+	}
+
+	ifd0Offset := uint32(8)
+	exifOffset := ifd0Offset + exifIFDSize(ifd0)
+	interopOffset := exifOffset + exifIFDSize(exif)
+	gpsOffset := interopOffset + exifIFDSize(interop)
+
+	d.setPointer(ifd0, exifTagExifIFD, exifOffset)
+	d.setPointer(ifd0, exifTagGPSIFD, gpsOffset)
+	d.setPointer(exif, exifTagInteropIFD, interopOffset)
+
+	buf := make([]byte, 0, gpsOffset+exifIFDSize(gps))
+	if d.order == binary.LittleEndian {
+		buf = append(buf, "II"...)
+	} else {
+		buf = append(buf, "MM"...)
+	}
+	buf = d.order.AppendUint16(buf, 42)
+	buf = d.order.AppendUint32(buf, ifd0Offset)
+
+	buf = d.appendIFD(buf, ifd0)
+	buf = d.appendIFD(buf, exif)
+	buf = d.appendIFD(buf, interop)
+	buf = d.appendIFD(buf, gps)
+
+	return buf
+}
+
+// setExifPointer adds or removes the pointer field tag of a sub-IFD
+func setExifPointer(ifd exifIFD, tag uint16, present bool) exifIFD {
+	ifd = slices.DeleteFunc(ifd, func(f exifField) bool {
+		return f.tag == tag
+	})
+
+	if !present {
+		return ifd
+	}
+
+	ifd = append(ifd, exifField{tag: tag, typ: tiffLong, count: 1, value: make([]byte, 4)})
+	slices.SortFunc(ifd, func(a, b exifField) int {
+		return int(a.tag) - int(b.tag)
+	})
+
+	return ifd
+}
+
+func (d *exifData) setPointer(ifd exifIFD, tag uint16, offset uint32) {
+	if field := ifd.find(tag); field != nil {
+		field.value = d.order.AppendUint32(nil, offset)
+	}
+}
+
+// exifIFDSize returns the size of the IFD with its values,
+// an empty IFD is not written
+func exifIFDSize(ifd exifIFD) uint32 {
+	if len(ifd) == 0 {
+		return 0
+	}
+
+	size := 2 + len(ifd)*12 + 4
+	for _, field := range ifd {
+		if len(field.value) > 4 {
+			size += len(field.value) + len(field.value)%2
+		}
+	}
+
+	return uint32(size)
+}
+
+// appendIFD appends the IFD followed by its values to buf,
+// the IFD is written at the current length of buf
+func (d *exifData) appendIFD(buf []byte, ifd exifIFD) []byte {
+	if len(ifd) == 0 {
+		return buf
+	}
+
+	valueOffset := uint32(len(buf) + 2 + len(ifd)*12 + 4)
+
+	var values []byte
+	buf = d.order.AppendUint16(buf, uint16(len(ifd)))
+	for _, field := range ifd {
+		buf = d.order.AppendUint16(buf, field.tag)
+		buf = d.order.AppendUint16(buf, field.typ)
+		buf = d.order.AppendUint32(buf, field.count)
+
+		if len(field.value) <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, field.value)
+			buf = append(buf, inline...)
+			continue
+		}
+
+		buf = d.order.AppendUint32(buf, valueOffset+uint32(len(values)))
+		values = append(values, field.value...)
+		if len(field.value)%2 != 0 {
+			values = append(values, 0)
+		}
+	}
+	buf = d.order.AppendUint32(buf, 0)
+
+	return append(buf, values...)
+}
+
+// ascii returns the ASCII value of the field tag in ifd
+func (d *exifData) ascii(ifd exifIFD, tag uint16) string {
+	field := ifd.find(tag)
+	if field == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(field.value), "\x00"))
+}
+
+// rationals returns the RATIONAL values of the field tag in ifd
+func (d *exifData) rationals(ifd exifIFD, tag uint16) []float64 {
+	field := ifd.find(tag)
+	if field == nil || field.typ != tiffRational {
+		return nil
+	}
+
+	values := make([]float64, 0, field.count)
+	for i := 0; i+8 <= len(field.value); i += 8 {
+		num := d.order.Uint32(field.value[i:])
+		den := d.order.Uint32(field.value[i+4:])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+
+	return values
+}
+
+// number returns the SHORT or LONG value of the field tag in ifd
+func (d *exifData) number(ifd exifIFD, tag uint16) (uint32, bool) {
+	field := ifd.find(tag)
+	if field == nil {
+		return 0, false
+	}
+
+	switch {
+	case field.typ == tiffShort && len(field.value) >= 2:
+		return uint32(d.order.Uint16(field.value)), true
+	case field.typ == tiffLong && len(field.value) >= 4:
+		return d.order.Uint32(field.value), true
+	default:
+		return 0, false
+	}
+}
+
+// gpsCoordinate returns the coordinate of the GPS fields in degrees
+func (d *exifData) gpsCoordinate(refTag, tag uint16, negativeRef string) (float64, bool) {
+	dms := d.rationals(d.gps, tag)
+	if len(dms) != 3 {
+		return 0, false
+	}
+
+	deg := dms[0] + dms[1]/60 + dms[2]/3600
+	if d.ascii(d.gps, refTag) == negativeRef {
+		deg = -deg
+	}
+
+	return deg, true
+}
+
+// fields describes the metadata for the user
+func (d *exifData) fields() []MetadataField {
+	var fields []MetadataField
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, MetadataField{Name: name, Value: value})
+		}
+	}
+
+	add("Make", d.ascii(d.ifd0, exifTagMake))
+	add("Model", d.ascii(d.ifd0, exifTagModel))
+	add("Description", d.ascii(d.ifd0, exifTagImageDescription))
+	add("Artist", d.ascii(d.ifd0, exifTagArtist))
+	add("Copyright", d.ascii(d.ifd0, exifTagCopyright))
+	add("Software", d.ascii(d.ifd0, exifTagSoftware))
+
+	dateTime := d.ascii(d.exif, exifTagDateTimeOriginal)
+	if dateTime == "" {
+		dateTime = d.ascii(d.ifd0, exifTagDateTime)
+	}
+	add("Date", dateTime)
+
+	if orientation := d.orientation(); orientation != 1 {
+		add("Orientation", fmt.Sprint(orientation))
+	}
+
+	if v := d.rationals(d.exif, exifTagExposureTime); len(v) == 1 && v[0] > 0 {
+		if v[0] < 1 {
+			add("Exposure", fmt.Sprintf("1/%.0f s", 1/v[0]))
+		} else {
+			add("Exposure", fmt.Sprintf("%g s", v[0]))
+		}
+	}
+
+	if v := d.rationals(d.exif, exifTagFNumber); len(v) == 1 {
+		add("F-Number", fmt.Sprintf("f/%.1f", v[0]))
+	}
+
+	if v, ok := d.number(d.exif, exifTagISO); ok {
+		add("ISO", fmt.Sprint(v))
+	}
+
+	if v := d.rationals(d.exif, exifTagFocalLength); len(v) == 1 {
+		add("Focal Length", fmt.Sprintf("%g mm", v[0]))
+	}
+
+	lat, latOK := d.gpsCoordinate(exifTagGPSLatitudeRef, exifTagGPSLatitude, "S")
+	lon, lonOK := d.gpsCoordinate(exifTagGPSLongitudeRef, exifTagGPSLongitude, "W")
+	if latOK && lonOK {
+		add("GPS", fmt.Sprintf("%.6f, %.6f", lat, lon))
+	} else if len(d.gps) > 0 {
+		add("GPS", "present")
+	}
+
+	return fields
+}
@@ -0,0 +1,393 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+// testField creates an EXIF field of typ with the raw value in order
+func testField(tag, typ uint16, count uint32, value []byte) exifField {
+	return exifField{tag: tag, typ: typ, count: count, value: value}
+}
+
+func testASCII(tag uint16, s string) exifField {
+	value := append([]byte(s), 0)
+	return testField(tag, 2, uint32(len(value)), value)
+}
+
+func testShort(order byteOrder, tag uint16, v uint16) exifField {
+	return testField(tag, tiffShort, 1, order.AppendUint16(nil, v))
+}
+
+func testLong(order byteOrder, tag uint16, v uint32) exifField {
+	return testField(tag, tiffLong, 1, order.AppendUint32(nil, v))
+}
+
+func testRationals(order byteOrder, tag uint16, values ...[2]uint32) exifField {
+	var value []byte
+	for _, v := range values {
+		value = order.AppendUint32(value, v[0])
+		value = order.AppendUint32(value, v[1])
+	}
+	return testField(tag, tiffRational, uint32(len(values)), value)
+}
+
+// buildTestExif lays out IFD0, the EXIF IFD and the GPS IFD one after
+// another, each followed by its values. The pointers to the sub-IFDs
+// which are not empty are added to IFD0.
+func buildTestExif(order byteOrder, ifd0, exif, gps []exifField) []byte {
+	size := func(ifd []exifField) uint32 {
+		if len(ifd) == 0 {
+			return 0
+		}
+
+		n := 2 + len(ifd)*12 + 4
+		for _, f := range ifd {
+			if len(f.value) > 4 {
+				n += len(f.value) + len(f.value)%2
+			}
+		}
+		return uint32(n)
+	}
+
+	ifd0 = slices.Clone(ifd0)
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, testLong(order, exifTagExifIFD, 0))
+	}
+	if len(gps) > 0 {
+		ifd0 = append(ifd0, testLong(order, exifTagGPSIFD, 0))
+	}
+
+	exifOffset := 8 + size(ifd0)
+	gpsOffset := exifOffset + size(exif)
+	for i := range ifd0 {
+		switch ifd0[i].tag {
+		case exifTagExifIFD:
+			ifd0[i].value = order.AppendUint32(nil, exifOffset)
+		case exifTagGPSIFD:
+			ifd0[i].value = order.AppendUint32(nil, gpsOffset)
+		}
+	}
+
+	buf := []byte("II")
+	if order == binary.BigEndian {
+		buf = []byte("MM")
+	}
+	buf = order.AppendUint16(buf, 42)
+	buf = order.AppendUint32(buf, 8)
+
+	for _, ifd := range [][]exifField{ifd0, exif, gps} {
+		if len(ifd) == 0 {
+			continue
+		}
+
+		valueOffset := uint32(len(buf)) + 2 + uint32(len(ifd))*12 + 4
+		var values []byte
+
+		buf = order.AppendUint16(buf, uint16(len(ifd)))
+		for _, f := range ifd {
+			buf = order.AppendUint16(buf, f.tag)
+			buf = order.AppendUint16(buf, f.typ)
+			buf = order.AppendUint32(buf, f.count)
+			if len(f.value) <= 4 {
+				buf = append(buf, f.value...)
+				buf = append(buf, make([]byte, 4-len(f.value))...)
+				continue
+			}
+
+			buf = order.AppendUint32(buf, valueOffset+uint32(len(values)))
+			values = append(values, f.value...)
+			if len(f.value)%2 != 0 {
+				values = append(values, 0)
+			}
+		}
+		buf = order.AppendUint32(buf, 0)
+		buf = append(buf, values...)
+	}
+
+	return buf
+}
+
+// newTestExif builds EXIF data with the fields of a photo:
+// camera fields, an orientation, exposure fields, a maker note,
+// the pixel dimensions and a GPS location
+func newTestExif(order byteOrder) []byte {
+	ifd0 := []exifField{
+		testASCII(exifTagMake, "Canon"),
+		testASCII(exifTagModel, "EOS 5D"),
+		testShort(order, exifTagOrientation, 6),
+		testRationals(order, exifTagXResolution, [2]uint32{300, 1}),
+		testLong(order, tiffTagStripOffsets, 1234),
+	}
+	exif := []exifField{
+		testRationals(order, exifTagExposureTime, [2]uint32{1, 200}),
+		testRationals(order, exifTagFNumber, [2]uint32{28, 10}),
+		testShort(order, exifTagISO, 400),
+		testField(exifTagMakerNote, tiffUndefined, 10, bytes.Repeat([]byte{0xaa}, 10)),
+		testLong(order, exifTagPixelXDimension, 4000),
+		testLong(order, exifTagPixelYDimension, 3000),
+	}
+	gps := []exifField{
+		testASCII(exifTagGPSLatitudeRef, "N"),
+		testRationals(order, exifTagGPSLatitude, [2]uint32{35, 1}, [2]uint32{30, 1}, [2]uint32{0, 1}),
+		testASCII(exifTagGPSLongitudeRef, "W"),
+		testRationals(order, exifTagGPSLongitude, [2]uint32{120, 1}, [2]uint32{15, 1}, [2]uint32{36, 1}),
+	}
+
+	return buildTestExif(order, ifd0, exif, gps)
+}
+
+// testOrders are the byte orders of the EXIF data tested
+var testOrders = map[string]byteOrder{
+	"little endian": binary.LittleEndian,
+	"big endian":    binary.BigEndian,
+}
+
+func fieldValue(fields []MetadataField, name string) string {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func TestParseExif(t *testing.T) {
+	for name, order := range testOrders {
+		t.Run(name, func(t *testing.T) {
+			d, err := parseExif(newTestExif(order))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := d.orientation(); got != 6 {
+				t.Errorf("orientation is %d, want 6", got)
+			}
+
+			fields := d.fields()
+			for field, want := range map[string]string{
+				"Make":     "Canon",
+				"Model":    "EOS 5D",
+				"Exposure": "1/200 s",
+				"F-Number": "f/2.8",
+				"ISO":      "400",
+				"GPS":      "35.500000, -120.260000",
+			} {
+				if got := fieldValue(fields, field); got != want {
+					t.Errorf("%s is %q, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExifEncodeRoundTrip(t *testing.T) {
+	for name, order := range testOrders {
+		t.Run(name, func(t *testing.T) {
+			d, err := parseExif(newTestExif(order))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded := d.encode(true)
+			got, err := parseExif(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.order != order {
+				t.Errorf("byte order changed")
+			}
+
+			if o := got.orientation(); o != 1 {
+				t.Errorf("orientation is %d, want 1 for an upright image", o)
+			}
+
+			for _, tag := range []uint16{exifTagMake, exifTagModel, exifTagXResolution, exifTagExifIFD, exifTagGPSIFD} {
+				if got.ifd0.find(tag) == nil {
+					t.Errorf("IFD0 tag %#x is missing", tag)
+				}
+			}
+
+			if got.ifd0.find(tiffTagStripOffsets) != nil {
+				t.Errorf("the strip offsets of the original image are kept")
+			}
+
+			for _, tag := range []uint16{exifTagMakerNote, exifTagPixelXDimension, exifTagPixelYDimension} {
+				if got.exif.find(tag) != nil {
+					t.Errorf("EXIF tag %#x is kept", tag)
+				}
+			}
+
+			for _, tag := range []uint16{exifTagExposureTime, exifTagFNumber, exifTagISO} {
+				want := d.exif.find(tag)
+				field := got.exif.find(tag)
+				if field == nil || !bytes.Equal(field.value, want.value) {
+					t.Errorf("EXIF tag %#x is %v, want %v", tag, field, want)
+				}
+			}
+
+			if !slices.EqualFunc(got.gps, d.gps, func(a, b exifField) bool {
+				return a.tag == b.tag && a.typ == b.typ && a.count == b.count && bytes.Equal(a.value, b.value)
+			}) {
+				t.Errorf("GPS IFD is %v, want %v", got.gps, d.gps)
+			}
+
+			// encoding the encoded data again does not change it
+			if again := got.encode(true); !bytes.Equal(again, encoded) {
+				t.Errorf("encoding is not stable")
+			}
+		})
+	}
+}
+
+func TestExifEncodeStripGPS(t *testing.T) {
+	d, err := parseExif(newTestExif(binary.LittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseExif(d.encode(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.gps != nil || got.ifd0.find(exifTagGPSIFD) != nil {
+		t.Errorf("the GPS IFD is kept")
+	}
+
+	if fieldValue(got.fields(), "GPS") != "" {
+		t.Errorf("the GPS location is described")
+	}
+
+	if fieldValue(got.fields(), "Make") != "Canon" || got.exif.find(exifTagISO) == nil {
+		t.Errorf("the other fields are not kept")
+	}
+}
+
+func TestExifEncodeNothingLeft(t *testing.T) {
+	order := binary.LittleEndian
+	tiff := buildTestExif(order, []exifField{testLong(order, tiffTagStripOffsets, 8)}, nil, nil)
+
+	d, err := parseExif(tiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := d.encode(true); got != nil {
+		t.Errorf("encode returned %d bytes, want nil", len(got))
+	}
+}
+
+func TestParseExifMalformed(t *testing.T) {
+	order := binary.LittleEndian
+	valid := newTestExif(order)
+
+	patch := func(f func(bs []byte) []byte) []byte {
+		return f(slices.Clone(valid))
+	}
+
+	// the position of the first field of IFD0
+	firstField := 8 + 2
+
+	tests := []struct {
+		name    string
+		tiff    []byte
+		wantErr bool
+	}{
+		{"empty", nil, true},
+		{"short header", valid[:6], true},
+		{"bad byte order", patch(func(bs []byte) []byte { bs[0] = 'X'; return bs }), true},
+		{"bad magic", patch(func(bs []byte) []byte { bs[2] = 43; return bs }), true},
+		{"IFD0 past the end", patch(func(bs []byte) []byte {
+			order.PutUint32(bs[4:], uint32(len(bs)))
+			return bs
+		}), true},
+		{"IFD0 offset overflow", patch(func(bs []byte) []byte {
+			order.PutUint32(bs[4:], math.MaxUint32)
+			return bs
+		}), true},
+		{"too many fields", patch(func(bs []byte) []byte {
+			order.PutUint16(bs[8:], exifMaxFields+1)
+			return bs
+		}), true},
+		{"truncated fields", valid[:firstField+12*2], true},
+		{"value past the end", patch(func(bs []byte) []byte {
+			// Make is stored after the IFD
+			order.PutUint32(bs[firstField+8:], uint32(len(bs)))
+			return bs
+		}), false},
+		{"huge count", patch(func(bs []byte) []byte {
+			order.PutUint32(bs[firstField+4:], math.MaxUint32)
+			return bs
+		}), false},
+		{"unknown type", patch(func(bs []byte) []byte {
+			order.PutUint16(bs[firstField+2:], 99)
+			return bs
+		}), false},
+		{"zero type", patch(func(bs []byte) []byte {
+			order.PutUint16(bs[firstField+2:], 0)
+			return bs
+		}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parseExif(tt.tiff)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			// the broken field is dropped and the others are kept
+			if d.ifd0.find(exifTagMake) != nil {
+				t.Errorf("the broken Make field is kept")
+			}
+			if d.orientation() != 6 {
+				t.Errorf("the other fields are dropped")
+			}
+
+			// the data can be encoded again
+			if _, err := parseExif(d.encode(true)); err != nil {
+				t.Errorf("encoded data is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseExifBrokenSubIFD(t *testing.T) {
+	order := binary.BigEndian
+	tiff := newTestExif(order)
+
+	d, err := parseExif(tiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the GPS IFD pointer is moved past the end of the data
+	gpsOffset := order.Uint32(d.ifd0.find(exifTagGPSIFD).value)
+	for pos := 10; pos+12 <= len(tiff); pos += 12 {
+		if order.Uint16(tiff[pos:]) == exifTagGPSIFD && order.Uint32(tiff[pos+8:]) == gpsOffset {
+			order.PutUint32(tiff[pos+8:], uint32(len(tiff)+10))
+			break
+		}
+	}
+
+	d, err = parseExif(tiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.gps != nil {
+		t.Errorf("the broken GPS IFD is read")
+	}
+	if d.exif == nil || d.ifd0.find(exifTagMake) == nil {
+		t.Errorf("the valid IFDs are dropped")
+	}
+}
@@ -0,0 +1,247 @@
+package imgutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// ImageEncoder is the format of the images exported to a folder
+type ImageEncoder int
+
+const (
+	// EncoderJPEG encodes the images as JPEG with the JPEG options of export
+	EncoderJPEG ImageEncoder = iota
+
+	// EncoderPNG encodes the images as lossless PNG
+	EncoderPNG
+
+	// EncoderTIFF encodes the images as TIFF with the compression of the options
+	EncoderTIFF
+
+	// EncoderBMP encodes the images as uncompressed BMP
+	EncoderBMP
+)
+
+// ImageEncoderNames are the names of ImageEncoder indexed by the value
+var ImageEncoderNames = []string{"JPEG", "PNG", "TIFF", "BMP"}
+
+// imageEncoderExts are the extensions of the files of ImageEncoder
+// indexed by the value
+var imageEncoderExts = []string{".jpg", ".png", ".tiff", ".bmp"}
+
+// ExistingPolicy is what is done when an exported file already exists
+type ExistingPolicy int
+
+const (
+	// ExistingRename keeps the existing file and exports the image
+	// with the first free suffix _2, _3..., it is the default
+	// so that no file is lost
+	ExistingRename ExistingPolicy = iota
+
+	// ExistingSkip keeps the existing file and does not export the image
+	ExistingSkip
+
+	// ExistingOverwrite replaces the existing file
+	ExistingOverwrite
+)
+
+// ExistingPolicyNames are the names of ExistingPolicy indexed by the value
+var ExistingPolicyNames = []string{"Keep Both", "Skip", "Overwrite"}
+
+// FolderOptions stores how the images are exported to a folder
+type FolderOptions struct {
+	// Encoder is the format of the images
+	Encoder ImageEncoder
+
+	// TIFFCompression is the compression of the TIFF images
+	TIFFCompression TIFFCompression
+
+	// PrependDigit prefixes the names with the index of the images
+	PrependDigit bool
+
+	// Existing is what is done when a file already exists
+	Existing ExistingPolicy
+}
+
+// FolderResult reports what an export to a folder did
+type FolderResult struct {
+	// Written is the number of files written
+	Written int
+
+	// Skipped is the number of images not exported since their file existed
+	Skipped int
+
+	// Renamed is the number of images exported with another name
+	// since their name was taken
+	Renamed int
+}
+
+// SaveImgsToFolderContext saves the images as files in the directory dir,
+// named like the entries of SaveImgsAsZip with the extension of the encoder.
+// The path separators and the characters invalid on Windows are replaced
+// in the names, which come from the archives read, so that the files
+// stay in dir. It stops when ctx is done and reports the progress to opts.Progress,
+// the files already written are kept.
+func SaveImgsToFolderContext(ctx context.Context, imgs []*Image, dir string,
+	opts SaveOptions, folderOpts FolderOptions) (FolderResult, error) {
+
+	var result FolderResult
+
+	names := baseEntryNames(imgs, folderOpts.PrependDigit, opts)
+	for i, name := range names {
+		names[i] = nameReplacer.Replace(name)
+	}
+
+	names, renamed := uniqueNames(names, imageEncoderExts[folderOpts.Encoder])
+	result.Renamed = renamed
+
+	// the names of this export, which the renamed files must not take
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[strings.ToLower(name)] = true
+	}
+
+	var writeImgs []*Image
+	var paths []string
+	for i, name := range names {
+		if name != filepath.Base(name) {
+			return result, util.Errorf("invalid file name %q", name)
+		}
+
+		p := filepath.Join(dir, name)
+		exists, err := fileExists(p)
+		if err != nil {
+			return result, util.Errorf("%w", err)
+		}
+
+		if exists {
+			switch folderOpts.Existing {
+			case ExistingSkip:
+				result.Skipped++
+				continue
+			case ExistingRename:
+				p, err = freePath(dir, name, taken)
+				if err != nil {
+					return result, util.Errorf("%w", err)
+				}
+				result.Renamed++
+			}
+		}
+
+		writeImgs = append(writeImgs, imgs[i])
+		paths = append(paths, p)
+	}
+
+	encode := imageEncoder(folderOpts)
+	err := encodeOrdered(ctx, writeImgs, opts, encode, func(i int, bs []byte) error {
+		if err := os.WriteFile(paths[i], bs, 0o644); err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		result.Written++
+		return nil
+	})
+	if err != nil {
+		return result, util.Errorf("%w", err)
+	}
+
+	return result, nil
+}
+
+// imageEncoder returns the function encoding an image with the encoder
+func imageEncoder(folderOpts FolderOptions) func(img *Image, opts SaveOptions) ([]byte, error) {
+	switch folderOpts.Encoder {
+	case EncoderPNG:
+		return func(img *Image, opts SaveOptions) ([]byte, error) {
+			return encodeImageBytes(img, opts, png.Encode)
+		}
+	case EncoderTIFF:
+		return func(img *Image, opts SaveOptions) ([]byte, error) {
+			// the progress of the pages is reported by the export
+			opts.Progress = nil
+
+			buf := new(bytes.Buffer)
+			err := SaveImgsAsTIFF([]*Image{img}, buf, opts, folderOpts.TIFFCompression)
+			if err != nil {
+				return nil, util.Errorf("%w", err)
+			}
+
+			return buf.Bytes(), nil
+		}
+	case EncoderBMP:
+		return func(img *Image, opts SaveOptions) ([]byte, error) {
+			return encodeImageBytes(img, opts, bmp.Encode)
+		}
+	default:
+		return encodeJPEGBytes
+	}
+}
+
+// encodeImageBytes renders the image resized by the options
+// and encodes it with encode
+func encodeImageBytes(img *Image, opts SaveOptions,
+	encode func(w io.Writer, img image.Image) error) ([]byte, error) {
+
+	decoded, err := img.Img()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := encode(buf, opts.prepare(decoded)); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fileExists reports whether the file p exists
+func fileExists(p string) (bool, error) {
+	_, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, util.Errorf("%w", err)
+	}
+
+	return true, nil
+}
+
+// freePath returns the path in dir of name with the first suffix _2, _3...
+// which is neither an existing file nor taken, the name is then taken
+func freePath(dir, name string, taken map[string]bool) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for n := 2; ; n++ {
+		suffixed := fmt.Sprintf("%s_%d%s", base, n, ext)
+		if taken[strings.ToLower(suffixed)] {
+			continue
+		}
+
+		p := filepath.Join(dir, suffixed)
+		exists, err := fileExists(p)
+		if err != nil {
+			return "", util.Errorf("%w", err)
+		}
+
+		if !exists {
+			taken[strings.ToLower(suffixed)] = true
+			return p, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package imgutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// folderFiles returns the names of the files in dir and its subdirectories
+func folderFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+func TestSaveImgsToFolderNames(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	imgs := newTestNamedImgs(t, 5, func(i int) string {
+		return []string{`../up`, `..\up`, `sub/page`, `a:b*?`, `plain`}[i]
+	})
+
+	result, err := SaveImgsToFolderContext(context.Background(), imgs, dir,
+		SaveOptions{}, FolderOptions{Encoder: EncoderPNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the separators are replaced, so ../up and ..\up collide
+	want := []string{"out/.._up.png", "out/.._up_2.png", "out/a_b__.png", "out/plain.png", "out/sub_page.png"}
+	if got := folderFiles(t, root); !slices.Equal(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+
+	if result.Written != 5 || result.Renamed != 1 {
+		t.Errorf("got result %+v, want 5 written and 1 renamed", result)
+	}
+}
+
+func TestSaveImgsToFolderExisting(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   ExistingPolicy
+		wantFiles  []string
+		wantKept   bool
+		wantResult FolderResult
+	}{
+		{"default keeps both", 0, []string{"a.png", "a_2.png", "b.png"}, true,
+			FolderResult{Written: 2, Renamed: 1}},
+		{"skip", ExistingSkip, []string{"a.png", "b.png"}, true,
+			FolderResult{Written: 1, Skipped: 1}},
+		{"overwrite", ExistingOverwrite, []string{"a.png", "b.png"}, false,
+			FolderResult{Written: 2}},
+	}
+
+	old := []byte("existing file")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "a.png"), old, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			imgs := newTestNamedImgs(t, 2, func(i int) string {
+				return []string{"a", "b"}[i]
+			})
+
+			result, err := SaveImgsToFolderContext(context.Background(), imgs, dir,
+				SaveOptions{}, FolderOptions{Encoder: EncoderPNG, Existing: tt.existing})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != tt.wantResult {
+				t.Errorf("got result %+v, want %+v", result, tt.wantResult)
+			}
+
+			if got := folderFiles(t, dir); !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("got files %v, want %v", got, tt.wantFiles)
+			}
+
+			bs, err := os.ReadFile(filepath.Join(dir, "a.png"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := string(bs) == string(old); kept != tt.wantKept {
+				t.Errorf("existing file kept is %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestExistingPolicyDefault(t *testing.T) {
+	var opts FolderOptions
+	if opts.Existing != ExistingRename {
+		t.Errorf("the default policy is %s, want %s",
+			ExistingPolicyNames[opts.Existing], ExistingPolicyNames[ExistingRename])
+	}
+}
@@ -0,0 +1,225 @@
+package imgutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+const formatGIF = "gif"
+
+var errInvalidGIF = errors.New("invalid GIF structure")
+
+// GIFOptions stores the options of the animated GIF export
+type GIFOptions struct {
+	// Delay is the delay of every frame in 100ths of a second
+	Delay int
+
+	// UseFrameDelay uses the delay of the frames imported from
+	// an animated GIF instead of Delay when it is known
+	UseFrameDelay bool
+
+	// LoopCount is the number of times the animation is repeated,
+	// zero means forever and -1 means the animation is shown once
+	LoopCount int
+
+	// Colors is the size of the palette of each frame, at most 256
+	Colors int
+
+	// Dither diffuses the quantization error with Floyd-Steinberg dithering
+	Dither bool
+}
+
+// delay returns the delay of the frame of img
+func (o GIFOptions) delay(img *Image) int {
+	if o.UseFrameDelay && img.Delay > 0 {
+		return img.Delay
+	}
+
+	return o.Delay
+}
+
+// gifFrame is a frame of an animated GIF composited on the logical screen
+type gifFrame struct {
+	img image.Image
+
+	// delay is the delay of the frame in 100ths of a second
+	delay int
+}
+
+// isGIF reports whether bs is a GIF image
+func isGIF(bs []byte) bool {
+	return bytes.HasPrefix(bs, []byte("GIF8"))
+}
+
+// gifFrameCount returns the number of frames of a GIF image,
+// the image descriptors are counted without decoding the frames
+func gifFrameCount(bs []byte) (int, error) {
+	// header and logical screen descriptor
+	const headerLen = 13
+	if len(bs) < headerLen || !isGIF(bs) {
+		return 0, util.Errorf("%w", errInvalidGIF)
+	}
+
+	pos := headerLen + gifColorTableLen(bs[10])
+	count := 0
+
+	for pos < len(bs) {
+		switch bs[pos] {
+		case 0x21: // extension introducer, label and sub-blocks
+			pos += 2
+		case 0x2c: // image descriptor, local color table, LZW code size and sub-blocks
+			if pos+10 > len(bs) {
+				return 0, util.Errorf("%w", errInvalidGIF)
+			}
+			pos += 10 + gifColorTableLen(bs[pos+9]) + 1
+			count++
+		case 0x3b: // trailer
+			return count, nil
+		default:
+			return 0, util.Errorf("%w", errInvalidGIF)
+		}
+
+		// skip the sub-blocks until the block terminator
+		for {
+			if pos >= len(bs) {
+				return 0, util.Errorf("%w", errInvalidGIF)
+			}
+
+			size := int(bs[pos])
+			pos += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+
+	// some encoders omit the trailer
+	return count, nil
+}
+
+// gifColorTableLen returns the length of the color table
+// described by the packed fields of a descriptor
+func gifColorTableLen(packed byte) int {
+	if packed&0x80 == 0 {
+		return 0
+	}
+
+	return 3 << (packed&0x07 + 1)
+}
+
+// decodeGIFFrames decodes every frame of a GIF image as it is displayed,
+// each frame is drawn over the previous ones according to their disposal.
+func decodeGIFFrames(bs []byte) ([]gifFrame, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(bs))
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	screen := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if screen.Empty() {
+		// some encoders leave the logical screen empty
+		for _, frame := range g.Image {
+			screen = screen.Union(frame.Bounds())
+		}
+	}
+
+	canvas := image.NewNRGBA(screen)
+	frames := make([]gifFrame, len(g.Image))
+
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(screen)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		composited := image.NewNRGBA(screen)
+		copy(composited.Pix, canvas.Pix)
+
+		frames[i] = gifFrame{img: composited}
+		if i < len(g.Delay) {
+			frames[i].delay = g.Delay[i]
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			// the background is shown as transparent like the browsers do
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames, nil
+}
+
+// SaveImgsAsGIF saves images as the frames of an animated GIF file
+func SaveImgsAsGIF(imgs []*Image, f io.Writer, opts SaveOptions, gifOpts GIFOptions) error {
+	return SaveImgsAsGIFContext(context.Background(), imgs, f, opts, gifOpts)
+}
+
+// SaveImgsAsGIFContext saves images as the frames of an animated GIF file,
+// the images are resized with opts and each frame has its own palette.
+// It stops when ctx is done and reports the progress to opts.Progress.
+func SaveImgsAsGIFContext(ctx context.Context, imgs []*Image, f io.Writer,
+	opts SaveOptions, gifOpts GIFOptions) error {
+
+	colors := min(max(gifOpts.Colors, 2), 256)
+
+	frames := make([]*image.Paletted, len(imgs))
+	counter := newProgressCounter(len(imgs), opts.Progress)
+	err := parallelDo(ctx, len(imgs), opts.Workers, func(i int) error {
+		decoded, err := imgs[i].Img()
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		frames[i] = quantize(opts.prepare(decoded), colors, gifOpts.Dither)
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	g := &gif.GIF{
+		Image:     frames,
+		Delay:     make([]int, len(frames)),
+		Disposal:  make([]byte, len(frames)),
+		LoopCount: gifOpts.LoopCount,
+	}
+
+	for i, frame := range frames {
+		g.Config.Width = max(g.Config.Width, frame.Rect.Dx())
+		g.Config.Height = max(g.Config.Height, frame.Rect.Dy())
+		g.Delay[i] = gifOpts.delay(imgs[i])
+	}
+
+	// the disposal of a frame applies after it is shown, so a frame
+	// is cleared when the next frame does not cover it
+	for i := 1; i < len(frames); i++ {
+		if !frames[i-1].Rect.In(frames[i].Rect) {
+			g.Disposal[i-1] = gif.DisposalBackground
+		}
+	}
+
+	if err := gif.EncodeAll(f, g); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
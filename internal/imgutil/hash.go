@@ -0,0 +1,236 @@
+package imgutil
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// HashMethod is the perceptual hash used to compare images
+type HashMethod int
+
+const (
+	// HashDHash compares the brightness of neighboring pixels,
+	// it is fast and finds identical and resized pages
+	HashDHash HashMethod = iota
+
+	// HashPHash compares the low frequencies of the image,
+	// it is slower and also finds recompressed or slightly altered pages
+	HashPHash
+)
+
+// HashMethodNames are the names of HashMethod indexed by the value
+var HashMethodNames = []string{"dHash (fast)", "pHash (robust)"}
+
+const (
+	// pHashSize is the size of the image transformed by pHash
+	pHashSize = 32
+
+	// hashBits is the number of bits of a perceptual hash
+	hashBits = 64
+
+	// uniformMaxDeviation is the largest standard deviation of the
+	// luminance of a near-uniform image, whose hash says nothing about it
+	uniformMaxDeviation = 4
+)
+
+// DuplicateOptions stores how duplicate images are searched
+type DuplicateOptions struct {
+	// Method is the perceptual hash comparing the images
+	Method HashMethod
+
+	// Threshold is the largest number of different bits of the hashes
+	// of two duplicate images, zero only matches identical hashes
+	Threshold int
+
+	// Workers is the number of images hashed concurrently,
+	// zero means one per CPU
+	Workers int
+
+	// Progress is called after each image is hashed, it may be nil
+	Progress ProgressFunc
+}
+
+// PerceptualHash returns the 64-bit perceptual hash of the image,
+// similar images have hashes with few different bits.
+func PerceptualHash(img image.Image, method HashMethod) uint64 {
+	if method == HashPHash {
+		return pHash(img)
+	}
+
+	return dHash(img)
+}
+
+// HashDistance returns the number of different bits of two hashes
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// luminance returns the luminance of the pixels of the image resized
+// to w x h, the transparent pixels are flattened on white
+func luminance(img image.Image, w, h int) []float64 {
+	small := imaging.Resize(flattenOnWhite(img), w, h, imaging.Box)
+
+	values := make([]float64, w*h)
+	for i := range values {
+		p := small.Pix[i*4 : i*4+3]
+		values[i] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+	}
+
+	return values
+}
+
+// dHash sets a bit for each pixel of a 9x8 image which is brighter
+// than its right neighbor
+func dHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	values := luminance(img, w, h)
+
+	var hash uint64
+	for y := range h {
+		for x := range w - 1 {
+			hash <<= 1
+			if values[y*w+x] > values[y*w+x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// pHash sets a bit for each of the 8x8 lowest frequencies of the DCT
+// of a 32x32 image which is above their median
+func pHash(img image.Image) uint64 {
+	const n, size = pHashSize, 8
+	values := luminance(img, n, n)
+
+	cos := make([]float64, n*size)
+	for u := range size {
+		for x := range n {
+			cos[u*n+x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+
+	// the rows are transformed first, then the columns
+	rows := make([]float64, n*size)
+	for y := range n {
+		for u := range size {
+			var sum float64
+			for x := range n {
+				sum += values[y*n+x] * cos[u*n+x]
+			}
+			rows[y*size+u] = sum
+		}
+	}
+
+	coefs := make([]float64, size*size)
+	for v := range size {
+		for u := range size {
+			var sum float64
+			for y := range n {
+				sum += rows[y*size+u] * cos[v*n+y]
+			}
+			coefs[v*size+u] = sum
+		}
+	}
+
+	// the DC coefficient is the average brightness, it is left out of the median
+	sorted := slices.Clone(coefs[1:])
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for _, c := range coefs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+// isUniform reports whether the image is near-uniform, e.g. a blank page.
+// All the uniform images have about the same hash whatever their color.
+func isUniform(img image.Image) bool {
+	values := luminance(img, 16, 16)
+
+	var sum, sqSum float64
+	for _, v := range values {
+		sum += v
+		sqSum += v * v
+	}
+
+	n := float64(len(values))
+	mean := sum / n
+	return math.Sqrt(max(sqSum/n-mean*mean, 0)) <= uniformMaxDeviation
+}
+
+// FindDuplicates groups the images whose perceptual hashes differ by at most
+// opts.Threshold bits from the first image of their group.
+// The near-uniform images are left out since their hashes are alike,
+// they are found as blank pages instead.
+// The groups of at least two images are returned as indexes in imgs,
+// in the order of their first image. It stops when ctx is done and
+// reports the progress to opts.Progress.
+func FindDuplicates(ctx context.Context, imgs []*Image, opts DuplicateOptions) ([][]int, error) {
+	hashes := make([]uint64, len(imgs))
+	uniform := make([]bool, len(imgs))
+	counter := newProgressCounter(len(imgs), opts.Progress)
+
+	err := parallelDo(ctx, len(imgs), opts.Workers, func(i int) error {
+		decoded, err := imgs[i].Img()
+		if err != nil {
+			return util.Errorf("%s: %w", imgs[i].Filename, err)
+		}
+
+		hashes[i] = PerceptualHash(decoded, opts.Method)
+		uniform[i] = isUniform(decoded)
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return groupHashes(hashes, uniform, opts.Threshold), nil
+}
+
+// groupHashes groups each hash with the following hashes which differ
+// by at most threshold bits from it and are not grouped yet.
+// The images are not grouped through other images, so the images
+// of a group are all similar to its first image.
+// The skipped hashes are not grouped.
+func groupHashes(hashes []uint64, skipped []bool, threshold int) [][]int {
+	threshold = min(max(threshold, 0), hashBits)
+	grouped := make([]bool, len(hashes))
+	copy(grouped, skipped)
+
+	var groups [][]int
+	for i := range hashes {
+		if grouped[i] {
+			continue
+		}
+
+		group := []int{i}
+		for j := i + 1; j < len(hashes); j++ {
+			if !grouped[j] && HashDistance(hashes[i], hashes[j]) <= threshold {
+				group = append(group, j)
+				grouped[j] = true
+			}
+		}
+
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
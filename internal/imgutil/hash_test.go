@@ -0,0 +1,95 @@
+package imgutil
+
+import (
+	"context"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestGroupHashes(t *testing.T) {
+	tests := []struct {
+		name      string
+		hashes    []uint64
+		skipped   []bool
+		threshold int
+		want      [][]int
+	}{
+		{
+			name:   "identical",
+			hashes: []uint64{0xf0, 0x0f, 0xf0, 0xf0},
+			want:   [][]int{{0, 2, 3}},
+		},
+		{
+			// 0x1 and 0x7 differ by 2 bits, they are not grouped through 0x3
+			name:      "no chaining",
+			hashes:    []uint64{0x1, 0x3, 0x7},
+			threshold: 1,
+			want:      [][]int{{0, 1}},
+		},
+		{
+			name:      "grouped once",
+			hashes:    []uint64{0x1, 0x3, 0x2, 0x6},
+			threshold: 1,
+			want:      [][]int{{0, 1}, {2, 3}},
+		},
+		{
+			name:    "skipped",
+			hashes:  []uint64{0, 0, 0, 0xff, 0xff},
+			skipped: []bool{true, true, false, false, false},
+			want:    [][]int{{3, 4}},
+		},
+		{
+			name:      "negative threshold",
+			hashes:    []uint64{0x1, 0x1, 0x3},
+			threshold: -5,
+			want:      [][]int{{0, 1}},
+		},
+		{
+			name:   "no duplicates",
+			hashes: []uint64{0x1, 0x2, 0x4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupHashes(tt.hashes, tt.skipped, tt.threshold)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got groups %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDuplicatesSkipsUniform(t *testing.T) {
+	white := func(x, y int) color.NRGBA { return color.NRGBA{255, 255, 255, 255} }
+	gray := func(x, y int) color.NRGBA { return color.NRGBA{128, 128, 128, 255} }
+	// the same 4x4 blocks of grays at size w
+	blocks := func(w int) func(x, y int) color.NRGBA {
+		return func(x, y int) color.NRGBA {
+			v := uint8((x*4/w*3 + y*4/w*5) % 8 * 32)
+			return color.NRGBA{v, v, v, 255}
+		}
+	}
+
+	imgs := []*Image{
+		newTestImg(t, 64, 64, white),
+		newTestImg(t, 64, 64, blocks(64)),
+		newTestImg(t, 64, 64, gray),
+		newTestImg(t, 32, 32, white),
+		newTestImg(t, 128, 128, blocks(128)),
+	}
+
+	for method, name := range HashMethodNames {
+		t.Run(name, func(t *testing.T) {
+			groups, err := FindDuplicates(context.Background(), imgs, DuplicateOptions{Method: HashMethod(method), Threshold: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := [][]int{{1, 4}}; !reflect.DeepEqual(groups, want) {
+				t.Errorf("got groups %v, want %v", groups, want)
+			}
+		})
+	}
+}
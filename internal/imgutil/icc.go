@@ -0,0 +1,1098 @@
+package imgutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// tiffTagICCProfile is the TIFF tag storing an ICC profile
+	tiffTagICCProfile = 34675
+
+	// iccHeaderSize is the size of the header of an ICC profile
+	iccHeaderSize = 128
+
+	// iccMaxTags bounds the number of tags read from a profile
+	iccMaxTags = 200
+
+	// iccMaxGridSize bounds the number of entries of a color lookup table
+	iccMaxGridSize = 1 << 22
+
+	// cmykGridPoints is the number of points per channel of the table
+	// of sRGB colors built from a CMYK profile
+	cmykGridPoints = 17
+
+	// srgbEncodeSize is the size of the table encoding linear values to sRGB
+	srgbEncodeSize = 4096
+)
+
+var (
+	iccHeader = []byte("ICC_PROFILE\x00")
+
+	errInvalidICC = errors.New("invalid ICC profile")
+)
+
+// iccD50 is the white point of the profile connection space
+var iccD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// srgbColorants are the colorants of sRGB adapted to D50
+var srgbColorants = [3][3]float64{
+	{0.4360747, 0.2225045, 0.0139322},
+	{0.3850649, 0.7168786, 0.0971045},
+	{0.1430804, 0.0606169, 0.7141733},
+}
+
+// xyzToSRGB converts XYZ adapted to D50 to linear sRGB
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// iccProfile is a parsed ICC profile
+type iccProfile struct {
+	// colorSpace and pcs are the signatures of the color space of the data
+	// and of the profile connection space
+	colorSpace, pcs string
+
+	tags map[string][]byte
+}
+
+// parseICC parses the header and the tag table of an ICC profile
+func parseICC(bs []byte) (*iccProfile, error) {
+	if len(bs) < iccHeaderSize+4 || string(bs[36:40]) != "acsp" {
+		return nil, errInvalidICC
+	}
+
+	p := &iccProfile{
+		colorSpace: string(bs[16:20]),
+		pcs:        string(bs[20:24]),
+		tags:       make(map[string][]byte),
+	}
+
+	count := int(binary.BigEndian.Uint32(bs[iccHeaderSize:]))
+	if count > iccMaxTags {
+		return nil, errInvalidICC
+	}
+
+	for i := range count {
+		entry := iccHeaderSize + 4 + i*12
+		if entry+12 > len(bs) {
+			return nil, errInvalidICC
+		}
+
+		offset := uint64(binary.BigEndian.Uint32(bs[entry+4:]))
+		size := uint64(binary.BigEndian.Uint32(bs[entry+8:]))
+		if offset+size > uint64(len(bs)) || size < 8 {
+			continue
+		}
+
+		p.tags[string(bs[entry:entry+4])] = bs[offset : offset+size]
+	}
+
+	return p, nil
+}
+
+// description returns the description of the profile
+func (p *iccProfile) description() string {
+	data := p.tags["desc"]
+	if len(data) < 12 {
+		return ""
+	}
+
+	switch string(data[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(data[8:]))
+		if 12+n > len(data) {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimRight(string(data[12:12+n]), "\x00"))
+	case "mluc":
+		// the first record is used
+		if len(data) < 28 || binary.BigEndian.Uint32(data[8:]) == 0 {
+			return ""
+		}
+		n := int(binary.BigEndian.Uint32(data[20:]))
+		offset := int(binary.BigEndian.Uint32(data[24:]))
+		if offset+n > len(data) {
+			return ""
+		}
+		units := make([]uint16, n/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(data[offset+i*2:])
+		}
+		return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+	default:
+		return ""
+	}
+}
+
+// name returns the description of the profile or its color space
+func (p *iccProfile) name() string {
+	if desc := p.description(); desc != "" {
+		return desc
+	}
+
+	return strings.TrimSpace(p.colorSpace) + " profile"
+}
+
+// s15Fixed16 decodes a signed 15.16 fixed point number
+func s15Fixed16(bs []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(bs))) / 65536
+}
+
+// xyz returns the value of an XYZ tag
+func (p *iccProfile) xyz(tag string) ([3]float64, bool) {
+	data := p.tags[tag]
+	if len(data) < 20 || string(data[:4]) != "XYZ " {
+		return [3]float64{}, false
+	}
+
+	return [3]float64{s15Fixed16(data[8:]), s15Fixed16(data[12:]), s15Fixed16(data[16:])}, true
+}
+
+// iccCurve maps a normalized value of a channel
+type iccCurve func(x float64) float64
+
+// parseICCCurve parses a curv or para element and returns the curve
+// and the size of the element padded to 4 bytes
+func parseICCCurve(data []byte) (iccCurve, int, error) {
+	if len(data) < 12 {
+		return nil, 0, errInvalidICC
+	}
+
+	switch string(data[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(data[8:]))
+		size := 12 + n*2
+		if size > len(data) {
+			return nil, 0, errInvalidICC
+		}
+		size += size % 4
+
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, size, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(data[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, size, nil
+		}
+
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(data[12+i*2:])) / 65535
+		}
+		return func(x float64) float64 { return interpolateTable(table, x) }, size, nil
+
+	case "para":
+		paramCounts := []int{1, 3, 4, 5, 7}
+		fn := int(binary.BigEndian.Uint16(data[8:]))
+		if fn >= len(paramCounts) || 12+paramCounts[fn]*4 > len(data) {
+			return nil, 0, errInvalidICC
+		}
+
+		var v [7]float64
+		for i := range paramCounts[fn] {
+			v[i] = s15Fixed16(data[12+i*4:])
+		}
+		g, a, b, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+
+		size := 12 + paramCounts[fn]*4
+		curve := func(x float64) float64 {
+			switch fn {
+			case 0:
+				return math.Pow(x, g)
+			case 1:
+				if a*x+b >= 0 {
+					return math.Pow(a*x+b, g)
+				}
+				return 0
+			case 2:
+				if a*x+b >= 0 {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			case 3:
+				if x >= d {
+					return math.Pow(a*x+b, g)
+				}
+				return c * x
+			default:
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}
+		}
+		return curve, size, nil
+
+	default:
+		return nil, 0, errInvalidICC
+	}
+}
+
+// interpolateTable interpolates linearly the table of evenly spaced values
+func interpolateTable(table []float64, x float64) float64 {
+	pos := clamp01(x) * float64(len(table)-1)
+	i := min(int(pos), len(table)-2)
+	frac := pos - float64(i)
+
+	return table[i]*(1-frac) + table[i+1]*frac
+}
+
+// clamp01 clamps x to [0, 1], NaN of a broken curve is clamped to 0
+func clamp01(x float64) float64 {
+	if math.IsNaN(x) {
+		return 0
+	}
+
+	return min(max(x, 0), 1)
+}
+
+// iccLut is a lookup transform of a profile to the PCS: input curves,
+// color lookup table, middle curves, matrix and output curves applied
+// in order, the parts which are not used are nil.
+type iccLut struct {
+	inChans, outChans int
+
+	aCurves []iccCurve
+	grid    []int
+	clut    []float64
+	mCurves []iccCurve
+	matrix  []float64
+	bCurves []iccCurve
+
+	// legacyLab is set if the Lab values are in the legacy 16-bit encoding
+	legacyLab bool
+}
+
+// parseICCLut parses a lut8, lut16 or lutAtoB element
+func parseICCLut(data []byte) (*iccLut, error) {
+	if len(data) < 32 {
+		return nil, errInvalidICC
+	}
+
+	lut := &iccLut{inChans: int(data[8]), outChans: int(data[9])}
+	if lut.inChans < 1 || lut.inChans > 8 || lut.outChans != 3 {
+		return nil, errInvalidICC
+	}
+
+	var err error
+	switch string(data[:4]) {
+	case "mft1", "mft2":
+		err = lut.parseLegacy(data)
+	case "mAB ":
+		err = lut.parseAtoB(data)
+	default:
+		err = errInvalidICC
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lut, nil
+}
+
+// parseLegacy parses the tables of a lut8 or lut16 element,
+// the matrix is only used with XYZ input and is ignored
+func (lut *iccLut) parseLegacy(data []byte) error {
+	gridPoints := int(data[10])
+	if gridPoints < 2 {
+		return errInvalidICC
+	}
+
+	is16 := string(data[:4]) == "mft2"
+	lut.legacyLab = is16
+
+	inEntries, outEntries, pos, sampleSize := 256, 256, 48, 1
+	if is16 {
+		if len(data) < 52 {
+			return errInvalidICC
+		}
+		inEntries = int(binary.BigEndian.Uint16(data[48:]))
+		outEntries = int(binary.BigEndian.Uint16(data[50:]))
+		pos, sampleSize = 52, 2
+	}
+
+	if inEntries < 2 || outEntries < 2 {
+		return errInvalidICC
+	}
+
+	readTable := func(n int) ([]float64, error) {
+		if pos+n*sampleSize > len(data) {
+			return nil, errInvalidICC
+		}
+
+		table := make([]float64, n)
+		for i := range table {
+			if is16 {
+				table[i] = float64(binary.BigEndian.Uint16(data[pos+i*2:])) / 65535
+			} else {
+				table[i] = float64(data[pos+i]) / 255
+			}
+		}
+		pos += n * sampleSize
+
+		return table, nil
+	}
+
+	readCurves := func(chans, entries int) ([]iccCurve, error) {
+		curves := make([]iccCurve, chans)
+		for i := range curves {
+			table, err := readTable(entries)
+			if err != nil {
+				return nil, err
+			}
+			curves[i] = func(x float64) float64 { return interpolateTable(table, x) }
+		}
+		return curves, nil
+	}
+
+	var err error
+	if lut.aCurves, err = readCurves(lut.inChans, inEntries); err != nil {
+		return err
+	}
+
+	lut.grid = slices.Repeat([]int{gridPoints}, lut.inChans)
+	size, err := gridSize(lut.grid, lut.outChans)
+	if err != nil {
+		return err
+	}
+
+	if lut.clut, err = readTable(size); err != nil {
+		return err
+	}
+
+	if lut.bCurves, err = readCurves(lut.outChans, outEntries); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseAtoB parses the elements of a lutAtoB element
+func (lut *iccLut) parseAtoB(data []byte) error {
+	offsets := make([]int, 5)
+	for i := range offsets {
+		offsets[i] = int(binary.BigEndian.Uint32(data[12+i*4:]))
+		if offsets[i] >= len(data) {
+			return errInvalidICC
+		}
+	}
+	bOffset, matrixOffset, mOffset, clutOffset, aOffset := offsets[0], offsets[1],
+		offsets[2], offsets[3], offsets[4]
+
+	readCurves := func(offset, n int) ([]iccCurve, error) {
+		if offset == 0 {
+			return nil, nil
+		}
+
+		curves := make([]iccCurve, n)
+		for i := range curves {
+			curve, size, err := parseICCCurve(data[offset:])
+			if err != nil {
+				return nil, err
+			}
+			curves[i] = curve
+			offset += size
+			if offset > len(data) {
+				return nil, errInvalidICC
+			}
+		}
+		return curves, nil
+	}
+
+	var err error
+	if lut.bCurves, err = readCurves(bOffset, lut.outChans); err != nil {
+		return err
+	}
+	if lut.mCurves, err = readCurves(mOffset, lut.outChans); err != nil {
+		return err
+	}
+	if lut.aCurves, err = readCurves(aOffset, lut.inChans); err != nil {
+		return err
+	}
+
+	if matrixOffset != 0 {
+		if matrixOffset+48 > len(data) {
+			return errInvalidICC
+		}
+		lut.matrix = make([]float64, 12)
+		for i := range lut.matrix {
+			lut.matrix[i] = s15Fixed16(data[matrixOffset+i*4:])
+		}
+	}
+
+	if clutOffset == 0 {
+		if lut.inChans != lut.outChans {
+			return errInvalidICC
+		}
+		return nil
+	}
+
+	if clutOffset+20 > len(data) {
+		return errInvalidICC
+	}
+
+	lut.grid = make([]int, lut.inChans)
+	for i := range lut.grid {
+		lut.grid[i] = int(data[clutOffset+i])
+		if lut.grid[i] < 2 {
+			return errInvalidICC
+		}
+	}
+
+	size, err := gridSize(lut.grid, lut.outChans)
+	if err != nil {
+		return err
+	}
+
+	precision := int(data[clutOffset+16])
+	pos := clutOffset + 20
+	if (precision != 1 && precision != 2) || pos+size*precision > len(data) {
+		return errInvalidICC
+	}
+
+	lut.clut = make([]float64, size)
+	for i := range lut.clut {
+		if precision == 2 {
+			lut.clut[i] = float64(binary.BigEndian.Uint16(data[pos+i*2:])) / 65535
+		} else {
+			lut.clut[i] = float64(data[pos+i]) / 255
+		}
+	}
+
+	return nil
+}
+
+// gridSize returns the number of values of a color lookup table
+func gridSize(grid []int, outChans int) (int, error) {
+	size := outChans
+	for _, n := range grid {
+		size *= n
+		if size > iccMaxGridSize {
+			return 0, errInvalidICC
+		}
+	}
+
+	return size, nil
+}
+
+// eval transforms normalized input values to encoded PCS values
+func (lut *iccLut) eval(in []float64) [3]float64 {
+	values := make([]float64, len(in))
+	for i, x := range in {
+		values[i] = clamp01(x)
+		if lut.aCurves != nil {
+			values[i] = clamp01(lut.aCurves[i](values[i]))
+		}
+	}
+
+	var out [3]float64
+	if lut.clut != nil {
+		out = lut.interpolate(values)
+	} else {
+		copy(out[:], values)
+	}
+
+	if lut.mCurves != nil {
+		for i := range out {
+			out[i] = clamp01(lut.mCurves[i](out[i]))
+		}
+	}
+
+	if lut.matrix != nil {
+		m := lut.matrix
+		out = [3]float64{
+			clamp01(m[0]*out[0] + m[1]*out[1] + m[2]*out[2] + m[9]),
+			clamp01(m[3]*out[0] + m[4]*out[1] + m[5]*out[2] + m[10]),
+			clamp01(m[6]*out[0] + m[7]*out[1] + m[8]*out[2] + m[11]),
+		}
+	}
+
+	if lut.bCurves != nil {
+		for i := range out {
+			out[i] = clamp01(lut.bCurves[i](out[i]))
+		}
+	}
+
+	return out
+}
+
+// interpolate looks up the color lookup table with multilinear interpolation,
+// the first input channel varies the slowest
+func (lut *iccLut) interpolate(in []float64) [3]float64 {
+	n := len(lut.grid)
+	base := 0
+	stride := lut.outChans
+	strides := make([]int, n)
+	fracs := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		pos := in[i] * float64(lut.grid[i]-1)
+		idx := min(int(pos), lut.grid[i]-2)
+		fracs[i] = pos - float64(idx)
+		strides[i] = stride
+		base += idx * stride
+		stride *= lut.grid[i]
+	}
+
+	var out [3]float64
+	for corner := range 1 << n {
+		weight := 1.0
+		offset := base
+		for i := range n {
+			if corner&(1<<i) != 0 {
+				weight *= fracs[i]
+				offset += strides[i]
+			} else {
+				weight *= 1 - fracs[i]
+			}
+		}
+
+		if weight == 0 {
+			continue
+		}
+
+		for c := range out {
+			out[c] += weight * lut.clut[offset+c]
+		}
+	}
+
+	return out
+}
+
+// pcsToXYZ decodes PCS values of the lut to XYZ adapted to D50
+func (lut *iccLut) pcsToXYZ(pcs string, v [3]float64) [3]float64 {
+	if pcs == "XYZ " {
+		// the XYZ values are encoded with 1.0 as 0x8000
+		scale := 65535.0 / 32768
+		return [3]float64{v[0] * scale, v[1] * scale, v[2] * scale}
+	}
+
+	if lut.legacyLab {
+		// the legacy 16-bit encoding maps 0xff00 to the top of the range
+		for i := range v {
+			v[i] *= 65535.0 / 65280
+		}
+	}
+
+	l, a, b := v[0]*100, v[1]*255-128, v[2]*255-128
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+
+	finv := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+
+	return [3]float64{iccD50[0] * finv(fx), iccD50[1] * finv(fy), iccD50[2] * finv(fz)}
+}
+
+// srgbEncodeTable maps linear values to 8-bit sRGB values
+var srgbEncodeTable = func() []uint8 {
+	table := make([]uint8, srgbEncodeSize)
+	for i := range table {
+		table[i] = uint8(math.Round(srgbEncode(float64(i)/(srgbEncodeSize-1)) * 255))
+	}
+	return table
+}()
+
+// srgbEncode applies the sRGB transfer function to a linear value
+func srgbEncode(x float64) float64 {
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+// srgbDecode removes the sRGB transfer function from a value
+func srgbDecode(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// xyzToSRGB8 converts XYZ adapted to D50 to 8-bit sRGB values
+func xyzToSRGB8(v [3]float64) [3]uint8 {
+	var out [3]uint8
+	for c := range out {
+		m := xyzToSRGB[c]
+		linear := clamp01(m[0]*v[0] + m[1]*v[1] + m[2]*v[2])
+		out[c] = srgbEncodeTable[int(linear*(srgbEncodeSize-1)+0.5)]
+	}
+
+	return out
+}
+
+// rgbTransform converts the colors of a matrix/TRC RGB profile to sRGB
+type rgbTransform struct {
+	// linear are the values of the tone curves for the 8-bit values
+	linear [3][256]float64
+
+	// matrix converts the linear values to linear sRGB
+	matrix [3][3]float64
+}
+
+// newRGBTransform builds the transform of a matrix/TRC RGB profile,
+// nil is returned if the profile is close enough to sRGB
+func newRGBTransform(p *iccProfile) (*rgbTransform, error) {
+	var colorants [3][3]float64
+	var curves [3]iccCurve
+	for i, c := range []string{"r", "g", "b"} {
+		xyz, ok := p.xyz(c + "XYZ")
+		if !ok {
+			return nil, errInvalidICC
+		}
+		colorants[i] = xyz
+
+		curve, _, err := parseICCCurve(p.tags[c+"TRC"])
+		if err != nil {
+			return nil, err
+		}
+		curves[i] = curve
+	}
+
+	if isSRGBLike(colorants, curves) {
+		return nil, nil
+	}
+
+	t := &rgbTransform{}
+	for c := range 3 {
+		for v := range 256 {
+			t.linear[c][v] = curves[c](float64(v) / 255)
+		}
+	}
+
+	// the colorants are the columns of the matrix to XYZ
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				t.matrix[i][j] += xyzToSRGB[i][k] * colorants[j][k]
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// isSRGBLike reports whether the colorants and the tone curves
+// are the ones of sRGB within the precision of 8-bit values
+func isSRGBLike(colorants [3][3]float64, curves [3]iccCurve) bool {
+	const tolerance = 0.005
+
+	for i := range colorants {
+		for j := range colorants[i] {
+			if math.Abs(colorants[i][j]-srgbColorants[i][j]) > tolerance {
+				return false
+			}
+		}
+	}
+
+	for _, curve := range curves {
+		for x := 0.0; x <= 1; x += 0.125 {
+			if math.Abs(curve(x)-srgbDecode(x)) > tolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// apply converts the colors of the image
+func (t *rgbTransform) apply(img image.Image) *image.NRGBA {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := t.linear[0][c.R], t.linear[1][c.G], t.linear[2][c.B]
+
+		var out [3]uint8
+		for i := range out {
+			m := t.matrix[i]
+			linear := clamp01(m[0]*r + m[1]*g + m[2]*b)
+			out[i] = srgbEncodeTable[int(linear*(srgbEncodeSize-1)+0.5)]
+		}
+
+		return color.NRGBA{out[0], out[1], out[2], c.A}
+	})
+}
+
+// cmykTransform converts CMYK colors to sRGB with a table of sRGB colors
+// evaluated from the profile on a grid of CMYK colors
+type cmykTransform struct {
+	table [][3]uint8
+}
+
+// cmykTransforms caches the transforms of the CMYK profiles by the hash
+// of the profiles, since the pages of a book usually share one profile
+var cmykTransforms = struct {
+	mu sync.Mutex
+	m  map[[sha256.Size]byte]*cmykTransform
+}{m: make(map[[sha256.Size]byte]*cmykTransform)}
+
+// cachedCMYKTransform returns the transform of the CMYK profile p
+// parsed from profile, which is built once per profile
+func cachedCMYKTransform(profile []byte, p *iccProfile) (*cmykTransform, error) {
+	key := sha256.Sum256(profile)
+
+	cmykTransforms.mu.Lock()
+	t, ok := cmykTransforms.m[key]
+	cmykTransforms.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := newCMYKTransform(p)
+	if err != nil {
+		return nil, err
+	}
+
+	cmykTransforms.mu.Lock()
+	cmykTransforms.m[key] = t
+	cmykTransforms.mu.Unlock()
+
+	return t, nil
+}
+
+func newCMYKTransform(p *iccProfile) (*cmykTransform, error) {
+	var data []byte
+	for _, tag := range []string{"A2B0", "A2B1", "A2B2"} {
+		if data = p.tags[tag]; data != nil {
+			break
+		}
+	}
+
+	lut, err := parseICCLut(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if lut.inChans != 4 {
+		return nil, errInvalidICC
+	}
+
+	const n = cmykGridPoints
+	t := &cmykTransform{table: make([][3]uint8, n*n*n*n)}
+	in := make([]float64, 4)
+	for i := range t.table {
+		in[0] = float64(i/(n*n*n)) / (n - 1)
+		in[1] = float64(i/(n*n)%n) / (n - 1)
+		in[2] = float64(i/n%n) / (n - 1)
+		in[3] = float64(i%n) / (n - 1)
+		t.table[i] = xyzToSRGB8(lut.pcsToXYZ(p.pcs, lut.eval(in)))
+	}
+
+	return t, nil
+}
+
+// convert converts a CMYK color with multilinear interpolation of the table
+func (t *cmykTransform) convert(c color.CMYK) [3]uint8 {
+	const n = cmykGridPoints
+
+	in := [4]uint8{c.C, c.M, c.Y, c.K}
+	var idx [4]int
+	var frac [4]float64
+	for i, v := range in {
+		pos := float64(v) * (n - 1) / 255
+		idx[i] = min(int(pos), n-2)
+		frac[i] = pos - float64(idx[i])
+	}
+
+	var sum [3]float64
+	for corner := range 16 {
+		weight := 1.0
+		offset := 0
+		for i := range 4 {
+			bit := corner >> (3 - i) & 1
+			offset = offset*n + idx[i] + bit
+			if bit != 0 {
+				weight *= frac[i]
+			} else {
+				weight *= 1 - frac[i]
+			}
+		}
+
+		if weight == 0 {
+			continue
+		}
+
+		for ch := range sum {
+			sum[ch] += weight * float64(t.table[offset][ch])
+		}
+	}
+
+	return [3]uint8{uint8(sum[0] + 0.5), uint8(sum[1] + 0.5), uint8(sum[2] + 0.5)}
+}
+
+// convertCMYK converts a CMYK image to an sRGB image,
+// t nil converts the colors naively
+func convertCMYK(img *image.CMYK, t *cmykTransform) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if t == nil {
+		draw.Draw(dst, dst.Rect, img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	// the rows are converted serially since the images are already
+	// decoded by the workers of the caller
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			rgb := t.convert(img.CMYKAt(bounds.Min.X+x, bounds.Min.Y+y))
+			off := dst.PixOffset(x, y)
+			dst.Pix[off], dst.Pix[off+1], dst.Pix[off+2], dst.Pix[off+3] = rgb[0], rgb[1], rgb[2], 255
+		}
+	}
+
+	return dst
+}
+
+// readICCProfile returns the ICC profile embedded in the encoded image bs,
+// nil is returned if it has none
+func readICCProfile(bs []byte) []byte {
+	switch {
+	case isJPEG(bs):
+		return jpegICCProfile(bs)
+	case bytes.HasPrefix(bs, pngHeader):
+		return pngICCProfile(bs)
+	case isWebP(bs):
+		var profile []byte
+		webpChunks(bs, func(typ string, data []byte) bool {
+			if typ == "ICCP" {
+				profile = data
+				return false
+			}
+			return true
+		})
+		return profile
+	case isTIFF(bs):
+		offsets := tiffPageOffsets(bs)
+		if len(offsets) == 0 {
+			return nil
+		}
+		return tiffPageIFD(bs, offsets[0]).iccProfile()
+	default:
+		return nil
+	}
+}
+
+// jpegICCProfile joins the chunks of the ICC profile of a JPEG image
+func jpegICCProfile(bs []byte) []byte {
+	chunks := make(map[int][]byte)
+	total := 0
+	jpegSegments(bs, func(marker byte, payload []byte) bool {
+		if marker != jpegMarkerAPP2 || !bytes.HasPrefix(payload, iccHeader) ||
+			len(payload) < len(iccHeader)+2 {
+			return true
+		}
+
+		seq := int(payload[len(iccHeader)])
+		total = int(payload[len(iccHeader)+1])
+		chunks[seq] = payload[len(iccHeader)+2:]
+		return true
+	})
+
+	if total == 0 || len(chunks) != total {
+		return nil
+	}
+
+	var profile []byte
+	for seq := 1; seq <= total; seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+
+	return profile
+}
+
+// pngICCProfile decompresses the iCCP chunk of a PNG image
+func pngICCProfile(bs []byte) []byte {
+	var profile []byte
+	pngChunks(bs, func(typ string, data []byte) bool {
+		if typ != "iCCP" {
+			return true
+		}
+
+		// the profile name is followed by the compression method
+		i := bytes.IndexByte(data, 0)
+		if i < 0 || i+2 > len(data) {
+			return false
+		}
+
+		r, err := zlib.NewReader(bytes.NewReader(data[i+2:]))
+		if err != nil {
+			return false
+		}
+		defer r.Close()
+
+		profile, _ = io.ReadAll(r)
+		return false
+	})
+
+	return profile
+}
+
+// iccProfile returns the ICC profile of the IFD, nil if it has none
+func (d *exifData) iccProfile() []byte {
+	if d == nil {
+		return nil
+	}
+
+	field := d.ifd0.find(tiffTagICCProfile)
+	if field == nil {
+		return nil
+	}
+
+	return field.value
+}
+
+// toSRGB converts the colors of the image with the ICC profile to sRGB.
+// The CMYK images are converted naively if they have no usable profile.
+// The description of the conversion is returned, empty if the image
+// is not converted.
+func toSRGB(img image.Image, profile []byte) (image.Image, string) {
+	var p *iccProfile
+	if profile != nil {
+		// a broken profile is ignored like a missing one
+		p, _ = parseICC(profile)
+	}
+
+	if cmyk, ok := img.(*image.CMYK); ok {
+		if p != nil && p.colorSpace == "CMYK" {
+			t, err := cachedCMYKTransform(profile, p)
+			if err == nil {
+				return convertCMYK(cmyk, t), fmt.Sprintf("CMYK (%s) converted to sRGB", p.name())
+			}
+		}
+
+		return convertCMYK(cmyk, nil), "CMYK converted to sRGB without profile"
+	}
+
+	if p == nil || p.colorSpace != "RGB " {
+		return img, ""
+	}
+
+	t, err := newRGBTransform(p)
+	if err != nil || t == nil {
+		return img, ""
+	}
+
+	return t.apply(img), fmt.Sprintf("%s converted to sRGB", p.name())
+}
+
+// srgbProfile is a compact ICC profile of sRGB embedded in the exports
+var srgbProfile = sync.OnceValue(buildSRGBProfile)
+
+// buildSRGBProfile builds a version 2 matrix/TRC ICC profile of sRGB
+func buildSRGBProfile() []byte {
+	be := binary.BigEndian
+
+	xyzElement := func(v [3]float64) []byte {
+		data := []byte("XYZ \x00\x00\x00\x00")
+		for _, x := range v {
+			data = be.AppendUint32(data, uint32(int32(math.Round(x*65536))))
+		}
+		return data
+	}
+
+	const curvePoints = 1024
+	curve := []byte("curv\x00\x00\x00\x00")
+	curve = be.AppendUint32(curve, curvePoints)
+	for i := range curvePoints {
+		curve = be.AppendUint16(curve, uint16(math.Round(srgbDecode(float64(i)/(curvePoints-1))*65535)))
+	}
+
+	name := "sRGB IEC61966-2.1\x00"
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = be.AppendUint32(desc, uint32(len(name)))
+	desc = append(desc, name...)
+	// the empty Unicode and ScriptCode descriptions
+	desc = append(desc, make([]byte, 4+4+2+1+67)...)
+
+	cprt := append([]byte("text\x00\x00\x00\x00"), "No copyright, use freely\x00"...)
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", cprt},
+		{"wtpt", xyzElement(iccD50)},
+		{"rXYZ", xyzElement(srgbColorants[0])},
+		{"gXYZ", xyzElement(srgbColorants[1])},
+		{"bXYZ", xyzElement(srgbColorants[2])},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	table := be.AppendUint32(nil, uint32(len(tags)))
+	var elements []byte
+	dataStart := uint32(iccHeaderSize + 4 + len(tags)*12)
+	offset := dataStart
+	for i, tag := range tags {
+		// the tone curves share their element
+		if i == 0 || !bytes.Equal(tag.data, tags[i-1].data) {
+			offset = dataStart + uint32(len(elements))
+			elements = append(elements, tag.data...)
+			for len(elements)%4 != 0 {
+				elements = append(elements, 0)
+			}
+		}
+
+		table = append(table, tag.sig...)
+		table = be.AppendUint32(table, offset)
+		table = be.AppendUint32(table, uint32(len(tag.data)))
+	}
+
+	header := make([]byte, iccHeaderSize)
+	be.PutUint32(header[0:], dataStart+uint32(len(elements)))
+	be.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	for i, x := range iccD50 {
+		be.PutUint32(header[68+i*4:], uint32(int32(math.Round(x*65536))))
+	}
+
+	return slices.Concat(header, table, elements)
+}
+
+// jpegICCSegments returns the APP2 segments storing the ICC profile
+func jpegICCSegments(profile []byte) [][]byte {
+	const chunkSize = jpegMaxSegmentSize - 14
+
+	count := (len(profile) + chunkSize - 1) / chunkSize
+	segments := make([][]byte, 0, count)
+	for i := range count {
+		chunk := profile[i*chunkSize : min((i+1)*chunkSize, len(profile))]
+		payload := slices.Concat(iccHeader, []byte{byte(i + 1), byte(count)}, chunk)
+		segments = append(segments, jpegSegment(jpegMarkerAPP2, payload))
+	}
+
+	return segments
+}
@@ -0,0 +1,427 @@
+package imgutil
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+// testICCTag is a tag of a test ICC profile
+type testICCTag struct {
+	sig  string
+	data []byte
+}
+
+// buildTestICC builds an ICC profile of the color space with the tags
+func buildTestICC(colorSpace, pcs string, tags ...testICCTag) []byte {
+	be := binary.BigEndian
+
+	table := be.AppendUint32(nil, uint32(len(tags)))
+	var elements []byte
+	dataStart := iccHeaderSize + 4 + len(tags)*12
+	for _, tag := range tags {
+		table = append(table, tag.sig...)
+		table = be.AppendUint32(table, uint32(dataStart+len(elements)))
+		table = be.AppendUint32(table, uint32(len(tag.data)))
+
+		elements = append(elements, tag.data...)
+		for len(elements)%4 != 0 {
+			elements = append(elements, 0)
+		}
+	}
+
+	header := make([]byte, iccHeaderSize)
+	be.PutUint32(header[0:], uint32(dataStart+len(elements)))
+	copy(header[16:], colorSpace)
+	copy(header[20:], pcs)
+	copy(header[36:], "acsp")
+
+	return slices.Concat(header, table, elements)
+}
+
+// testXYZElement builds an XYZ element
+func testXYZElement(v [3]float64) []byte {
+	data := []byte("XYZ \x00\x00\x00\x00")
+	for _, x := range v {
+		data = binary.BigEndian.AppendUint32(data, uint32(int32(math.Round(x*65536))))
+	}
+	return data
+}
+
+// testCMYKLut builds a lutAtoB element with a 2-point 16-bit color lookup
+// table to XYZ, the corners hold the naive conversion of CMYK to sRGB
+func testCMYKLut() []byte {
+	be := binary.BigEndian
+
+	data := []byte("mAB \x00\x00\x00\x00")
+	data = append(data, 4, 3, 0, 0)
+	// only the color lookup table is used, it follows the offsets
+	data = be.AppendUint32(data, 0)
+	data = be.AppendUint32(data, 0)
+	data = be.AppendUint32(data, 0)
+	data = be.AppendUint32(data, 32)
+	data = be.AppendUint32(data, 0)
+
+	grid := make([]byte, 16)
+	copy(grid, []byte{2, 2, 2, 2})
+	data = append(data, grid...)
+	data = append(data, 2, 0, 0, 0)
+
+	for i := range 16 {
+		c, m, y, k := float64(i>>3&1), float64(i>>2&1), float64(i>>1&1), float64(i&1)
+		rgb := [3]float64{(1 - c) * (1 - k), (1 - m) * (1 - k), (1 - y) * (1 - k)}
+
+		// the linear sRGB values to XYZ, encoded with 1.0 as 0x8000
+		for j := range 3 {
+			var xyz float64
+			for ch := range 3 {
+				xyz += srgbColorants[ch][j] * rgb[ch]
+			}
+			data = be.AppendUint16(data, uint16(math.Round(xyz*32768)))
+		}
+	}
+
+	return data
+}
+
+// newTestCMYKProfile builds a CMYK profile converting naively to sRGB
+func newTestCMYKProfile() []byte {
+	return buildTestICC("CMYK", "XYZ ", testICCTag{"A2B0", testCMYKLut()})
+}
+
+func TestParseICCTruncated(t *testing.T) {
+	for _, profile := range [][]byte{srgbProfile(), newTestCMYKProfile()} {
+		for n := range len(profile) {
+			p, err := parseICC(profile[:n])
+			if n < iccHeaderSize+4 {
+				if err == nil {
+					t.Errorf("the profile of %d bytes is parsed", n)
+				}
+				continue
+			}
+			if err != nil {
+				continue
+			}
+
+			// the tags past the end are dropped
+			for sig, data := range p.tags {
+				if len(data) < 8 {
+					t.Errorf("the tag %q of the profile of %d bytes has %d bytes", sig, n, len(data))
+				}
+			}
+
+			// the transforms of a truncated profile fail
+			if p.colorSpace == "CMYK" {
+				if _, err := newCMYKTransform(p); err == nil {
+					t.Errorf("the CMYK transform of the profile of %d bytes is built", n)
+				}
+			} else if t2, err := newRGBTransform(p); err == nil && t2 != nil {
+				t.Errorf("the sRGB profile of %d bytes is converted", n)
+			}
+		}
+	}
+}
+
+func TestParseICCCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(bs []byte) []byte
+	}{
+		{"signature", func(bs []byte) []byte {
+			copy(bs[36:], "xxxx")
+			return bs
+		}},
+		{"tag count", func(bs []byte) []byte {
+			binary.BigEndian.PutUint32(bs[iccHeaderSize:], iccMaxTags+1)
+			return bs
+		}},
+		{"tag table past the end", func(bs []byte) []byte {
+			return bs[:iccHeaderSize+4+12*2]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseICC(tt.corrupt(slices.Clone(srgbProfile()))); err == nil {
+				t.Errorf("the corrupt profile is parsed")
+			}
+		})
+	}
+}
+
+func TestParseICCCurve(t *testing.T) {
+	be := binary.BigEndian
+
+	gamma := be.AppendUint32([]byte("curv\x00\x00\x00\x00"), 1)
+	gamma = be.AppendUint16(gamma, 2<<8)
+
+	table := be.AppendUint32([]byte("curv\x00\x00\x00\x00"), 3)
+	table = be.AppendUint16(table, 0)
+	table = be.AppendUint16(table, 0x4000)
+	table = be.AppendUint16(table, 0xffff)
+
+	para := append([]byte("para\x00\x00\x00\x00"), 0, 0, 0, 0)
+	para = be.AppendUint32(para, 3<<16)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		size     int
+		in, want float64
+	}{
+		{"identity", be.AppendUint32([]byte("curv\x00\x00\x00\x00"), 0), 12, 0.3, 0.3},
+		{"gamma", gamma, 16, 0.5, 0.25},
+		{"table", table, 20, 0.25, 0x2000 / 65535.0},
+		{"parametric", para, 16, 0.5, 0.125},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curve, size, err := parseICCCurve(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tt.size {
+				t.Errorf("got size %d, want %d", size, tt.size)
+			}
+			if got := curve(tt.in); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("got %v at %v, want %v", got, tt.in, tt.want)
+			}
+
+			// the truncated curves are rejected
+			if _, _, err := parseICCCurve(tt.data[:len(tt.data)-1]); err == nil && tt.size > 12 {
+				t.Errorf("the truncated curve is parsed")
+			}
+		})
+	}
+
+	unknown := append([]byte("para\x00\x00\x00\x00"), 0, 5, 0, 0)
+	unknown = append(unknown, make([]byte, 7*4)...)
+	if _, _, err := parseICCCurve(unknown); err == nil {
+		t.Errorf("the parametric curve of an unknown function is parsed")
+	}
+}
+
+func TestParseICCLutCorrupt(t *testing.T) {
+	valid := testCMYKLut()
+	if _, err := parseICCLut(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for n := range len(valid) {
+		if _, err := parseICCLut(valid[:n]); err == nil {
+			t.Errorf("the lut of %d bytes is parsed", n)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(bs []byte)
+	}{
+		{"no input channels", func(bs []byte) { bs[8] = 0 }},
+		{"output channels", func(bs []byte) { bs[9] = 4 }},
+		{"offset past the end", func(bs []byte) { binary.BigEndian.PutUint32(bs[24:], 1<<20) }},
+		{"grid of one point", func(bs []byte) { bs[32] = 1 }},
+		{"grid too large", func(bs []byte) { copy(bs[32:], []byte{255, 255, 255, 255}) }},
+		{"precision", func(bs []byte) { bs[48] = 3 }},
+		{"type", func(bs []byte) { copy(bs, "mBA ") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := slices.Clone(valid)
+			tt.corrupt(bs)
+			if _, err := parseICCLut(bs); err == nil {
+				t.Errorf("the corrupt lut is parsed")
+			}
+		})
+	}
+}
+
+func TestLutInterpolate(t *testing.T) {
+	// a grid of 2x3 points holding f(a, b) = (a, b, a*b) on the grid points,
+	// the first channel varies the slowest
+	lut := &iccLut{inChans: 2, outChans: 3, grid: []int{2, 3}}
+	for i := range 2 {
+		for j := range 3 {
+			a, b := float64(i), float64(j)/2
+			lut.clut = append(lut.clut, a, b, a*b)
+		}
+	}
+
+	tests := []struct {
+		in   []float64
+		want [3]float64
+	}{
+		// the grid points
+		{[]float64{0, 0}, [3]float64{0, 0, 0}},
+		{[]float64{0, 0.5}, [3]float64{0, 0.5, 0}},
+		{[]float64{1, 0.5}, [3]float64{1, 0.5, 0.5}},
+		{[]float64{1, 1}, [3]float64{1, 1, 1}},
+		// between the grid points, f is multilinear
+		{[]float64{0.5, 0.25}, [3]float64{0.5, 0.25, 0.125}},
+		{[]float64{0.25, 0.75}, [3]float64{0.25, 0.75, 0.1875}},
+	}
+
+	for _, tt := range tests {
+		got := lut.interpolate(tt.in)
+		for c := range got {
+			if math.Abs(got[c]-tt.want[c]) > 1e-9 {
+				t.Errorf("got %v at %v, want %v", got, tt.in, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLutEvalBrokenCurve(t *testing.T) {
+	// the parametric curve (-x)^0.5 is NaN for the positive values
+	para := append([]byte("para\x00\x00\x00\x00"), 0, 3, 0, 0)
+	for _, v := range []float64{0.5, -1, 0, 0, 0} {
+		para = binary.BigEndian.AppendUint32(para, uint32(int32(v*65536)))
+	}
+	curve, _, err := parseICCCurve(para)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lut := &iccLut{inChans: 1, outChans: 3, grid: []int{2}, clut: []float64{0, 0, 0, 1, 1, 1},
+		aCurves: []iccCurve{curve}}
+	if got := lut.eval([]float64{0.5}); got != [3]float64{} {
+		t.Errorf("got %v, want the first grid point", got)
+	}
+}
+
+func TestSRGBProfileRoundTrip(t *testing.T) {
+	p, err := parseICC(srgbProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.name(); got != "sRGB IEC61966-2.1" {
+		t.Errorf("got name %q", got)
+	}
+
+	// the profile of sRGB is left alone
+	tr, err := newRGBTransform(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr != nil {
+		t.Errorf("the sRGB profile has a transform")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	if got, desc := toSRGB(img, srgbProfile()); got != image.Image(img) || desc != "" {
+		t.Errorf("the sRGB image is converted: %q", desc)
+	}
+}
+
+func TestRGBTransformIdentity(t *testing.T) {
+	// the colorants of sRGB with linear tone curves are linear sRGB,
+	// which is converted by the transfer function of sRGB alone
+	linear := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00")
+	profile := buildTestICC("RGB ", "XYZ ",
+		testICCTag{"rXYZ", testXYZElement(srgbColorants[0])},
+		testICCTag{"gXYZ", testXYZElement(srgbColorants[1])},
+		testICCTag{"bXYZ", testXYZElement(srgbColorants[2])},
+		testICCTag{"rTRC", linear},
+		testICCTag{"gTRC", linear},
+		testICCTag{"bTRC", linear},
+	)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := range 256 {
+		img.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(255 - x), uint8(x / 2), 255})
+	}
+
+	got, desc := toSRGB(img, profile)
+	if desc == "" {
+		t.Fatal("the image is not converted")
+	}
+
+	for x := range 256 {
+		in := img.NRGBAAt(x, 0)
+		out := color.NRGBAModel.Convert(got.At(x, 0)).(color.NRGBA)
+		for c, v := range []uint8{in.R, in.G, in.B} {
+			want := math.Round(srgbEncode(float64(v)/255) * 255)
+			o := []uint8{out.R, out.G, out.B}[c]
+			if math.Abs(float64(o)-want) > 1 {
+				t.Errorf("channel %d of %v is %d, want %v", c, in, o, want)
+			}
+		}
+	}
+}
+
+func TestCMYKToSRGB(t *testing.T) {
+	profile := newTestCMYKProfile()
+
+	tests := []struct {
+		name string
+		cmyk color.CMYK
+		want [3]uint8
+	}{
+		{"white", color.CMYK{0, 0, 0, 0}, [3]uint8{255, 255, 255}},
+		{"cyan", color.CMYK{255, 0, 0, 0}, [3]uint8{0, 255, 255}},
+		{"magenta", color.CMYK{0, 255, 0, 0}, [3]uint8{255, 0, 255}},
+		{"yellow", color.CMYK{0, 0, 255, 0}, [3]uint8{255, 255, 0}},
+		{"black", color.CMYK{0, 0, 0, 255}, [3]uint8{0, 0, 0}},
+		{"red", color.CMYK{0, 255, 255, 0}, [3]uint8{255, 0, 0}},
+	}
+
+	img := image.NewCMYK(image.Rect(0, 0, len(tests), 1))
+	for i, tt := range tests {
+		img.SetCMYK(i, 0, tt.cmyk)
+	}
+
+	got, desc := toSRGB(img, profile)
+	if desc != "CMYK (CMYK profile) converted to sRGB" {
+		t.Errorf("got description %q", desc)
+	}
+
+	for i, tt := range tests {
+		c := color.NRGBAModel.Convert(got.At(i, 0)).(color.NRGBA)
+		for ch, v := range []uint8{c.R, c.G, c.B} {
+			if d := int(v) - int(tt.want[ch]); d < -2 || d > 2 {
+				t.Errorf("%s is converted to %v, want %v", tt.name, c, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func FuzzParseICC(f *testing.F) {
+	f.Add(srgbProfile())
+	f.Add(newTestCMYKProfile())
+
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		p, err := parseICC(bs)
+		if err != nil {
+			return
+		}
+
+		_ = p.name()
+		_, _ = newRGBTransform(p)
+
+		for _, data := range p.tags {
+			if curve, _, err := parseICCCurve(data); err == nil {
+				_ = curve(0.5)
+			}
+
+			lut, err := parseICCLut(data)
+			if err != nil {
+				continue
+			}
+			for _, v := range []float64{0, 0.5, 1} {
+				in := slices.Repeat([]float64{v}, lut.inChans)
+				_ = xyzToSRGB8(lut.pcsToXYZ(p.pcs, lut.eval(in)))
+			}
+		}
+	})
+}
@@ -1,12 +1,15 @@
 package imgutil
 
 import (
+	"bytes"
+	"encoding/json"
 	"image"
-	"image/draw"
+	"image/png"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/VoileLab/goimgpack/internal/util"
@@ -15,16 +18,56 @@ import (
 var SupportedArchiveExts = []string{".zip", ".cbz"}
 var SupportedPDFExts = []string{".pdf"}
 
-// Image stores all the information of an image
+// Image stores all the information of an image.
+// Only the compressed original source of the image and the edit operations
+// applied to it are kept, the image is rendered on demand and the rendered
+// image is kept in a memory-bounded cache.
 type Image struct {
 	// Filename is the base name of the image file without the extension
 	Filename string
 
-	// Img is the image.Image object of the image
-	Img image.Image
-
-	// Type is the type of the image
+	// Type is the type of the original image
 	Type string
+
+	// Color describes the conversion of the colors of the original image
+	// to sRGB, empty if the colors are not converted
+	Color string
+
+	// Frames is the number of frames of the original animated image
+	// of which only the first frame is kept, zero if it is not animated
+	Frames int
+
+	// Delay is the delay in 100ths of a second of a frame
+	// expanded from an animated image, zero if unknown
+	Delay int
+
+	// Provenance is where the image was read from
+	Provenance Provenance
+
+	// SplitBefore starts a new volume at the image
+	// when the export is split at the markers
+	SplitBefore bool
+
+	// src is the compressed original source of the image, it is never modified
+	src *source
+
+	// meta is the metadata of the original image, nil if it has none
+	meta *Metadata
+
+	// srcBounds is the bounds of the decoded original image
+	srcBounds image.Rectangle
+
+	// ops is the edit operations applied in order on the original image
+	ops []Op
+
+	// bounds is the bounds of the rendered image
+	bounds image.Rectangle
+}
+
+// renderKey is the cache key of an image rendered from src with ops
+type renderKey struct {
+	src *source
+	ops string
 }
 
 // NewImgByFilepath creates an Image object from a file path
@@ -35,35 +78,180 @@ func NewImgByFilepath(filepath string) (*Image, error) {
 	}
 	defer f.Close()
 
-	return NewImg(f, path.Base(filepath))
+	img, err := NewImg(f, path.Base(filepath))
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	img.Provenance.Path = filepath
+
+	return img, nil
 }
 
 // NewImg creates an Image object from an io.Reader
 func NewImg(r io.Reader, filename string) (*Image, error) {
-	img, imgType, err := decodeImage(r)
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	img, err := newImgFromBytes(bs, filename)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return img, nil
+}
+
+// newImgFromBytes creates an Image object from the encoded image bs
+func newImgFromBytes(bs []byte, filename string) (*Image, error) {
+	// the image is decoded once to validate it
+	img, imgType, conversion, err := decodeImage(bs)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
 
+	src, err := newSource(bs)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	imgCache.put(src, img)
+
 	// remove ext of filename
 	filename = strings.TrimSuffix(filename, filepath.Ext(filename))
 
 	return &Image{
 		Filename: filename,
-		Img:      img,
 		Type:     imgType,
+		Color:    conversion,
+		Provenance: Provenance{
+			Size: int64(len(bs)),
+		},
+		src:       src,
+		meta:      readMetadata(bs),
+		srcBounds: img.Bounds(),
+		bounds:    img.Bounds(),
 	}, nil
 }
 
-// Clone deep copies the image
-func (img *Image) Clone() *Image {
-	bounds := img.Img.Bounds()
-	clone := image.NewRGBA(bounds)
-	draw.Draw(clone, bounds, img.Img, bounds.Min, draw.Src)
+// newImgFromImage creates an Image object from a decoded image,
+// which is stored losslessly as the source of the image
+func newImgFromImage(decoded image.Image, filename string) (*Image, error) {
+	buf := new(bytes.Buffer)
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(buf, decoded); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	src, err := newSource(buf.Bytes())
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	imgCache.put(src, decoded)
 
 	return &Image{
-		Filename: img.Filename,
-		Img:      clone,
-		Type:     img.Type,
+		Filename:  filename,
+		Type:      formatPNG,
+		src:       src,
+		srcBounds: decoded.Bounds(),
+		bounds:    decoded.Bounds(),
+	}, nil
+}
+
+// Img returns the image rendered with its edit operations
+func (img *Image) Img() (image.Image, error) {
+	if len(img.ops) == 0 {
+		return img.Original()
 	}
+
+	key, err := img.renderKey()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	if rendered, ok := imgCache.get(key); ok {
+		return rendered, nil
+	}
+
+	original, err := img.Original()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	rendered := applyOps(original, img.ops)
+	imgCache.put(key, rendered)
+
+	return rendered, nil
+}
+
+// Original returns the decoded original image without the edit operations
+func (img *Image) Original() (image.Image, error) {
+	if decoded, ok := imgCache.get(img.src); ok {
+		return decoded, nil
+	}
+
+	bs, err := img.src.bytes()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	decoded, _, _, err := decodeImage(bs)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	imgCache.put(img.src, decoded)
+
+	return decoded, nil
+}
+
+func (img *Image) renderKey() (renderKey, error) {
+	bs, err := json.Marshal(img.ops)
+	if err != nil {
+		return renderKey{}, util.Errorf("%w", err)
+	}
+
+	return renderKey{src: img.src, ops: string(bs)}, nil
+}
+
+// Ops returns a copy of the edit operations of the image
+func (img *Image) Ops() []Op {
+	return slices.Clone(img.ops)
+}
+
+// SetOps replaces the edit operations of the image
+func (img *Image) SetOps(ops []Op) {
+	img.ops = slices.Clone(ops)
+	img.bounds = opsBounds(img.srcBounds, img.ops)
+}
+
+// AddOp appends an edit operation to the image
+func (img *Image) AddOp(op Op) {
+	img.SetOps(append(img.Ops(), op))
+}
+
+// Reset removes the edit operations of the image except its splits
+func (img *Image) Reset() {
+	img.SetOps(ResetOps(img.ops))
+}
+
+// IsEdited reports whether the image has edit operations
+func (img *Image) IsEdited() bool {
+	return len(img.ops) > 0
+}
+
+// Metadata returns the metadata of the original image, nil if it has none
+func (img *Image) Metadata() *Metadata {
+	return img.meta
+}
+
+// Bounds returns the bounds of the rendered image without rendering it
+func (img *Image) Bounds() image.Rectangle {
+	return img.bounds
+}
+
+// Clone copies the image,
+// the source is shared since it is never modified
+func (img *Image) Clone() *Image {
+	clone := *img
+	clone.ops = slices.Clone(img.ops)
+	return &clone
 }
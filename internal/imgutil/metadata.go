@@ -0,0 +1,414 @@
+package imgutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// MetadataMode is how the metadata of the images is exported
+type MetadataMode int
+
+const (
+	// MetadataStrip removes all the metadata for privacy
+	MetadataStrip MetadataMode = iota
+
+	// MetadataKeep copies the EXIF and XMP metadata into the exported JPEG
+	MetadataKeep
+
+	// MetadataStripGPS copies the EXIF and XMP metadata except the GPS
+	// location, the other metadata which may hold it is removed
+	MetadataStripGPS
+)
+
+// MetadataModeNames are the names of MetadataMode indexed by the value
+var MetadataModeNames = []string{"Strip All", "Keep", "Strip GPS Only"}
+
+const (
+	jpegMarkerSOS  = 0xda
+	jpegMarkerAPP0 = 0xe0
+	jpegMarkerAPP1 = 0xe1
+	jpegMarkerAPP2 = 0xe2
+
+	// jpegMarkerAPP13 stores the IPTC metadata in Photoshop resources
+	jpegMarkerAPP13 = 0xed
+
+	// jpegMarkerCOM stores a text comment
+	jpegMarkerCOM = 0xfe
+
+	// jpegMaxSegmentSize is the largest payload of a JPEG segment
+	jpegMaxSegmentSize = 0xffff - 2
+
+	// tiffTagXMP is the TIFF tag storing an XMP packet
+	tiffTagXMP = 700
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+	xmpKeyword = []byte("XML:com.adobe.xmp\x00")
+
+	// xmpExtensionHeader starts the parts of an XMP packet
+	// too large for a single segment
+	xmpExtensionHeader = []byte("http://ns.adobe.com/xmp/extension/\x00")
+)
+
+// Metadata is the EXIF and XMP metadata of an image
+type Metadata struct {
+	exif *exifData
+	xmp  []byte
+}
+
+// MetadataField is a piece of metadata described for the user
+type MetadataField struct {
+	Name  string
+	Value string
+}
+
+// Fields describes the metadata of the image,
+// nil is returned if the image has no metadata
+func (m *Metadata) Fields() []MetadataField {
+	if m == nil {
+		return nil
+	}
+
+	var fields []MetadataField
+	if m.exif != nil {
+		fields = m.exif.fields()
+		if len(fields) == 0 {
+			fields = append(fields, MetadataField{Name: "EXIF", Value: "present"})
+		}
+	}
+
+	if m.xmp != nil {
+		fields = append(fields, MetadataField{Name: "XMP", Value: fmt.Sprintf("%d bytes", len(m.xmp))})
+	}
+
+	return fields
+}
+
+// orientation returns the EXIF orientation, 1 if unknown
+func (m *Metadata) orientation() int {
+	if m == nil {
+		return 1
+	}
+
+	return m.exif.orientation()
+}
+
+// readMetadata reads the EXIF and XMP metadata of the encoded image bs,
+// nil is returned if the image has none
+func readMetadata(bs []byte) *Metadata {
+	var tiff, xmp []byte
+	switch {
+	case isJPEG(bs):
+		tiff, xmp = jpegMetadata(bs)
+	case bytes.HasPrefix(bs, pngHeader):
+		tiff, xmp = pngMetadata(bs)
+	case isWebP(bs):
+		tiff, xmp = webpMetadata(bs)
+	case isTIFF(bs):
+		tiff = bs
+	}
+
+	m := &Metadata{}
+	if tiff != nil {
+		// a broken EXIF structure is ignored like a missing one
+		m.exif, _ = parseExif(tiff)
+	}
+
+	if isTIFF(bs) && m.exif != nil {
+		if field := m.exif.ifd0.find(tiffTagXMP); field != nil {
+			xmp = field.value
+		}
+	}
+
+	if len(xmp) > 0 {
+		m.xmp = bytes.Clone(xmp)
+	}
+
+	if m.exif == nil && m.xmp == nil {
+		return nil
+	}
+
+	return m
+}
+
+// isJPEG reports whether bs is a JPEG image
+func isJPEG(bs []byte) bool {
+	return bytes.HasPrefix(bs, []byte{0xff, 0xd8})
+}
+
+// jpegSegments calls yield with the marker and the payload of each
+// segment of a JPEG image before the image data
+func jpegSegments(bs []byte, yield func(marker byte, payload []byte) bool) {
+	pos := 2
+	for pos+4 <= len(bs) {
+		if bs[pos] != 0xff {
+			return
+		}
+
+		marker := bs[pos+1]
+		if marker == jpegMarkerSOS {
+			return
+		}
+
+		segLen := int(binary.BigEndian.Uint16(bs[pos+2:]))
+		end := pos + 2 + segLen
+		if segLen < 2 || end > len(bs) {
+			return
+		}
+
+		if !yield(marker, bs[pos+4:end]) {
+			return
+		}
+
+		pos = end
+	}
+}
+
+// isJPEGMetadata reports whether the segment stores metadata:
+// EXIF, XMP, extended XMP, IPTC or a comment
+func isJPEGMetadata(marker byte, payload []byte) bool {
+	switch marker {
+	case jpegMarkerAPP1:
+		return bytes.HasPrefix(payload, exifHeader) || bytes.HasPrefix(payload, xmpHeader) ||
+			bytes.HasPrefix(payload, xmpExtensionHeader)
+	case jpegMarkerAPP13, jpegMarkerCOM:
+		return true
+	}
+
+	return false
+}
+
+// jpegMetadata returns the EXIF and XMP metadata of a JPEG image
+func jpegMetadata(bs []byte) (tiff, xmp []byte) {
+	jpegSegments(bs, func(marker byte, payload []byte) bool {
+		if marker != jpegMarkerAPP1 {
+			return true
+		}
+
+		if tiff == nil && bytes.HasPrefix(payload, exifHeader) {
+			tiff = payload[len(exifHeader):]
+		}
+
+		if xmp == nil && bytes.HasPrefix(payload, xmpHeader) {
+			xmp = payload[len(xmpHeader):]
+		}
+
+		return true
+	})
+
+	return tiff, xmp
+}
+
+// pngChunks calls yield with the type and the data of each chunk
+// of a PNG image
+func pngChunks(bs []byte, yield func(typ string, data []byte) bool) {
+	pos := len(pngHeader)
+	for pos+8 <= len(bs) {
+		size := int(binary.BigEndian.Uint32(bs[pos:]))
+		typ := string(bs[pos+4 : pos+8])
+		end := pos + 8 + size
+		if size < 0 || end+4 > len(bs) || typ == "IEND" {
+			return
+		}
+
+		if !yield(typ, bs[pos+8:end]) {
+			return
+		}
+
+		// the chunk is followed by its CRC
+		pos = end + 4
+	}
+}
+
+// pngMetadata returns the eXIf chunk and the XMP packet of a PNG image
+func pngMetadata(bs []byte) (tiff, xmp []byte) {
+	pngChunks(bs, func(typ string, data []byte) bool {
+		switch {
+		case typ == "eXIf":
+			tiff = data
+		case typ == "iTXt" && bytes.HasPrefix(data, xmpKeyword):
+			xmp = pngText(data[len(xmpKeyword):])
+		}
+
+		return true
+	})
+
+	return tiff, xmp
+}
+
+// pngText returns the text of an iTXt chunk after its keyword
+func pngText(data []byte) []byte {
+	if len(data) < 2 {
+		return nil
+	}
+
+	compressed := data[0] == 1
+	rest := data[2:]
+
+	// the language tag and the translated keyword
+	for range 2 {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return nil
+		}
+		rest = rest[i+1:]
+	}
+
+	if !compressed {
+		return rest
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+
+	return text
+}
+
+// isWebP reports whether bs is a WebP image
+func isWebP(bs []byte) bool {
+	return len(bs) >= 12 && string(bs[:4]) == "RIFF" && string(bs[8:12]) == "WEBP"
+}
+
+// webpChunks calls yield with the type and the data of each chunk
+// of a WebP image
+func webpChunks(bs []byte, yield func(typ string, data []byte) bool) {
+	pos := 12
+	for pos+8 <= len(bs) {
+		typ := string(bs[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(bs[pos+4:]))
+		end := pos + 8 + size
+		if size < 0 || end > len(bs) {
+			return
+		}
+
+		if !yield(typ, bs[pos+8:end]) {
+			return
+		}
+
+		// the chunks are padded to an even size
+		pos = end + size%2
+	}
+}
+
+// webpMetadata returns the EXIF and XMP chunks of a WebP image
+func webpMetadata(bs []byte) (tiff, xmp []byte) {
+	webpChunks(bs, func(typ string, data []byte) bool {
+		switch typ {
+		case "EXIF":
+			// some encoders keep the header of the JPEG segment
+			tiff = bytes.TrimPrefix(data, exifHeader)
+		case "XMP ":
+			xmp = data
+		}
+
+		return true
+	})
+
+	return tiff, xmp
+}
+
+// orientImage transforms the image so that it is upright
+// according to its EXIF orientation
+func orientImage(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// jpegMetadataSegments returns the APP1 segments storing the metadata
+// of an upright JPEG image exported with mode
+func jpegMetadataSegments(m *Metadata, mode MetadataMode) [][]byte {
+	if m == nil || mode == MetadataStrip {
+		return nil
+	}
+
+	var segments [][]byte
+	if m.exif != nil {
+		tiff := m.exif.encode(mode == MetadataKeep)
+		payload := append(bytes.Clone(exifHeader), tiff...)
+		if tiff != nil && len(payload) <= jpegMaxSegmentSize {
+			segments = append(segments, jpegSegment(jpegMarkerAPP1, payload))
+		}
+	}
+
+	// the XMP packet may repeat the location, it is dropped with it
+	keepXMP := mode == MetadataKeep || !bytes.Contains(m.xmp, []byte("exif:GPS"))
+	if m.xmp != nil && keepXMP {
+		payload := append(bytes.Clone(xmpHeader), m.xmp...)
+		if len(payload) <= jpegMaxSegmentSize {
+			segments = append(segments, jpegSegment(jpegMarkerAPP1, payload))
+		}
+	}
+
+	return segments
+}
+
+// jpegSegment builds a JPEG segment with its marker and length
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// replaceJPEGMetadata returns the JPEG image bs with its metadata
+// segments replaced by segments, which are placed after the JFIF segment
+func replaceJPEGMetadata(bs []byte, segments [][]byte) []byte {
+	out := make([]byte, 0, len(bs))
+	out = append(out, bs[:2]...)
+
+	pos := 2
+	inserted := false
+	jpegSegments(bs, func(marker byte, payload []byte) bool {
+		end := pos + 4 + len(payload)
+
+		if !inserted && marker != jpegMarkerAPP0 {
+			out = append(out, bytes.Join(segments, nil)...)
+			inserted = true
+		}
+
+		if !isJPEGMetadata(marker, payload) {
+			out = append(out, bs[pos:end]...)
+		}
+
+		pos = end
+		return true
+	})
+
+	if !inserted {
+		out = append(out, bytes.Join(segments, nil)...)
+	}
+
+	return append(out, bs[pos:]...)
+}
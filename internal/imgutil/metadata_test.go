@@ -0,0 +1,337 @@
+package imgutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const (
+	testXMP    = `<x:xmpmeta><rdf:Description dc:title="book"/></x:xmpmeta>`
+	testGPSXMP = `<x:xmpmeta><rdf:Description exif:GPSLatitude="35,30N"/></x:xmpmeta>`
+)
+
+// encodeTestJPEG encodes a small JPEG image without metadata
+func encodeTestJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// pngChunk builds a PNG chunk with its length and CRC
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// encodeTestPNG encodes a small PNG image with the chunks inserted after IHDR
+func encodeTestPNG(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	bs := buf.Bytes()
+
+	// the header is followed by IHDR with 13 bytes of data
+	ihdrEnd := len(pngHeader) + 8 + 13 + 4
+	out := append([]byte{}, bs[:ihdrEnd]...)
+	out = append(out, bytes.Join(chunks, nil)...)
+	return append(out, bs[ihdrEnd:]...)
+}
+
+// webpChunk builds a WebP chunk padded to an even size
+func webpChunk(typ string, data []byte) []byte {
+	chunk := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// newTestWebP builds a WebP container of the chunks, no image is decoded
+// when the metadata is read
+func newTestWebP(chunks ...[]byte) []byte {
+	body := append([]byte("WEBP"), bytes.Join(chunks, nil)...)
+	bs := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(bs, body...)
+}
+
+// checkTestMetadata checks that m is the metadata of newTestExif and xmp
+func checkTestMetadata(t *testing.T, m *Metadata, xmp string) {
+	t.Helper()
+
+	if m == nil {
+		t.Fatal("no metadata is read")
+	}
+
+	if got := m.orientation(); got != 6 {
+		t.Errorf("orientation is %d, want 6", got)
+	}
+
+	if got := fieldValue(m.Fields(), "Make"); got != "Canon" {
+		t.Errorf("Make is %q, want Canon", got)
+	}
+
+	if string(m.xmp) != xmp {
+		t.Errorf("XMP is %q, want %q", m.xmp, xmp)
+	}
+}
+
+func TestReadMetadataJPEG(t *testing.T) {
+	tiff := newTestExif(binary.BigEndian)
+	bs := replaceJPEGMetadata(encodeTestJPEG(t), [][]byte{
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(exifHeader), tiff...)),
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(xmpHeader), testXMP...)),
+	})
+
+	if _, err := jpeg.Decode(bytes.NewReader(bs)); err != nil {
+		t.Fatalf("the JPEG image is broken: %v", err)
+	}
+
+	checkTestMetadata(t, readMetadata(bs), testXMP)
+}
+
+func TestReadMetadataPNG(t *testing.T) {
+	tiff := newTestExif(binary.LittleEndian)
+
+	itxt := func(compressed bool) []byte {
+		data := append(bytes.Clone(xmpKeyword), 0, 0, 0, 0)
+		if !compressed {
+			return append(data, testXMP...)
+		}
+
+		data[len(xmpKeyword)] = 1
+		buf := new(bytes.Buffer)
+		w := zlib.NewWriter(buf)
+		w.Write([]byte(testXMP))
+		w.Close()
+		return append(data, buf.Bytes()...)
+	}
+
+	for name, compressed := range map[string]bool{"plain XMP": false, "compressed XMP": true} {
+		t.Run(name, func(t *testing.T) {
+			bs := encodeTestPNG(t, pngChunk("eXIf", tiff), pngChunk("iTXt", itxt(compressed)))
+
+			if _, err := png.Decode(bytes.NewReader(bs)); err != nil {
+				t.Fatalf("the PNG image is broken: %v", err)
+			}
+
+			checkTestMetadata(t, readMetadata(bs), testXMP)
+		})
+	}
+}
+
+func TestReadMetadataWebP(t *testing.T) {
+	tiff := newTestExif(binary.LittleEndian)
+
+	for name, exif := range map[string][]byte{
+		"raw":            tiff,
+		"with header":    append(bytes.Clone(exifHeader), tiff...),
+		"odd chunk size": append(bytes.Clone(tiff), 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			bs := newTestWebP(
+				webpChunk("VP8X", make([]byte, 10)),
+				webpChunk("EXIF", exif),
+				webpChunk("XMP ", []byte(testXMP)),
+			)
+
+			checkTestMetadata(t, readMetadata(bs), testXMP)
+		})
+	}
+}
+
+func TestReadMetadataTruncated(t *testing.T) {
+	tiff := newTestExif(binary.LittleEndian)
+
+	jpegBs := replaceJPEGMetadata(encodeTestJPEG(t), [][]byte{
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(exifHeader), tiff...)),
+	})
+	pngBs := encodeTestPNG(t, pngChunk("eXIf", tiff))
+	webpBs := newTestWebP(webpChunk("EXIF", tiff))
+
+	// the lengths of the metadata blocks point past the end of the data
+	for name, bs := range map[string][]byte{
+		"JPEG": jpegBs[:len(jpegBs)/3],
+		"PNG":  pngBs[:len(pngHeader)+8+13+4+8+len(tiff)/2],
+		"WebP": webpBs[:len(webpBs)-10],
+	} {
+		t.Run(name, func(t *testing.T) {
+			if m := readMetadata(bs); m != nil {
+				t.Errorf("metadata %v is read from a truncated block", m.Fields())
+			}
+		})
+	}
+
+	// a huge chunk size does not overflow the position
+	huge := newTestWebP(webpChunk("EXIF", tiff))
+	binary.LittleEndian.PutUint32(huge[16:], 0xffffffff)
+	if m := readMetadata(huge); m != nil {
+		t.Errorf("metadata %v is read from a broken chunk", m.Fields())
+	}
+}
+
+func TestJPEGMetadataSegments(t *testing.T) {
+	exif, err := parseExif(newTestExif(binary.LittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		xmp      string
+		mode     MetadataMode
+		wantGPS  bool
+		wantXMP  bool
+		wantNone bool
+	}{
+		{"strip", testXMP, MetadataStrip, false, false, true},
+		{"keep", testGPSXMP, MetadataKeep, true, true, false},
+		{"strip GPS", testXMP, MetadataStripGPS, false, true, false},
+		{"strip GPS in XMP", testGPSXMP, MetadataStripGPS, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Metadata{exif: exif, xmp: []byte(tt.xmp)}
+			segments := jpegMetadataSegments(m, tt.mode)
+			if tt.wantNone {
+				if segments != nil {
+					t.Fatalf("got %d segments, want none", len(segments))
+				}
+				return
+			}
+
+			bs := replaceJPEGMetadata(encodeTestJPEG(t), segments)
+			if _, err := jpeg.Decode(bytes.NewReader(bs)); err != nil {
+				t.Fatalf("the JPEG image is broken: %v", err)
+			}
+
+			got := readMetadata(bs)
+			if got == nil || got.exif == nil {
+				t.Fatal("no EXIF data is written")
+			}
+
+			if hasGPS := got.exif.gps != nil; hasGPS != tt.wantGPS {
+				t.Errorf("GPS IFD kept is %v, want %v", hasGPS, tt.wantGPS)
+			}
+
+			if hasXMP := got.xmp != nil; hasXMP != tt.wantXMP {
+				t.Errorf("XMP kept is %v, want %v", hasXMP, tt.wantXMP)
+			}
+
+			if got.orientation() != 1 {
+				t.Errorf("orientation is %d, want 1", got.orientation())
+			}
+
+			// the segments replace the old ones instead of being added
+			again := readMetadata(replaceJPEGMetadata(bs, segments))
+			if len(again.Fields()) != len(got.Fields()) {
+				t.Errorf("the metadata changed when it is replaced again")
+			}
+		})
+	}
+}
+
+// jpegMarkers returns the markers and the payloads of the segments
+// of a JPEG image before the image data
+func jpegMarkers(bs []byte) ([]byte, [][]byte) {
+	var markers []byte
+	var payloads [][]byte
+	jpegSegments(bs, func(marker byte, payload []byte) bool {
+		markers = append(markers, marker)
+		payloads = append(payloads, payload)
+		return true
+	})
+
+	return markers, payloads
+}
+
+func TestEncodeJPEGPassthroughMetadata(t *testing.T) {
+	order := binary.LittleEndian
+	tiff := buildTestExif(order,
+		[]exifField{testASCII(exifTagMake, "Canon"), testShort(order, exifTagOrientation, 1)},
+		nil,
+		[]exifField{testASCII(exifTagGPSLatitudeRef, "N")})
+
+	iptc := append([]byte("Photoshop 3.0\x00"), "8BIM caption: at home"...)
+	extXMP := append(bytes.Clone(xmpExtensionHeader), `exif:GPSLatitude="35,30N"`...)
+	src := replaceJPEGMetadata(encodeTestJPEG(t), [][]byte{
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(exifHeader), tiff...)),
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(xmpHeader), testGPSXMP...)),
+		jpegSegment(jpegMarkerAPP1, extXMP),
+		jpegSegment(jpegMarkerAPP13, iptc),
+		jpegSegment(jpegMarkerCOM, []byte("taken at home")),
+	})
+
+	img, err := NewImg(bytes.NewReader(src), "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image data after the segments
+	_, srcPayloads := jpegMarkers(src)
+	last := srcPayloads[len(srcPayloads)-1]
+	srcData := src[bytes.Index(src, last)+len(last):]
+
+	for mode, name := range MetadataModeNames {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			opts := SaveOptions{Passthrough: true, Quality: 90, Metadata: MetadataMode(mode)}
+			if err := encodeJPEG(buf, img, opts); err != nil {
+				t.Fatal(err)
+			}
+			bs := buf.Bytes()
+
+			if MetadataMode(mode) == MetadataKeep {
+				if !bytes.Equal(bs, src) {
+					t.Errorf("the source is not copied as is")
+				}
+				return
+			}
+
+			if !bytes.HasSuffix(bs, srcData) {
+				t.Errorf("the image data is not copied")
+			}
+
+			markers, payloads := jpegMarkers(bs)
+			for i, marker := range markers {
+				payload := payloads[i]
+				switch {
+				case marker == jpegMarkerAPP13:
+					t.Errorf("the IPTC segment is kept")
+				case marker == jpegMarkerCOM:
+					t.Errorf("the comment is kept")
+				case bytes.HasPrefix(payload, xmpExtensionHeader):
+					t.Errorf("the extended XMP segment is kept")
+				case bytes.HasPrefix(payload, xmpHeader):
+					t.Errorf("the XMP packet with the location is kept")
+				case bytes.HasPrefix(payload, exifHeader) && MetadataMode(mode) == MetadataStrip:
+					t.Errorf("the EXIF data is kept")
+				}
+			}
+
+			m := readMetadata(bs)
+			if MetadataMode(mode) == MetadataStripGPS {
+				if m == nil || fieldValue(m.Fields(), "Make") != "Canon" || m.exif.gps != nil {
+					t.Errorf("got metadata %v, want the EXIF data without GPS", m.Fields())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package imgutil
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// OpKind is the kind of an edit operation
+type OpKind string
+
+const (
+	// OpRotate rotates the image 90 degrees counter-clockwise
+	OpRotate OpKind = "rotate"
+
+	// OpCrop crops the image to Rect
+	OpCrop OpKind = "crop"
+
+	// OpSplit keeps the half Part of the image,
+	// 1 is the left half and 2 is the right half
+	OpSplit OpKind = "split"
+
+	// OpAdjust applies the tonal adjustment Adjust
+	OpAdjust OpKind = "adjust"
+)
+
+// Op is an edit operation of an image.
+// The operations of an image are applied in order on its original source,
+// so they can be reordered or removed at any time.
+// An operation is not modified once it is created.
+type Op struct {
+	Kind OpKind `json:"kind"`
+
+	// Rect is the rectangle kept by OpCrop,
+	// in the coordinates of the image the operation is applied to
+	Rect *image.Rectangle `json:"rect,omitempty"`
+
+	// Part is the half kept by OpSplit
+	Part int `json:"part,omitempty"`
+
+	// Adjust is the adjustment applied by OpAdjust
+	Adjust *Adjustment `json:"adjust,omitempty"`
+}
+
+// NewCropOp creates an operation cropping the image to rect
+func NewCropOp(rect image.Rectangle) Op {
+	return Op{Kind: OpCrop, Rect: &rect}
+}
+
+// NewAdjustOp creates an operation applying the adjustment
+func NewAdjustOp(adj Adjustment) Op {
+	return Op{Kind: OpAdjust, Adjust: &adj}
+}
+
+// Apply returns the image with the operation applied
+func (op Op) Apply(img image.Image) image.Image {
+	switch op.Kind {
+	case OpRotate:
+		return imaging.Rotate90(img)
+	case OpCrop, OpSplit:
+		rect := op.keptRect(img.Bounds())
+		return imaging.Crop(img, rect.Add(img.Bounds().Min))
+	case OpAdjust:
+		if op.Adjust == nil {
+			return img
+		}
+		return op.Adjust.Apply(img)
+	default:
+		return img
+	}
+}
+
+// Bounds returns the bounds of an image of bounds
+// after the operation is applied, without decoding the image
+func (op Op) Bounds(bounds image.Rectangle) image.Rectangle {
+	switch op.Kind {
+	case OpRotate:
+		return image.Rect(0, 0, bounds.Dy(), bounds.Dx())
+	case OpCrop, OpSplit:
+		rect := op.keptRect(bounds)
+		return image.Rect(0, 0, rect.Dx(), rect.Dy())
+	default:
+		return bounds
+	}
+}
+
+// keptRect returns the rectangle kept by a crop or a split
+// relative to the origin of bounds, a crop outside of the image keeps
+// the whole image
+func (op Op) keptRect(bounds image.Rectangle) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if op.Kind == OpSplit {
+		if op.Part == 2 {
+			return image.Rect(w/2, 0, w, h)
+		}
+		return image.Rect(0, 0, w/2, h)
+	}
+
+	if op.Rect == nil {
+		return image.Rect(0, 0, w, h)
+	}
+
+	rect := op.Rect.Intersect(image.Rect(0, 0, w, h))
+	if rect.Empty() {
+		return image.Rect(0, 0, w, h)
+	}
+
+	return rect
+}
+
+// String describes the operation for the user
+func (op Op) String() string {
+	switch op.Kind {
+	case OpRotate:
+		return "Rotate 90°"
+	case OpCrop:
+		if op.Rect == nil {
+			return "Crop"
+		}
+		return fmt.Sprintf("Crop %dx%d at (%d, %d)",
+			op.Rect.Dx(), op.Rect.Dy(), op.Rect.Min.X, op.Rect.Min.Y)
+	case OpSplit:
+		if op.Part == 2 {
+			return "Split (right half)"
+		}
+		return "Split (left half)"
+	case OpAdjust:
+		if op.Adjust == nil {
+			return "Adjust"
+		}
+		return "Adjust: " + op.Adjust.String()
+	default:
+		return string(op.Kind)
+	}
+}
+
+// ResetOps returns the operations kept when an image is reset to its
+// original, which are the splits since the other halves of the original
+// image are separate images.
+func ResetOps(ops []Op) []Op {
+	var kept []Op
+	for _, op := range ops {
+		if op.Kind == OpSplit {
+			kept = append(kept, op)
+		}
+	}
+
+	return kept
+}
+
+// applyOps applies the operations in order
+func applyOps(img image.Image, ops []Op) image.Image {
+	for _, op := range ops {
+		img = op.Apply(img)
+	}
+
+	return img
+}
+
+// opsBounds returns the bounds of an image of bounds
+// after the operations are applied
+func opsBounds(bounds image.Rectangle, ops []Op) image.Rectangle {
+	for _, op := range ops {
+		bounds = op.Bounds(bounds)
+	}
+
+	return bounds
+}
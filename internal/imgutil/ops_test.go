@@ -0,0 +1,209 @@
+package imgutil
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestOpsImg creates an image of 40x30 whose pixels tell their position
+func newTestOpsImg(t *testing.T) *Image {
+	return newTestImg(t, 40, 30, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255}
+	})
+}
+
+func TestOpsBoundsMatchRender(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Op
+		want image.Point
+	}{
+		{"none", nil, image.Pt(40, 30)},
+		{"rotate", []Op{{Kind: OpRotate}}, image.Pt(30, 40)},
+		{"rotate twice", []Op{{Kind: OpRotate}, {Kind: OpRotate}}, image.Pt(40, 30)},
+		{"left half", []Op{{Kind: OpSplit, Part: 1}}, image.Pt(20, 30)},
+		{"right half of odd width", []Op{{Kind: OpRotate}, {Kind: OpSplit, Part: 2}}, image.Pt(15, 40)},
+		{"split then rotate", []Op{{Kind: OpSplit, Part: 2}, {Kind: OpRotate}}, image.Pt(30, 20)},
+		{"crop", []Op{NewCropOp(image.Rect(5, 4, 25, 14))}, image.Pt(20, 10)},
+		{"crop past the edges", []Op{NewCropOp(image.Rect(30, 20, 100, 100))}, image.Pt(10, 10)},
+		{"crop outside", []Op{NewCropOp(image.Rect(50, 50, 60, 60))}, image.Pt(40, 30)},
+		{"crop of a split", []Op{{Kind: OpSplit, Part: 2}, NewCropOp(image.Rect(0, 0, 10, 30))}, image.Pt(10, 30)},
+		{"adjust", []Op{NewAdjustOp(Adjustment{Grayscale: true})}, image.Pt(40, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newTestOpsImg(t)
+			img.SetOps(tt.ops)
+
+			if got := img.Bounds().Size(); got != tt.want {
+				t.Errorf("got bounds %v, want %v", got, tt.want)
+			}
+
+			rendered, err := img.Img()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered.Bounds().Size() != img.Bounds().Size() {
+				t.Errorf("rendered size %v differs from bounds %v",
+					rendered.Bounds().Size(), img.Bounds().Size())
+			}
+		})
+	}
+}
+
+func TestOpApplyPixels(t *testing.T) {
+	img := newTestOpsImg(t)
+	original, err := img.Original()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(img image.Image, x, y int) color.NRGBA {
+		b := img.Bounds()
+		return color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+	}
+
+	// the right half starts at the middle of the original
+	right := Op{Kind: OpSplit, Part: 2}.Apply(original)
+	if got, want := at(right, 0, 0), at(original, 20, 0); got != want {
+		t.Errorf("right half starts with %v, want %v", got, want)
+	}
+
+	// the crop starts at the corner of its rectangle
+	cropped := NewCropOp(image.Rect(5, 4, 25, 14)).Apply(original)
+	if got, want := at(cropped, 0, 0), at(original, 5, 4); got != want {
+		t.Errorf("crop starts with %v, want %v", got, want)
+	}
+
+	// the rotation moves the top right corner to the top left
+	rotated := Op{Kind: OpRotate}.Apply(original)
+	if got, want := at(rotated, 0, 0), at(original, 39, 0); got != want {
+		t.Errorf("rotation starts with %v, want %v", got, want)
+	}
+}
+
+func TestResetOps(t *testing.T) {
+	ops := []Op{
+		{Kind: OpRotate},
+		{Kind: OpSplit, Part: 2},
+		NewAdjustOp(Adjustment{Grayscale: true}),
+		NewCropOp(image.Rect(0, 0, 5, 5)),
+		{Kind: OpSplit, Part: 1},
+	}
+
+	want := []Op{{Kind: OpSplit, Part: 2}, {Kind: OpSplit, Part: 1}}
+	if got := ResetOps(ops); !slices.Equal(got, want) {
+		t.Errorf("got ops %v, want %v", got, want)
+	}
+
+	img := newTestOpsImg(t)
+	img.SetOps(ops)
+	img.Reset()
+	if got := img.Ops(); !slices.Equal(got, want) {
+		t.Errorf("reset image has ops %v, want %v", got, want)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(10, 30) {
+		t.Errorf("reset image has bounds %v, want 10x30", got)
+	}
+}
+
+func TestRenderKey(t *testing.T) {
+	img := newTestOpsImg(t)
+	img.SetOps([]Op{{Kind: OpRotate}, NewAdjustOp(Adjustment{Brightness: 10})})
+
+	key := func(img *Image) renderKey {
+		k, err := img.renderKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	// the same operations of another image with the same source
+	same := img.Clone()
+	if key(same) != key(img) {
+		t.Errorf("the keys of the same operations differ")
+	}
+
+	// the operations in another order
+	reordered := img.Clone()
+	reordered.SetOps([]Op{NewAdjustOp(Adjustment{Brightness: 10}), {Kind: OpRotate}})
+	if key(reordered) == key(img) {
+		t.Errorf("the keys of reordered operations are the same")
+	}
+
+	// another value of an operation
+	changed := img.Clone()
+	changed.SetOps([]Op{{Kind: OpRotate}, NewAdjustOp(Adjustment{Brightness: 20})})
+	if key(changed) == key(img) {
+		t.Errorf("the keys of other adjustments are the same")
+	}
+
+	// the same operations of another source
+	other := newTestOpsImg(t)
+	other.SetOps(img.Ops())
+	if key(other) == key(img) {
+		t.Errorf("the keys of another source are the same")
+	}
+
+	// the rendered images are cached by key
+	first, err := img.Img()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := same.Img()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("the image is rendered again for the same key")
+	}
+}
+
+func TestOpJSON(t *testing.T) {
+	bs, err := json.Marshal([]Op{{Kind: OpRotate}, NewCropOp(image.Rect(1, 2, 3, 4))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields of the other kinds are left out
+	if s := string(bs); strings.Contains(s, "adjust") || strings.Count(s, "rect") != 1 {
+		t.Errorf("got JSON %s", s)
+	}
+
+	var ops []Op
+	if err := json.Unmarshal(bs, &ops); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[1].Rect == nil || *ops[1].Rect != image.Rect(1, 2, 3, 4) {
+		t.Errorf("got ops %v", ops)
+	}
+}
+
+func TestCloneIsolatesOps(t *testing.T) {
+	img := newTestOpsImg(t)
+	img.AddOp(Op{Kind: OpRotate})
+
+	clone := img.Clone()
+	clone.AddOp(Op{Kind: OpSplit, Part: 1})
+	clone.SetOps(append(clone.Ops()[:1:1], NewCropOp(image.Rect(0, 0, 5, 5))))
+
+	if got := img.Ops(); !slices.Equal(got, []Op{{Kind: OpRotate}}) {
+		t.Errorf("the ops of the original changed to %v", got)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(30, 40) {
+		t.Errorf("the bounds of the original changed to %v", got)
+	}
+
+	// the slice returned by Ops is a copy
+	ops := img.Ops()
+	ops[0] = Op{Kind: OpSplit, Part: 2}
+	if img.Ops()[0].Kind != OpRotate {
+		t.Errorf("the ops are changed through Ops")
+	}
+}
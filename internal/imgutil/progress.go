@@ -0,0 +1,36 @@
+package imgutil
+
+import "sync"
+
+// ProgressFunc is called with the number of images processed so far
+// and the total number of images
+type ProgressFunc func(done, total int)
+
+// report calls the progress function if it is set
+func (p ProgressFunc) report(done, total int) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+// progressCounter counts the images processed by concurrent workers
+// and reports the count in increasing order
+type progressCounter struct {
+	mu       sync.Mutex
+	done     int
+	total    int
+	progress ProgressFunc
+}
+
+func newProgressCounter(total int, progress ProgressFunc) *progressCounter {
+	return &progressCounter{total: total, progress: progress}
+}
+
+// inc counts one more processed image and reports it
+func (c *progressCounter) inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done++
+	c.progress.report(c.done, c.total)
+}
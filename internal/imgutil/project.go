@@ -0,0 +1,247 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+var SupportedProjectExts = []string{".imgpack"}
+
+const (
+	// projectVersion is the version of the project format
+	projectVersion = 1
+
+	projectManifestName = "manifest.json"
+	projectSourceDir    = "sources/"
+)
+
+// projectManifest describes the images of a project,
+// the sources of the images are stored next to it in the project file
+type projectManifest struct {
+	Version int            `json:"version"`
+	Images  []projectImage `json:"images"`
+}
+
+type projectImage struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+
+	// Width and Height are the size of the original image,
+	// the size is read from the source when the project is loaded
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	Color  string `json:"color,omitempty"`
+	Frames int    `json:"frames,omitempty"`
+	Delay  int    `json:"delay,omitempty"`
+
+	// Ops is the edit operations applied to the original image
+	Ops []Op `json:"ops,omitempty"`
+
+	// Provenance is where the image was read from before the project
+	Provenance Provenance `json:"provenance"`
+
+	// SplitBefore is the split marker of the volumes starting at the image
+	SplitBefore bool `json:"split_before,omitempty"`
+
+	// Source is the entry of the source of the image in the project file,
+	// images sharing a source share the entry
+	Source string `json:"source"`
+}
+
+// SaveProject saves the images with their order, names, sources and
+// edit operations as a project file, it stops when ctx is done and
+// reports the progress to progress.
+func SaveProject(ctx context.Context, imgs []*Image, f io.Writer, progress ProgressFunc) error {
+	zipWriter := zip.NewWriter(f)
+	defer zipWriter.Close()
+
+	manifest := projectManifest{
+		Version: projectVersion,
+		Images:  make([]projectImage, len(imgs)),
+	}
+
+	sourceNames := make(map[*source]string)
+	for i, img := range imgs {
+		if err := ctx.Err(); err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		name, ok := sourceNames[img.src]
+		if !ok {
+			name = fmt.Sprintf("%s%d", projectSourceDir, len(sourceNames))
+			sourceNames[img.src] = name
+
+			if err := writeProjectSource(zipWriter, name, img.src); err != nil {
+				return util.Errorf("%w", err)
+			}
+		}
+
+		manifest.Images[i] = projectImage{
+			Filename:    img.Filename,
+			Type:        img.Type,
+			Color:       img.Color,
+			Frames:      img.Frames,
+			Delay:       img.Delay,
+			Width:       img.srcBounds.Dx(),
+			Height:      img.srcBounds.Dy(),
+			Ops:         img.ops,
+			Provenance:  img.Provenance,
+			SplitBefore: img.SplitBefore,
+			Source:      name,
+		}
+
+		progress.report(i+1, len(imgs))
+	}
+
+	manifestFile, err := zipWriter.Create(projectManifestName)
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	encoder := json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// writeProjectSource writes the source as the entry name of the project,
+// the source is stored as is since it is already compressed
+func writeProjectSource(zipWriter *zip.Writer, name string, src *source) error {
+	bs, err := src.bytes()
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	if _, err := w.Write(bs); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// LoadProject reads the images of a project file,
+// it stops when ctx is done and reports the progress to opts.Progress.
+// The images are not decoded until they are used.
+func LoadProject(ctx context.Context, f io.Reader, opts ReadOptions) ([]*Image, error) {
+	ra, size, release, err := openReaderAt(f)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	defer release()
+
+	r, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	manifest, err := readProjectManifest(r)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	sources := make(map[string]*projectSource)
+	imgs := make([]*Image, len(manifest.Images))
+	for i, imgInfo := range manifest.Images {
+		if err := ctx.Err(); err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		src, ok := sources[imgInfo.Source]
+		if !ok {
+			src, err = readProjectSource(r, imgInfo.Source)
+			if err != nil {
+				return nil, util.Errorf("%w", err)
+			}
+			sources[imgInfo.Source] = src
+		}
+
+		imgs[i] = &Image{
+			Filename:    imgInfo.Filename,
+			Type:        imgInfo.Type,
+			Color:       imgInfo.Color,
+			Frames:      imgInfo.Frames,
+			Delay:       imgInfo.Delay,
+			Provenance:  imgInfo.Provenance,
+			SplitBefore: imgInfo.SplitBefore,
+			src:         src.src,
+			meta:        src.meta,
+			srcBounds:   src.bounds,
+		}
+		imgs[i].SetOps(imgInfo.Ops)
+
+		opts.Progress.report(i+1, len(manifest.Images))
+	}
+
+	return imgs, nil
+}
+
+func readProjectManifest(r *zip.Reader) (*projectManifest, error) {
+	manifestFile, err := r.Open(projectManifestName)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	defer manifestFile.Close()
+
+	var manifest projectManifest
+	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	if manifest.Version > projectVersion {
+		return nil, util.Errorf("unsupported project version %d", manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// projectSource is a source read from a project file
+type projectSource struct {
+	src  *source
+	meta *Metadata
+
+	// bounds is read from the source, the size in the manifest
+	// is not trusted
+	bounds image.Rectangle
+}
+
+// readProjectSource reads the source entry name of the project
+// with the metadata and the bounds of the image
+func readProjectSource(r *zip.Reader, name string) (*projectSource, error) {
+	sourceFile, err := r.Open(name)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	defer sourceFile.Close()
+
+	bs, err := io.ReadAll(sourceFile)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	bounds, err := decodeBounds(bs)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	src, err := newSource(bs)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return &projectSource{src: src, meta: readMetadata(bs), bounds: bounds}, nil
+}
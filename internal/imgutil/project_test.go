@@ -0,0 +1,208 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// newTestRotatedJPEG creates a JPEG image of 16x8 pixels
+// whose EXIF orientation turns it upright as 8x16 pixels
+func newTestRotatedJPEG(t *testing.T) *Image {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	bs := replaceJPEGMetadata(buf.Bytes(), [][]byte{
+		jpegSegment(jpegMarkerAPP1, append(bytes.Clone(exifHeader), newTestExif(binary.BigEndian)...)),
+	})
+
+	img, err := newImgFromBytes(bs, "rotated.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+// saveTestProject saves the images as a project and returns the file
+func saveTestProject(t *testing.T, imgs []*Image) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := SaveProject(context.Background(), imgs, buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// rewriteTestManifest rewrites the manifest of the project file bs with edit
+func rewriteTestManifest(t *testing.T, bs []byte, edit func(m *projectManifest)) []byte {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.Name == projectManifestName {
+			var m projectManifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			edit(&m)
+			if data, err = json.Marshal(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// testOpsJSON returns the JSON of ops to compare the values of the ops
+func testOpsJSON(t *testing.T, ops []Op) string {
+	t.Helper()
+
+	bs, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(bs)
+}
+
+func TestProjectRoundTrip(t *testing.T) {
+	imgs := newTestNamedImgs(t, 3, func(i int) string { return []string{"a", "b", "c"}[i] })
+	imgs = append(imgs, newTestRotatedJPEG(t))
+
+	imgs[0].AddOp(Op{Kind: OpRotate})
+	imgs[0].Provenance = Provenance{Path: "/books/one.cbz", Entry: "pages/a.png", Size: 100}
+	imgs[1].SetOps([]Op{{Kind: OpSplit, Part: 2}, NewAdjustOp(Adjustment{Grayscale: true})})
+	imgs[1].Provenance = Provenance{Path: "/books/two.pdf", Page: 3, Object: 12}
+	imgs[1].SplitBefore = true
+	imgs[3].AddOp(NewCropOp(image.Rect(1, 2, 7, 12)))
+
+	// the other half of the first image shares its source
+	half := imgs[1].Clone()
+	half.SetOps([]Op{{Kind: OpSplit, Part: 1}})
+	imgs = slices.Insert(imgs, 2, half)
+
+	// the order is not the order of the sources
+	imgs[0], imgs[4] = imgs[4], imgs[0]
+
+	progress := 0
+	loaded, err := LoadProject(context.Background(), bytes.NewReader(saveTestProject(t, imgs)),
+		ReadOptions{Progress: func(done, total int) { progress = done }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != len(imgs) || progress != len(imgs) {
+		t.Fatalf("got %d images and progress %d, want %d", len(loaded), progress, len(imgs))
+	}
+
+	for i, img := range imgs {
+		got := loaded[i]
+		if got.Filename != img.Filename || got.Type != img.Type {
+			t.Errorf("image %d is %q of %s, want %q of %s", i, got.Filename, got.Type, img.Filename, img.Type)
+		}
+		if testOpsJSON(t, got.Ops()) != testOpsJSON(t, img.Ops()) {
+			t.Errorf("image %d has ops %v, want %v", i, got.Ops(), img.Ops())
+		}
+		if got.Provenance != img.Provenance {
+			t.Errorf("image %d has provenance %+v, want %+v", i, got.Provenance, img.Provenance)
+		}
+		if got.SplitBefore != img.SplitBefore {
+			t.Errorf("image %d has split marker %v", i, got.SplitBefore)
+		}
+		if got.srcBounds != img.srcBounds || got.Bounds() != img.Bounds() {
+			t.Errorf("image %d has bounds %v of %v, want %v of %v",
+				i, got.Bounds(), got.srcBounds, img.Bounds(), img.srcBounds)
+		}
+
+		want, err := img.Img()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered, err := got.Img()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(imaging.Clone(rendered).Pix, imaging.Clone(want).Pix) {
+			t.Errorf("image %d is rendered differently", i)
+		}
+	}
+
+	// the halves share the source again
+	if loaded[1].src != loaded[2].src || loaded[1].src == loaded[3].src {
+		t.Errorf("the sources are not shared as saved")
+	}
+}
+
+func TestLoadProjectBounds(t *testing.T) {
+	imgs := []*Image{newTestRotatedJPEG(t)}
+	imgs[0].AddOp(Op{Kind: OpSplit, Part: 1})
+
+	// the size in the manifest is not trusted
+	bs := rewriteTestManifest(t, saveTestProject(t, imgs), func(m *projectManifest) {
+		m.Images[0].Width, m.Images[0].Height = 1<<30, 3
+	})
+
+	loaded, err := LoadProject(context.Background(), bytes.NewReader(bs), ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loaded[0].Bounds(); got != image.Rect(0, 0, 4, 16) {
+		t.Errorf("got bounds %v, want the left half of 8x16", got)
+	}
+}
+
+func TestLoadProjectVersion(t *testing.T) {
+	imgs := newTestNamedImgs(t, 1, func(i int) string { return "a" })
+	bs := rewriteTestManifest(t, saveTestProject(t, imgs), func(m *projectManifest) {
+		m.Version = projectVersion + 1
+	})
+
+	if _, err := LoadProject(context.Background(), bytes.NewReader(bs), ReadOptions{}); err == nil {
+		t.Errorf("the project of a newer version is loaded")
+	}
+}
@@ -0,0 +1,215 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var errNoProvenance = errors.New("the source of the image is unknown")
+
+// Provenance describes where an image was read from
+type Provenance struct {
+	// Path is the path of the file the image was read from, which is
+	// the archive or the PDF containing the image, empty if unknown
+	Path string `json:"path,omitempty"`
+
+	// Entry is the path of the image inside the archive, empty otherwise
+	Entry string `json:"entry,omitempty"`
+
+	// Page is the 1-based page of the PDF or of the multi-page TIFF
+	// the image was read from, zero otherwise
+	Page int `json:"page,omitempty"`
+
+	// Object is the object number of the image in the PDF, zero otherwise
+	Object int `json:"object,omitempty"`
+
+	// Frame is the 1-based frame of the animated GIF
+	// the image was expanded from, zero otherwise
+	Frame int `json:"frame,omitempty"`
+
+	// Size is the byte size of the encoded file of the image,
+	// which is the whole file of a page or a frame
+	Size int64 `json:"size,omitempty"`
+}
+
+// IsKnown reports whether the file the image was read from is known
+func (p Provenance) IsKnown() bool {
+	return p.Path != ""
+}
+
+// String describes the provenance, e.g. "book.cbz > a/b/c.jpg, 1.2 MB"
+func (p Provenance) String() string {
+	if !p.IsKnown() {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(filepath.Base(p.Path))
+	if p.Entry != "" {
+		sb.WriteString(" > " + p.Entry)
+	}
+	if p.Page > 0 {
+		fmt.Fprintf(&sb, ", page %d", p.Page)
+	}
+	if p.Object > 0 {
+		fmt.Fprintf(&sb, ", object %d", p.Object)
+	}
+	if p.Frame > 0 {
+		fmt.Fprintf(&sb, ", frame %d", p.Frame)
+	}
+	if p.Size > 0 {
+		sb.WriteString(", " + util.FormatSize(p.Size))
+	}
+
+	return sb.String()
+}
+
+// isPDF reports whether the image was read from a PDF
+func (p Provenance) isPDF() bool {
+	return p.Object > 0
+}
+
+// readFile returns the encoded file of the image, read from the file
+// system, the archive entry or the PDF object of the provenance
+func (p Provenance) readFile() ([]byte, error) {
+	if !p.IsKnown() {
+		return nil, util.Errorf("%w", errNoProvenance)
+	}
+
+	if p.Entry != "" {
+		r, err := zip.OpenReader(p.Path)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		defer r.Close()
+
+		f, err := r.Open(p.Entry)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		defer f.Close()
+
+		bs, err := io.ReadAll(f)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		return bs, nil
+	}
+
+	if p.isPDF() {
+		bs, err := p.readPDFObject()
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		return bs, nil
+	}
+
+	bs, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	return bs, nil
+}
+
+// readPDFObject returns the image of the PDF object of the provenance
+func (p Provenance) readPDFObject() ([]byte, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+
+	imgsInPDF, err := api.ExtractImagesRaw(f, []string{strconv.Itoa(p.Page)}, conf)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	for _, imgMap := range imgsInPDF {
+		for _, img := range imgMap {
+			if img.ObjNr != p.Object {
+				continue
+			}
+
+			bs, err := io.ReadAll(img)
+			if err != nil {
+				return nil, util.Errorf("%w", err)
+			}
+			return bs, nil
+		}
+	}
+
+	return nil, util.Errorf("object %d not found on page %d of %s",
+		p.Object, p.Page, filepath.Base(p.Path))
+}
+
+// Reimport reads the image again from its source, without its edit
+// operations. The name, the provenance and the split marker of the image
+// are kept.
+func Reimport(ctx context.Context, img *Image, opts ReadOptions) (*Image, error) {
+	prov := img.Provenance
+
+	bs, err := prov.readFile()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	var imgs []*Image
+	if prov.isPDF() {
+		pdfImg, err := newImgFromBytes(bs, img.Filename)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		imgs = []*Image{pdfImg}
+	} else {
+		filename := filepath.Base(prov.Path)
+		if prov.Entry != "" {
+			filename = path.Base(prov.Entry)
+		}
+
+		opts.ExpandGIFFrames = prov.Frame > 0
+		imgs, err = newImgs(bytes.NewReader(bs), filename, opts)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+	}
+
+	idx := 0
+	switch {
+	case prov.Frame > 0:
+		idx = prov.Frame - 1
+	case prov.Page > 0 && !prov.isPDF():
+		idx = prov.Page - 1
+	}
+
+	if idx >= len(imgs) {
+		return nil, util.Errorf("page %d not found in %s", idx+1, prov)
+	}
+
+	newImg := imgs[idx]
+	newImg.Filename = img.Filename
+	newImg.Provenance = prov
+	newImg.SplitBefore = img.SplitBefore
+	opts.Progress.report(1, 1)
+
+	return newImg, nil
+}
@@ -0,0 +1,173 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// writeTestBook writes a zip file in dir with a PNG image
+// and a multi-page TIFF image in a folder, and returns its path
+func writeTestBook(t *testing.T, dir string) string {
+	t.Helper()
+
+	cover := newTestImg(t, 6, 9, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x * 40), 0, uint8(y * 25), 255}
+	})
+	coverBytes, err := cover.src.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"cover.png", coverBytes},
+		{"pages/scan.tif", encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionDeflate)},
+	} {
+		fw, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(dir, "book.cbz")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// checkReimported checks that the image reimported from img
+// is the original image with the name, provenance and split marker of img
+func checkReimported(t *testing.T, img, got *Image, want *image.NRGBA) {
+	t.Helper()
+
+	if got.Filename != img.Filename || got.Provenance != img.Provenance ||
+		got.SplitBefore != img.SplitBefore {
+		t.Errorf("got %q from %+v split %v, want %q from %+v split %v",
+			got.Filename, got.Provenance, got.SplitBefore,
+			img.Filename, img.Provenance, img.SplitBefore)
+	}
+	if got.IsEdited() {
+		t.Errorf("the reimported image has ops %v", got.Ops())
+	}
+
+	rendered, err := got.Img()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Bounds() != want.Bounds() || !bytes.Equal(imaging.Clone(rendered).Pix, want.Pix) {
+		t.Errorf("the reimported image of %s differs from the original", img.Provenance)
+	}
+}
+
+func TestReimport(t *testing.T) {
+	p := writeTestBook(t, t.TempDir())
+
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	imgs, err := ReadImgsInFileContext(context.Background(), f, p, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 4 {
+		t.Fatalf("got %d images, want the cover and 3 pages", len(imgs))
+	}
+
+	for i, img := range imgs {
+		original, err := img.Original()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := imaging.Clone(original)
+
+		// the edits are dropped, the rest is kept
+		img.Filename = "renamed"
+		img.SplitBefore = i%2 == 1
+		img.SetOps([]Op{{Kind: OpRotate}, {Kind: OpSplit, Part: 2}})
+
+		got, err := Reimport(context.Background(), img, ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReimported(t, img, got, want)
+	}
+
+	// the pages of the TIFF are selected by their page
+	if got := imgs[2].Provenance; got.Entry != "pages/scan.tif" || got.Page != 2 {
+		t.Errorf("got provenance %+v", got)
+	}
+}
+
+func TestReimportTIFFPage(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "scan.tiff")
+	if err := os.WriteFile(p, encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionNone), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	imgs, err := ReadImgsInFileContext(context.Background(), f, p, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := imgs[2]
+	original, err := img.Original()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := imaging.Clone(original)
+
+	img.SplitBefore = true
+	img.AddOp(NewCropOp(image.Rect(1, 1, 3, 3)))
+
+	got, err := Reimport(context.Background(), img, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReimported(t, img, got, want)
+	if got.Bounds().Size() != image.Pt(7, 5) {
+		t.Errorf("got bounds %v, want the third page", got.Bounds())
+	}
+
+	// a page past the end of the file is not found
+	img.Provenance.Page = 4
+	if _, err := Reimport(context.Background(), img, ReadOptions{}); err == nil {
+		t.Errorf("the missing page is reimported")
+	}
+}
+
+func TestReimportUnknown(t *testing.T) {
+	img := newTestImg(t, 4, 4, func(x, y int) color.NRGBA { return color.NRGBA{A: 255} })
+	if _, err := Reimport(context.Background(), img, ReadOptions{}); !errors.Is(err, errNoProvenance) {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+// quantizeMaxSamples bounds the number of pixels used to build a palette
+const quantizeMaxSamples = 1 << 16
+
+// colorBox is a box of the RGB color space of the median cut algorithm
+type colorBox struct {
+	pixels [][3]uint8
+}
+
+// widest returns the channel with the widest range in the box and the range
+func (b colorBox) widest() (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	hi := [3]uint8{}
+	for _, p := range b.pixels {
+		for c := range 3 {
+			lo[c] = min(lo[c], p[c])
+			hi[c] = max(hi[c], p[c])
+		}
+	}
+
+	channel, width := 0, 0
+	for c := range 3 {
+		if int(hi[c])-int(lo[c]) > width {
+			channel, width = c, int(hi[c])-int(lo[c])
+		}
+	}
+
+	return channel, width
+}
+
+// average returns the average color of the box
+func (b colorBox) average() color.Color {
+	var sum [3]int
+	for _, p := range b.pixels {
+		for c := range 3 {
+			sum[c] += int(p[c])
+		}
+	}
+
+	n := max(len(b.pixels), 1)
+	return color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 255}
+}
+
+// medianCutPalette builds a palette of at most n colors for the opaque
+// image img with the median cut algorithm: the box of colors with the
+// widest range is split at its median until there are n boxes.
+func medianCutPalette(img *image.NRGBA, n int) color.Palette {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	step := max(total/quantizeMaxSamples, 1)
+
+	pixels := make([][3]uint8, 0, total/step+1)
+	for i := 0; i < total; i += step {
+		x, y := i%bounds.Dx(), i/bounds.Dx()
+		off := img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+		pixels = append(pixels, [3]uint8{img.Pix[off], img.Pix[off+1], img.Pix[off+2]})
+	}
+
+	boxes := []colorBox{{pixels: pixels}}
+	for len(boxes) < n {
+		idx, channel, width := -1, 0, 0
+		for i, box := range boxes {
+			if len(box.pixels) < 2 {
+				continue
+			}
+
+			c, w := box.widest()
+			if w > width {
+				idx, channel, width = i, c, w
+			}
+		}
+
+		// every box has a single color
+		if idx < 0 {
+			break
+		}
+
+		box := boxes[idx]
+		slices.SortFunc(box.pixels, func(p, q [3]uint8) int {
+			return int(p[channel]) - int(q[channel])
+		})
+
+		mid := len(box.pixels) / 2
+		boxes[idx] = colorBox{pixels: box.pixels[:mid]}
+		boxes = append(boxes, colorBox{pixels: box.pixels[mid:]})
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+
+	return palette
+}
+
+// flattenOnWhite draws the image on a white background,
+// the bounds of the returned image start at the origin
+func flattenOnWhite(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+
+	flat := image.NewNRGBA(rect)
+	draw.Draw(flat, rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, rect, img, bounds.Min, draw.Over)
+
+	return flat
+}
+
+// quantize converts the image to a paletted image of at most n colors
+// whose bounds start at the origin. The transparent pixels are flattened
+// on white since the frames of an animation are drawn over each other.
+func quantize(img image.Image, n int, dither bool) *image.Paletted {
+	flat := flattenOnWhite(img)
+	rect := flat.Rect
+
+	paletted := image.NewPaletted(rect, medianCutPalette(flat, n))
+	if dither {
+		draw.FloydSteinberg.Draw(paletted, rect, flat, image.Point{})
+	} else {
+		draw.Draw(paletted, rect, flat, image.Point{}, draw.Src)
+	}
+
+	return paletted
+}
@@ -2,8 +2,9 @@ package imgutil
 
 import (
 	"archive/zip"
-	"bytes"
+	"context"
 	"fmt"
+	"image"
 	"io"
 	"maps"
 	"os"
@@ -16,11 +17,178 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
+// ReadOptions stores the options used to read images
+type ReadOptions struct {
+	// Workers is the number of images decoded concurrently,
+	// zero means one per CPU
+	Workers int
+
+	// ExpandGIFFrames reads every frame of an animated GIF as an image,
+	// otherwise only the first frame is read
+	ExpandGIFFrames bool
+
+	// SVG is how SVG images are rasterized
+	SVG SVGOptions
+
+	// Progress is called after each image is read, it may be nil
+	Progress ProgressFunc
+}
+
+// newImgs creates the images of the encoded image in r.
+// An animated GIF is expanded into its frames named <file>_f001...
+// if opts.ExpandGIFFrames is set, otherwise its frame count is kept.
+// A multi-page TIFF is expanded into its pages named <file>_p001...
+// An SVG image is rasterized with opts.SVG.
+func newImgs(r io.Reader, filename string, opts ReadOptions) ([]*Image, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	if isSVG(filename, bs) {
+		decoded, err := rasterizeSVG(bs, opts.SVG)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		img, err := newImgFromImage(decoded,
+			strings.TrimSuffix(filename, filepath.Ext(filename)))
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		img.Provenance.Size = int64(len(bs))
+		return []*Image{img}, nil
+	}
+
+	if opts.ExpandGIFFrames && isGIF(bs) {
+		frames, err := decodeGIFFrames(bs)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		if len(frames) > 1 {
+			imgs, err := newFrameImgs(frames, filename)
+			if err != nil {
+				return nil, util.Errorf("%w", err)
+			}
+
+			for i, img := range imgs {
+				img.Provenance.Frame = i + 1
+				img.Provenance.Size = int64(len(bs))
+			}
+			return imgs, nil
+		}
+	}
+
+	if isTIFF(bs) && len(tiffPageOffsets(bs)) > 1 {
+		pages, conversions, err := decodeTIFFPages(bs)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		imgs, err := newPageImgs(pages, filename)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		for i, img := range imgs {
+			img.Color = conversions[i]
+			img.Provenance.Page = i + 1
+			img.Provenance.Size = int64(len(bs))
+		}
+		return imgs, nil
+	}
+
+	img, err := newImgFromBytes(bs, filename)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	if img.Type == formatGIF {
+		frameCount, err := gifFrameCount(bs)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		if frameCount > 1 {
+			img.Frames = frameCount
+		}
+	}
+
+	return []*Image{img}, nil
+}
+
+// newPageImgs creates the images of the pages of a multi-page image
+func newPageImgs(pages []image.Image, filename string) ([]*Image, error) {
+	filename = strings.TrimSuffix(filename, filepath.Ext(filename))
+	digits := max(util.CountDigits(len(pages)), 3)
+
+	imgs := make([]*Image, len(pages))
+	for i, page := range pages {
+		pageName := fmt.Sprintf("%s_p%s", filename, util.PaddingZero(i+1, digits))
+
+		img, err := newImgFromImage(page, pageName)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+
+		imgs[i] = img
+	}
+
+	return imgs, nil
+}
+
+// newFrameImgs creates the images of the frames of an animated image
+func newFrameImgs(frames []gifFrame, filename string) ([]*Image, error) {
+	filename = strings.TrimSuffix(filename, filepath.Ext(filename))
+	digits := max(util.CountDigits(len(frames)), 3)
+
+	imgs := make([]*Image, len(frames))
+	for i, frame := range frames {
+		frameName := fmt.Sprintf("%s_f%s", filename, util.PaddingZero(i+1, digits))
+
+		img, err := newImgFromImage(frame.img, frameName)
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		img.Delay = frame.delay
+
+		imgs[i] = img
+	}
+
+	return imgs, nil
+}
+
 // ReadImgsInFile reads images from a file
 func ReadImgsInFile(f io.Reader, filename string) ([]*Image, error) {
+	return ReadImgsInFileContext(context.Background(), f, filename, ReadOptions{})
+}
+
+// ReadImgsInFileContext reads images from a file,
+// it stops when ctx is done and reports the progress to opts.Progress.
+// The filename is the path of the file, recorded as the provenance of the images.
+func ReadImgsInFileContext(ctx context.Context, f io.Reader, filename string,
+	opts ReadOptions) ([]*Image, error) {
+
+	imgs, err := readImgsInFile(ctx, f, filename, opts)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	for _, img := range imgs {
+		img.Provenance.Path = filename
+	}
+
+	return imgs, nil
+}
+
+// readImgsInFile reads images from a file by its extension
+func readImgsInFile(ctx context.Context, f io.Reader, filename string,
+	opts ReadOptions) ([]*Image, error) {
+
 	fileExt := filepath.Ext(filename)
 	if slices.Contains(SupportedArchiveExts, fileExt) {
-		imgs, err := ReadImgsInZip(f)
+		imgs, err := ReadImgsInZipContext(ctx, f, opts)
 		if err != nil {
 			return nil, util.Errorf("%w", err)
 		}
@@ -28,99 +196,146 @@ func ReadImgsInFile(f io.Reader, filename string) ([]*Image, error) {
 	}
 
 	if slices.Contains(SupportedPDFExts, fileExt) {
-		imgs, err := ReadImgsInPDF(f)
+		imgs, err := ReadImgsInPDFContext(ctx, f, opts)
 		if err != nil {
 			return nil, util.Errorf("%w", err)
 		}
 		return imgs, nil
 	}
 
-	img, err := NewImgByFilepath(filename)
+	if err := ctx.Err(); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	imgs, err := newImgs(f, filepath.Base(filename), opts)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
+	opts.Progress.report(1, 1)
 
-	return []*Image{img}, nil
+	return imgs, nil
 }
 
 // ReadImgsInDir reads images in a directory not recursively
 func ReadImgsInDir(dirpath string) ([]*Image, error) {
+	return ReadImgsInDirContext(context.Background(), dirpath, ReadOptions{})
+}
+
+// ReadImgsInDirContext reads images in a directory not recursively,
+// it stops when ctx is done and reports the progress to opts.Progress
+func ReadImgsInDirContext(ctx context.Context, dirpath string, opts ReadOptions) ([]*Image, error) {
 	dir, err := os.ReadDir(dirpath)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
 
-	imgs := make([]*Image, 0, len(dir))
-	for _, entry := range dir {
-		if entry.IsDir() {
-			continue
+	dir = slices.DeleteFunc(dir, func(entry os.DirEntry) bool {
+		return entry.IsDir()
+	})
+
+	imgs := make([][]*Image, len(dir))
+	counter := newProgressCounter(len(dir), opts.Progress)
+	err = parallelDo(ctx, len(dir), opts.Workers, func(i int) error {
+		filename := filepath.Join(dirpath, dir[i].Name())
+		f, err := os.Open(filename)
+		if err != nil {
+			return util.Errorf("%w", err)
 		}
+		defer f.Close()
 
-		img, err := NewImgByFilepath(filepath.Join(dirpath, entry.Name()))
+		imgs[i], err = newImgs(f, dir[i].Name(), opts)
 		if err != nil {
-			return nil, util.Errorf("%w", err)
+			return util.Errorf("%w", err)
+		}
+
+		for _, img := range imgs[i] {
+			img.Provenance.Path = filename
 		}
 
-		imgs = append(imgs, img)
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return nil, util.Errorf("%w", err)
 	}
 
-	return imgs, nil
+	return slices.Concat(imgs...), nil
 }
 
 // ReadImgsInZip reads images in a zip file
 func ReadImgsInZip(f io.Reader) ([]*Image, error) {
-	bs, err := io.ReadAll(f)
+	return ReadImgsInZipContext(context.Background(), f, ReadOptions{})
+}
+
+// ReadImgsInZipContext reads images in a zip file,
+// it stops when ctx is done and reports the progress to opts.Progress
+func ReadImgsInZipContext(ctx context.Context, f io.Reader, opts ReadOptions) ([]*Image, error) {
+	ra, size, release, err := openReaderAt(f)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
+	defer release()
 
-	r, err := zip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	r, err := zip.NewReader(ra, size)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
 
-	imgs := make([]*Image, 0, len(r.File))
-	for _, f := range r.File {
-		if f.FileInfo().IsDir() {
-			continue
-		}
-
-		if !slices.Contains(SupportedImageExts, filepath.Ext(f.Name)) {
-			continue
-		}
+	files := slices.DeleteFunc(slices.Clone(r.File), func(f *zip.File) bool {
+		ext := filepath.Ext(f.Name)
+		return f.FileInfo().IsDir() ||
+			!slices.Contains(SupportedImageExts, ext) && !slices.Contains(SupportedVectorExts, ext)
+	})
 
-		rc, err := f.Open()
+	imgs := make([][]*Image, len(files))
+	counter := newProgressCounter(len(files), opts.Progress)
+	err = parallelDo(ctx, len(files), opts.Workers, func(i int) error {
+		rc, err := files[i].Open()
 		if err != nil {
-			return nil, util.Errorf("%w", err)
+			return util.Errorf("%w", err)
 		}
 		defer rc.Close()
 
 		// prevent directory in filename
-		filename := strings.ReplaceAll(f.Name, "/", "_")
+		filename := strings.ReplaceAll(files[i].Name, "/", "_")
 
-		img, err := NewImg(rc, filename)
+		imgs[i], err = newImgs(rc, filename, opts)
 		if err != nil {
-			return nil, util.Errorf("%w", err)
+			return util.Errorf("%w", err)
+		}
+
+		for _, img := range imgs[i] {
+			img.Provenance.Entry = files[i].Name
 		}
 
-		imgs = append(imgs, img)
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return nil, util.Errorf("%w", err)
 	}
 
-	return imgs, nil
+	return slices.Concat(imgs...), nil
 }
 
 // ReadImgsInPDF reads images in a PDF file
 func ReadImgsInPDF(f io.Reader) ([]*Image, error) {
+	return ReadImgsInPDFContext(context.Background(), f, ReadOptions{})
+}
+
+// ReadImgsInPDFContext reads images in a PDF file,
+// it stops when ctx is done and reports the progress to opts.Progress
+func ReadImgsInPDFContext(ctx context.Context, f io.Reader, opts ReadOptions) ([]*Image, error) {
 	conf := model.NewDefaultConfiguration()
 	conf.ValidationMode = model.ValidationRelaxed
 
-	pdfBuf := new(bytes.Buffer)
-	_, err := io.Copy(pdfBuf, f)
+	ra, size, release, err := openReaderAt(f)
 	if err != nil {
 		return nil, util.Errorf("%w", err)
 	}
+	defer release()
 
-	pdfReader := bytes.NewReader(pdfBuf.Bytes())
+	pdfReader := io.NewSectionReader(ra, 0, size)
 
 	allPages, err := api.PageCount(pdfReader, conf)
 	if err != nil {
@@ -145,26 +360,34 @@ func ReadImgsInPDF(f io.Reader) ([]*Image, error) {
 	}
 	jdxMaxDigits := util.CountDigits(jdxMax)
 
-	imgsMap := make(map[string]*Image)
+	pdfImgsMap := make(map[string]model.Image)
 	for idx, imgMap := range imgsInPDF {
-		for jdx, imgReader := range imgMap {
+		for jdx, pdfImg := range imgMap {
 			filename := fmt.Sprintf("%s_%d", util.PaddingZero(jdx, jdxMaxDigits), idx)
-
-			img, err := NewImg(imgReader, filename)
-			if err != nil {
-				return nil, util.Errorf("%w", err)
-			}
-
-			imgsMap[filename] = img
+			pdfImgsMap[filename] = pdfImg
 		}
 	}
 
-	imgsKeys := slices.Collect(maps.Keys(imgsMap))
+	imgsKeys := slices.Collect(maps.Keys(pdfImgsMap))
 	slices.Sort(imgsKeys)
 
 	imgs := make([]*Image, len(imgsKeys))
-	for i, key := range imgsKeys {
-		imgs[i] = imgsMap[key]
+	counter := newProgressCounter(len(imgsKeys), opts.Progress)
+	err = parallelDo(ctx, len(imgsKeys), opts.Workers, func(i int) error {
+		pdfImg := pdfImgsMap[imgsKeys[i]]
+		img, err := NewImg(pdfImg, imgsKeys[i])
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+		img.Provenance.Page = pdfImg.PageNr
+		img.Provenance.Object = pdfImg.ObjNr
+
+		imgs[i] = img
+		counter.inc()
+		return nil
+	})
+	if err != nil {
+		return nil, util.Errorf("%w", err)
 	}
 
 	return imgs, nil
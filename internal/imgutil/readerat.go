@@ -0,0 +1,52 @@
+package imgutil
+
+import (
+	"io"
+	"os"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// openReaderAt returns r as an io.ReaderAt with its size.
+// Files and other io.ReaderAt whose size is known are used directly,
+// other readers are spooled to a temp file instead of memory.
+// The returned function releases the temp file, it must be called
+// once the io.ReaderAt is not used anymore.
+func openReaderAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	noop := func() {}
+
+	if ra, ok := r.(io.ReaderAt); ok {
+		switch sr := r.(type) {
+		case interface{ Stat() (os.FileInfo, error) }:
+			info, err := sr.Stat()
+			if err == nil && info.Mode().IsRegular() {
+				return ra, info.Size(), noop, nil
+			}
+		case interface{ Size() int64 }:
+			return ra, sr.Size(), noop, nil
+		case io.Seeker:
+			size, err := sr.Seek(0, io.SeekEnd)
+			if err == nil {
+				return ra, size, noop, nil
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "goimgpack-*")
+	if err != nil {
+		return nil, 0, nil, util.Errorf("%w", err)
+	}
+
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, util.Errorf("%w", err)
+	}
+
+	return tmp, size, cleanup, nil
+}
@@ -0,0 +1,197 @@
+package imgutil
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// nameFields are the fields of a NameTemplate
+var nameFields = []string{"book", "n", "orig", "page", "w", "h"}
+
+// nameNumberFields are the fields which can be padded with zeros
+var nameNumberFields = []string{"n", "page", "w", "h"}
+
+// nameReplacer replaces the path separators and the characters
+// which are invalid in the file names on Windows
+var nameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+	"\"", "_", "<", "_", ">", "_", "|", "_")
+
+// NameTemplate is a parsed template of image names, e.g. "{book}_{n:03}".
+// The fields are:
+//   - {book}: the name of the book
+//   - {n}: the number of the image in the renamed images
+//   - {orig}: the name of the file the image was read from
+//   - {page}: the page or the frame the image was read from, {n} if none
+//   - {w} and {h}: the size of the image
+//
+// The numbers are padded with zeros to the width after the colon, e.g. {n:03}.
+type NameTemplate struct {
+	parts []namePart
+}
+
+// namePart is a literal text or a field of a NameTemplate
+type namePart struct {
+	literal string
+	field   string
+
+	// width is the zero padding width of a number field
+	width int
+}
+
+// NameContext stores the values of the fields of a NameTemplate
+// which do not come from the image
+type NameContext struct {
+	// Book is the value of {book}
+	Book string
+
+	// N is the value of {n}
+	N int
+}
+
+// ParseNameTemplate parses a template of image names,
+// the error describes the mistake so it can be shown as is
+func ParseNameTemplate(s string) (*NameTemplate, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty name template")
+	}
+
+	t := &NameTemplate{}
+	for s != "" {
+		start := strings.IndexAny(s, "{}")
+		if start < 0 {
+			t.parts = append(t.parts, namePart{literal: s})
+			break
+		}
+
+		if s[start] == '}' {
+			return nil, errors.New("unexpected } in name template")
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, errors.New("unclosed { in name template")
+		}
+		end += start
+
+		if start > 0 {
+			t.parts = append(t.parts, namePart{literal: s[:start]})
+		}
+
+		part, err := parseNameField(s[start+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, part)
+
+		s = s[end+1:]
+	}
+
+	return t, nil
+}
+
+// parseNameField parses a field of a name template without its braces
+func parseNameField(s string) (namePart, error) {
+	field, format, hasFormat := strings.Cut(s, ":")
+	if !slices.Contains(nameFields, field) {
+		return namePart{}, fmt.Errorf("unknown field {%s} in name template", s)
+	}
+
+	part := namePart{field: field}
+	if !hasFormat {
+		return part, nil
+	}
+
+	width, err := strconv.Atoi(format)
+	if !slices.Contains(nameNumberFields, field) || err != nil || width < 0 || width > 9 {
+		return namePart{}, fmt.Errorf("invalid format of field {%s} in name template", s)
+	}
+	part.width = width
+
+	return part, nil
+}
+
+// Name returns the name of the image by the template, the path separators
+// and the characters invalid on Windows are replaced so the name stays
+// a single valid file, e.g. when {book} is typed by the user
+func (t *NameTemplate) Name(img *Image, ctx NameContext) string {
+	var sb strings.Builder
+	for _, part := range t.parts {
+		switch part.field {
+		case "":
+			sb.WriteString(part.literal)
+		case "book":
+			sb.WriteString(ctx.Book)
+		case "orig":
+			sb.WriteString(img.originalName())
+		case "n":
+			sb.WriteString(util.PaddingZero(ctx.N, part.width))
+		case "page":
+			page := cmp.Or(img.Provenance.Page, img.Provenance.Frame, ctx.N)
+			sb.WriteString(util.PaddingZero(page, part.width))
+		case "w":
+			sb.WriteString(util.PaddingZero(img.Bounds().Dx(), part.width))
+		case "h":
+			sb.WriteString(util.PaddingZero(img.Bounds().Dy(), part.width))
+		}
+	}
+
+	return nameReplacer.Replace(sb.String())
+}
+
+// Names returns the names of the images by the template,
+// the images are numbered from start
+func (t *NameTemplate) Names(imgs []*Image, book string, start int) []string {
+	names := make([]string, len(imgs))
+	for i, img := range imgs {
+		names[i] = t.Name(img, NameContext{Book: book, N: start + i})
+	}
+
+	return names
+}
+
+// originalName returns the name without the extension of the file
+// the image was read from, the archive entry or the file itself,
+// or the name of the image if its source is unknown
+func (img *Image) originalName() string {
+	prov := img.Provenance
+	switch {
+	case prov.Entry != "":
+		name := path.Base(prov.Entry)
+		return strings.TrimSuffix(name, path.Ext(name))
+	case prov.IsKnown():
+		name := filepath.Base(prov.Path)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	default:
+		return img.Filename
+	}
+}
+
+// BookName returns the name of the book the images were read from,
+// which is the name of the file of the first image with a known source
+func BookName(imgs []*Image) string {
+	for _, img := range imgs {
+		prov := img.Provenance
+		if !prov.IsKnown() {
+			continue
+		}
+
+		// the images of a folder are named by the folder
+		name := filepath.Base(prov.Path)
+		if prov.Entry == "" && prov.Object == 0 && prov.Page == 0 && prov.Frame == 0 {
+			name = filepath.Base(filepath.Dir(prov.Path))
+		}
+
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	return ""
+}
@@ -0,0 +1,105 @@
+package imgutil
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestParseNameTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"empty", "  ", "empty name template"},
+		{"unknown field", "{book}_{chapter}", "unknown field {chapter} in name template"},
+		{"unknown formatted field", "{num:03}", "unknown field {num:03} in name template"},
+		{"unclosed brace", "{book}_{n", "unclosed { in name template"},
+		{"unexpected brace", "{book}}_{n}", "unexpected } in name template"},
+		{"nested braces", "{{n}}", "unknown field {{n} in name template"},
+		{"format of text field", "{orig:03}", "invalid format of field {orig:03} in name template"},
+		{"format not a number", "{n:ab}", "invalid format of field {n:ab} in name template"},
+		{"format too wide", "{n:10}", "invalid format of field {n:10} in name template"},
+		{"negative format", "{n:-1}", "invalid format of field {n:-1} in name template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseNameTemplate(tt.template)
+			if err == nil {
+				t.Fatalf("ParseNameTemplate(%q) succeeded, want an error", tt.template)
+			}
+
+			if err.Error() != tt.wantErr {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNameTemplateName(t *testing.T) {
+	newImg := func(prov Provenance) *Image {
+		img := newTestImg(t, 30, 20, func(x, y int) color.NRGBA {
+			return color.NRGBA{255, 255, 255, 255}
+		})
+		img.Filename = "scan.png"
+		img.Provenance = prov
+		return img
+	}
+
+	img := newImg(Provenance{})
+	page := newImg(Provenance{Path: "/books/book.pdf", Page: 12})
+	entry := newImg(Provenance{Path: "/books/book.cbz", Entry: "ch1/p05.jpg"})
+
+	tests := []struct {
+		name     string
+		template string
+		img      *Image
+		ctx      NameContext
+		want     string
+	}{
+		{"literal", "cover", img, NameContext{}, "cover"},
+		{"padded number", "{book}_{n:03}", img, NameContext{Book: "book", N: 7}, "book_007"},
+		{"number wider than padding", "{n:02}", img, NameContext{N: 1234}, "1234"},
+		{"no padding", "{n}", img, NameContext{N: 7}, "7"},
+		{"zero padding", "{n:0}", img, NameContext{N: 7}, "7"},
+		{"size", "{w}x{h:04}", img, NameContext{}, "30x0020"},
+		{"page", "p{page:03}", page, NameContext{N: 1}, "p012"},
+		{"page of unknown source", "p{page}", img, NameContext{N: 4}, "p4"},
+		{"original name", "{orig}", entry, NameContext{}, "p05"},
+		{"original name of unknown source", "{orig}", img, NameContext{}, "scan.png"},
+		{"slash in book", "{book}_{n}", img, NameContext{Book: "a/b", N: 1}, "a_b_1"},
+		{"backslash in book", "{book}", img, NameContext{Book: `a\b`}, "a_b"},
+		{"separator in literal", "x/{n}", img, NameContext{N: 1}, "x_1"},
+		{"invalid characters in book", "{book}", img, NameContext{Book: `Vol: 1*?"<>|`}, "Vol_ 1______"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseNameTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := tmpl.Name(tt.img, tt.ctx); got != tt.want {
+				t.Errorf("got name %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameTemplateNames(t *testing.T) {
+	fill := func(x, y int) color.NRGBA { return color.NRGBA{0, 0, 0, 255} }
+	imgs := []*Image{newTestImg(t, 4, 4, fill), newTestImg(t, 4, 4, fill), newTestImg(t, 4, 4, fill)}
+
+	tmpl, err := ParseNameTemplate("{book}-{n:02}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"b-09", "b-10", "b-11"}
+	if got := tmpl.Names(imgs, "b", 9); !slices.Equal(got, want) {
+		t.Errorf("got names %v, want %v", got, want)
+	}
+}
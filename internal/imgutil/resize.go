@@ -0,0 +1,117 @@
+package imgutil
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ResizeMode is the way images are resized before they are exported
+type ResizeMode int
+
+const (
+	// ResizeNone keeps the original size of the images
+	ResizeNone ResizeMode = iota
+
+	// ResizeFit downscales the images to fit in MaxWidth x MaxHeight
+	ResizeFit
+
+	// ResizeWidth resizes the images to Width, keeping the aspect ratio
+	ResizeWidth
+
+	// ResizePercent scales the images by Percent
+	ResizePercent
+)
+
+// ResampleFilterNames lists the resampling filters that can be chosen on export
+var ResampleFilterNames = []string{"Lanczos", "CatmullRom", "Box"}
+
+// ResampleFilterByName returns the resampling filter with the given name,
+// Lanczos is returned for unknown names
+func ResampleFilterByName(name string) imaging.ResampleFilter {
+	switch name {
+	case "CatmullRom":
+		return imaging.CatmullRom
+	case "Box":
+		return imaging.Box
+	default:
+		return imaging.Lanczos
+	}
+}
+
+// ResizeOptions stores how images are resized before they are exported
+type ResizeOptions struct {
+	// Mode is the way images are resized
+	Mode ResizeMode
+
+	// MaxWidth and MaxHeight bound the size of the images in ResizeFit mode,
+	// zero means no bound on that side
+	MaxWidth  int
+	MaxHeight int
+
+	// Width is the width of the images in ResizeWidth mode
+	Width int
+
+	// Percent is the scale of the images in ResizePercent mode
+	Percent int
+
+	// Filter is the resampling filter used to resize the images
+	Filter imaging.ResampleFilter
+
+	// Sharpen is the sigma of the sharpening applied after resizing,
+	// zero means no sharpening
+	Sharpen float64
+}
+
+// targetSize returns the size an image of w x h is resized to
+func (o ResizeOptions) targetSize(w, h int) (int, int) {
+	switch o.Mode {
+	case ResizeFit:
+		scale := 1.0
+		if o.MaxWidth > 0 && w > o.MaxWidth {
+			scale = min(scale, float64(o.MaxWidth)/float64(w))
+		}
+		if o.MaxHeight > 0 && h > o.MaxHeight {
+			scale = min(scale, float64(o.MaxHeight)/float64(h))
+		}
+		return scaleSize(w, h, scale)
+	case ResizeWidth:
+		if o.Width <= 0 {
+			return w, h
+		}
+		return scaleSize(w, h, float64(o.Width)/float64(w))
+	case ResizePercent:
+		if o.Percent <= 0 {
+			return w, h
+		}
+		return scaleSize(w, h, float64(o.Percent)/100)
+	default:
+		return w, h
+	}
+}
+
+// Apply resizes the image according to the options.
+// The image is returned as is if no resizing is needed.
+func (o ResizeOptions) Apply(img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := o.targetSize(bounds.Dx(), bounds.Dy())
+	if w == bounds.Dx() && h == bounds.Dy() {
+		return img
+	}
+
+	filter := o.Filter
+	if filter.Support == 0 && filter.Kernel == nil {
+		filter = imaging.Lanczos
+	}
+
+	var resized image.Image = imaging.Resize(img, w, h, filter)
+	if o.Sharpen > 0 {
+		resized = imaging.Sharpen(resized, o.Sharpen)
+	}
+
+	return resized
+}
+
+func scaleSize(w, h int, scale float64) (int, int) {
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
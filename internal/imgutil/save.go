@@ -3,16 +3,191 @@ package imgutil
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"fmt"
+	"image"
 	"image/jpeg"
 	"io"
+	"runtime"
+	"strings"
 
 	"github.com/VoileLab/goimgpack/internal/util"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 )
 
+// SaveOptions stores the options used to encode images on export
+type SaveOptions struct {
+	// Quality is the quality of the JPEG encoder
+	Quality int
+
+	// Resize is the resizing applied to every image before encoding
+	Resize ResizeOptions
+
+	// Scale is an extra scale factor applied after Resize,
+	// zero or one means no scaling
+	Scale float64
+
+	// MaxImageSize lowers the JPEG quality of the images larger than it
+	// once encoded, down to the lowest quality tried for a target size.
+	// Zero means no limit.
+	MaxImageSize int64
+
+	// Passthrough copies the source of unedited JPEG images as is
+	// instead of re-encoding them, when they are not resized
+	Passthrough bool
+
+	// Metadata is how the metadata of the images is kept in the JPEG images
+	Metadata MetadataMode
+
+	// EmbedSRGB embeds an sRGB ICC profile in the encoded images
+	EmbedSRGB bool
+
+	// EntryNames is the template of the names of the images in an archive,
+	// nil means the filenames of the images. The images are numbered from 1.
+	EntryNames *NameTemplate
+
+	// Book is the {book} of EntryNames, usually the name of the archive
+	Book string
+
+	// Workers is the number of images encoded concurrently,
+	// zero means one per CPU
+	Workers int
+
+	// Progress is called after each image is saved, it may be nil
+	Progress ProgressFunc
+}
+
+// prepare returns the image resized according to the options
+func (o SaveOptions) prepare(img image.Image) image.Image {
+	img = o.Resize.Apply(img)
+	if o.Scale <= 0 || o.Scale == 1 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := scaleSize(bounds.Dx(), bounds.Dy(), o.Scale)
+	return ResizeOptions{Mode: ResizeFit, MaxWidth: w, MaxHeight: h, Filter: o.Resize.Filter}.Apply(img)
+}
+
+// keepsSize reports whether an image of bounds is saved in its own size
+func (o SaveOptions) keepsSize(bounds image.Rectangle) bool {
+	if o.Scale > 0 && o.Scale != 1 {
+		return false
+	}
+
+	w, h := o.Resize.targetSize(bounds.Dx(), bounds.Dy())
+	return w == bounds.Dx() && h == bounds.Dy()
+}
+
+// passthroughSource returns the source of the image if it can be saved
+// losslessly as is: an unedited upright sRGB JPEG image which is not resized.
+// nil is returned otherwise.
+func passthroughSource(img *Image, opts SaveOptions) ([]byte, error) {
+	if !opts.Passthrough || img.IsEdited() || img.Type != formatJPEG ||
+		img.Color != "" || !opts.keepsSize(img.Bounds()) {
+		return nil, nil
+	}
+
+	// the viewers may ignore the orientation, the image is re-encoded upright
+	if img.meta.orientation() != 1 {
+		return nil, nil
+	}
+
+	bs, err := img.src.bytes()
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	// a source over the size limit is re-encoded in a lower quality
+	if opts.MaxImageSize > 0 && int64(len(bs)) > opts.MaxImageSize {
+		return nil, nil
+	}
+
+	return bs, nil
+}
+
+// encodePrepared encodes the prepared image as JPEG in opts.Quality,
+// or in the highest lower quality that fits in opts.MaxImageSize.
+// The lowest quality is used if none fits.
+func encodePrepared(img image.Image, opts SaveOptions) ([]byte, error) {
+	encode := func(quality int) ([]byte, error) {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	bs, err := encode(opts.Quality)
+	if err != nil || opts.MaxImageSize <= 0 || int64(len(bs)) <= opts.MaxImageSize {
+		return bs, err
+	}
+
+	// the search ends with the lowest quality if nothing fits
+	lo, hi := targetMinQuality, opts.Quality-1
+	var best []byte
+	for lo <= hi {
+		quality := (lo + hi) / 2
+		bs, err = encode(quality)
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(bs)) <= opts.MaxImageSize {
+			best = bs
+			lo = quality + 1
+		} else {
+			hi = quality - 1
+		}
+	}
+
+	if best == nil {
+		return bs, nil
+	}
+
+	return best, nil
+}
+
+// encodeJPEG encodes the image as JPEG with the given options,
+// the source is copied instead if it can be saved losslessly.
+// The metadata of the image is written according to opts.Metadata.
+func encodeJPEG(w io.Writer, img *Image, opts SaveOptions) error {
+	bs, err := passthroughSource(img, opts)
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	// the metadata of a copied source is kept untouched
+	if bs == nil || opts.Metadata != MetadataKeep {
+		if bs == nil {
+			decoded, err := img.Img()
+			if err != nil {
+				return util.Errorf("%w", err)
+			}
+
+			bs, err = encodePrepared(opts.prepare(decoded), opts)
+			if err != nil {
+				return util.Errorf("%w", err)
+			}
+
+			if opts.EmbedSRGB {
+				bs = replaceJPEGMetadata(bs, jpegICCSegments(srgbProfile()))
+			}
+		}
+
+		bs = replaceJPEGMetadata(bs, jpegMetadataSegments(img.meta, opts.Metadata))
+	}
+
+	if _, err := w.Write(bs); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
 // SaveImg saves an image to a file
-func SaveImg(img *Image, f io.Writer, quality int) error {
-	err := jpeg.Encode(f, img.Img, &jpeg.Options{Quality: quality})
+func SaveImg(img *Image, f io.Writer, opts SaveOptions) error {
+	err := encodeJPEG(f, img, opts)
 	if err != nil {
 		return util.Errorf("%w", err)
 	}
@@ -21,47 +196,183 @@ func SaveImg(img *Image, f io.Writer, quality int) error {
 }
 
 // SaveImgsAsZip saves images as a zip file
-func SaveImgsAsZip(imgs []*Image, f io.Writer, prependDigit bool, quality int) error {
+func SaveImgsAsZip(imgs []*Image, f io.Writer, prependDigit bool, opts SaveOptions) error {
+	return SaveImgsAsZipContext(context.Background(), imgs, f, prependDigit, opts)
+}
+
+// SaveImgsAsZipContext saves images as a zip file named by ZipEntryNames,
+// it stops when ctx is done and reports the progress to opts.Progress
+func SaveImgsAsZipContext(ctx context.Context, imgs []*Image, f io.Writer,
+	prependDigit bool, opts SaveOptions) error {
+
 	zipWriter := zip.NewWriter(f)
 	defer zipWriter.Close()
 
-	imgLenDigits := util.CountDigits(len(imgs))
-	for i, img := range imgs {
-		filename := img.Filename + ".jpg"
-		if prependDigit {
-			filename = util.PaddingZero(i, imgLenDigits) + "_" + filename
-		}
-		imgFile, err := zipWriter.Create(filename)
+	names, _ := ZipEntryNames(imgs, prependDigit, opts)
+	err := encodeOrdered(ctx, imgs, opts, encodeJPEGBytes, func(i int, bs []byte) error {
+		imgFile, err := zipWriter.Create(names[i])
 		if err != nil {
 			return util.Errorf("%w", err)
 		}
 
-		err = jpeg.Encode(imgFile, img.Img, &jpeg.Options{Quality: quality})
+		_, err = imgFile.Write(bs)
 		if err != nil {
 			return util.Errorf("%w", err)
 		}
+
+		return nil
+	})
+	if err != nil {
+		return util.Errorf("%w", err)
 	}
 
 	return nil
 }
 
-// SaveImgsAsPDF saves images as a PDF file
-func SaveImgsAsPDF(imgs []*Image, f io.Writer, quality int) error {
-	imgsReader := make([]io.Reader, len(imgs))
+// ZipEntryNames returns the names of the images in the archive written by
+// SaveImgsAsZip and the number of names changed to avoid collisions.
+// The names are the filenames or opts.EntryNames, prefixed with the index
+// if prependDigit is set. A name used by an earlier image gets the first
+// free suffix _2, _3..., compared case-insensitively.
+func ZipEntryNames(imgs []*Image, prependDigit bool, opts SaveOptions) ([]string, int) {
+	return entryNames(imgs, prependDigit, opts, ".jpg")
+}
+
+// entryNames returns the names of the images with the extension ext
+// like ZipEntryNames
+func entryNames(imgs []*Image, prependDigit bool, opts SaveOptions, ext string) ([]string, int) {
+	return uniqueNames(baseEntryNames(imgs, prependDigit, opts), ext)
+}
+
+// baseEntryNames returns the names of the images like ZipEntryNames
+// before they are made unique and get their extension
+func baseEntryNames(imgs []*Image, prependDigit bool, opts SaveOptions) []string {
+	// the padding fits the highest index written
+	digits := util.CountDigits(max(len(imgs)-1, 0))
+
+	names := make([]string, len(imgs))
 	for i, img := range imgs {
-		buf := new(bytes.Buffer)
-		err := jpeg.Encode(buf, img.Img, &jpeg.Options{Quality: quality})
-		if err != nil {
-			return util.Errorf("%w", err)
+		name := img.Filename
+		if opts.EntryNames != nil {
+			name = opts.EntryNames.Name(img, NameContext{Book: opts.Book, N: i + 1})
+		}
+		if prependDigit {
+			name = util.PaddingZero(i, digits) + "_" + name
 		}
+		names[i] = name
+	}
 
-		imgsReader[i] = buf
+	return names
+}
+
+// uniqueNames appends ext to the names and makes them unique by suffixes,
+// the first of the same names is kept. It returns the names and the number
+// of names changed.
+func uniqueNames(names []string, ext string) ([]string, int) {
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		used[strings.ToLower(name+ext)] = false
+	}
+
+	changed := 0
+	unique := make([]string, len(names))
+	for i, name := range names {
+		key := strings.ToLower(name + ext)
+		if !used[key] {
+			used[key] = true
+			unique[i] = name + ext
+			continue
+		}
+
+		// the suffixed name must not be a name of another image either
+		for n := 2; ; n++ {
+			suffixed := fmt.Sprintf("%s_%d%s", name, n, ext)
+			if _, ok := used[strings.ToLower(suffixed)]; !ok {
+				used[strings.ToLower(suffixed)] = true
+				unique[i] = suffixed
+				break
+			}
+		}
+		changed++
 	}
 
-	err := api.ImportImages(nil, f, imgsReader, nil, nil)
+	return unique, changed
+}
+
+// SaveImgsAsPDF saves images as a PDF file
+func SaveImgsAsPDF(imgs []*Image, f io.Writer, opts SaveOptions) error {
+	return SaveImgsAsPDFContext(context.Background(), imgs, f, opts)
+}
+
+// SaveImgsAsPDFContext saves images as a PDF file,
+// it stops when ctx is done and reports the progress to opts.Progress
+func SaveImgsAsPDFContext(ctx context.Context, imgs []*Image, f io.Writer, opts SaveOptions) error {
+	imgsReader := make([]io.Reader, len(imgs))
+	err := encodeOrdered(ctx, imgs, opts, encodeJPEGBytes, func(i int, bs []byte) error {
+		imgsReader[i] = bytes.NewReader(bs)
+		return nil
+	})
 	if err != nil {
 		return util.Errorf("%w", err)
 	}
 
+	err = api.ImportImages(nil, f, imgsReader, nil, nil)
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// encodeJPEGBytes encodes the image as JPEG with the given options
+func encodeJPEGBytes(img *Image, opts SaveOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := encodeJPEG(buf, img, opts); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeOrdered encodes the images with encode on opts.Workers goroutines
+// and calls write with the encoded images in their order.
+// The images are encoded in batches, so only a few encoded images
+// are kept in memory at once.
+func encodeOrdered[T any](ctx context.Context, imgs []*Image, opts SaveOptions,
+	encode func(img *Image, opts SaveOptions) (T, error),
+	write func(i int, encoded T) error) error {
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	batchSize := workers * 2
+
+	for start := 0; start < len(imgs); start += batchSize {
+		batch := imgs[start:min(start+batchSize, len(imgs))]
+		encoded := make([]T, len(batch))
+
+		err := parallelDo(ctx, len(batch), workers, func(i int) error {
+			v, err := encode(batch[i], opts)
+			if err != nil {
+				return util.Errorf("%w", err)
+			}
+
+			encoded[i] = v
+			return nil
+		})
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		for i, v := range encoded {
+			if err := write(start+i, v); err != nil {
+				return util.Errorf("%w", err)
+			}
+
+			opts.Progress.report(start+i+1, len(imgs))
+		}
+	}
+
 	return nil
 }
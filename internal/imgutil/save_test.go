@@ -0,0 +1,146 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestUniqueNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		names       []string
+		want        []string
+		wantChanged int
+	}{
+		{
+			name:  "no collision",
+			names: []string{"a", "b", "c"},
+			want:  []string{"a.jpg", "b.jpg", "c.jpg"},
+		},
+		{
+			// the suffixes skip the names of the other images,
+			// which are compared case-insensitively
+			name:        "case and suffix collisions",
+			names:       []string{"a", "A", "a_2", "a"},
+			want:        []string{"a.jpg", "A_3.jpg", "a_2.jpg", "a_4.jpg"},
+			wantChanged: 2,
+		},
+		{
+			name:        "same names",
+			names:       []string{"x", "x", "x"},
+			want:        []string{"x.jpg", "x_2.jpg", "x_3.jpg"},
+			wantChanged: 2,
+		},
+		{
+			name:  "empty",
+			names: []string{},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := uniqueNames(tt.names, ".jpg")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got names %v, want %v", got, tt.want)
+			}
+
+			if changed != tt.wantChanged {
+				t.Errorf("got %d names changed, want %d", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+// newTestNamedImgs creates n images named by name
+func newTestNamedImgs(t *testing.T, n int, name func(i int) string) []*Image {
+	imgs := make([]*Image, n)
+	for i := range imgs {
+		imgs[i] = newTestImg(t, 4, 4, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(i), 0, 0, 255}
+		})
+		imgs[i].Filename = name(i)
+	}
+
+	return imgs
+}
+
+func TestZipEntryNamesPadding(t *testing.T) {
+	tests := []struct {
+		n         int
+		wantFirst string
+		wantLast  string
+	}{
+		// the padding fits the highest index, which is len-1
+		{1, "0_p.jpg", "0_p.jpg"},
+		{10, "0_p.jpg", "9_p_10.jpg"},
+		{11, "00_p.jpg", "10_p_11.jpg"},
+		{101, "000_p.jpg", "100_p_101.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			imgs := newTestNamedImgs(t, tt.n, func(i int) string {
+				if i == 0 {
+					return "p"
+				}
+				return fmt.Sprintf("p_%d", i+1)
+			})
+
+			names, changed := ZipEntryNames(imgs, true, SaveOptions{})
+			if names[0] != tt.wantFirst || names[len(names)-1] != tt.wantLast {
+				t.Errorf("got names %s ... %s, want %s ... %s",
+					names[0], names[len(names)-1], tt.wantFirst, tt.wantLast)
+			}
+
+			if changed != 0 {
+				t.Errorf("got %d names changed, want 0", changed)
+			}
+		})
+	}
+}
+
+func TestZipEntryNamesTemplate(t *testing.T) {
+	imgs := newTestNamedImgs(t, 3, func(i int) string { return "img" })
+
+	tmpl, err := ParseNameTemplate("{book}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, changed := ZipEntryNames(imgs, false, SaveOptions{EntryNames: tmpl, Book: "vol"})
+	want := []string{"vol.jpg", "vol_2.jpg", "vol_3.jpg"}
+	if !slices.Equal(names, want) || changed != 2 {
+		t.Errorf("got names %v with %d changed, want %v with 2 changed", names, changed, want)
+	}
+}
+
+func TestSaveImgsAsZipNames(t *testing.T) {
+	imgs := newTestNamedImgs(t, 4, func(i int) string {
+		return []string{"a", "A", "a_2", "a"}[i]
+	})
+
+	buf := new(bytes.Buffer)
+	if err := SaveImgsAsZip(imgs, buf, false, SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range r.File {
+		got = append(got, f.Name)
+	}
+
+	want, _ := ZipEntryNames(imgs, false, SaveOptions{})
+	if !slices.Equal(got, want) {
+		t.Errorf("got entries %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,262 @@
+package imgutil
+
+import (
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// compactMinDead is the least size in bytes of the freed data
+// of the temp file before the store is compacted
+const compactMinDead = 64 << 20
+
+// source is the compressed data of an image,
+// it is kept either in memory or in the temp file of the store
+type source struct {
+	data []byte
+
+	// blob is where the data is in the temp file, nil if it is in memory
+	blob *blob
+}
+
+// blob is the location of a source in the temp file of the store,
+// it is moved when the store is compacted
+type blob struct {
+	file *os.File
+	off  int64
+	size int64
+}
+
+// bytes returns the compressed data of the image
+func (s *source) bytes() ([]byte, error) {
+	if s.blob == nil {
+		return s.data, nil
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	bs := make([]byte, s.blob.size)
+	if _, err := s.blob.file.ReadAt(bs, s.blob.off); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	return bs, nil
+}
+
+// sourceStore appends the sources to a temp file when it is enabled.
+// The data of a source is freed once the source is garbage collected,
+// and the temp file is compacted when most of it is freed.
+type sourceStore struct {
+	mu      sync.RWMutex
+	enabled bool
+	file    *os.File
+
+	// size is the size of the temp file
+	size int64
+
+	// dead is the size of the freed data in the temp file
+	dead int64
+
+	// blobs are the sources in the temp file which are not freed
+	blobs map[*blob]struct{}
+
+	// compacting is set while the temp file is compacted
+	compacting bool
+}
+
+var store = &sourceStore{blobs: make(map[*blob]struct{})}
+
+// UseDiskStore sets whether the sources of the images read from now on
+// are kept in a temp file instead of memory
+func UseDiskStore(enabled bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.enabled = enabled
+}
+
+// CloseStore removes the temp file of the store,
+// the images whose source is in the temp file cannot be used anymore.
+func CloseStore() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.file == nil {
+		return nil
+	}
+
+	name := store.file.Name()
+	err := store.file.Close()
+	store.file = nil
+	store.size = 0
+	store.dead = 0
+	clear(store.blobs)
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	if err := os.Remove(name); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// newSource stores the compressed data of an image
+func newSource(bs []byte) (*source, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.enabled {
+		return &source{data: bs}, nil
+	}
+
+	if store.file == nil {
+		f, err := os.CreateTemp("", "goimgpack-store-*")
+		if err != nil {
+			return nil, util.Errorf("%w", err)
+		}
+		store.file = f
+	}
+
+	if _, err := store.file.WriteAt(bs, store.size); err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	// the temp file is compacted in the background so the decoding
+	// is not blocked, it keeps growing if it cannot be compacted
+	if !store.compacting && store.dead >= compactMinDead && store.dead*2 > store.size {
+		store.compacting = true
+		go store.compact()
+	}
+
+	b := &blob{file: store.file, off: store.size, size: int64(len(bs))}
+	store.size += b.size
+	store.blobs[b] = struct{}{}
+
+	src := &source{blob: b}
+	runtime.SetFinalizer(src, func(s *source) {
+		store.free(s.blob)
+	})
+
+	return src, nil
+}
+
+// free marks the data of b as freed,
+// the temp file is emptied when no source is left in it
+func (s *sourceStore) free(b *blob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blobs[b]; !ok {
+		return
+	}
+
+	delete(s.blobs, b)
+	s.dead += b.size
+
+	if len(s.blobs) == 0 && s.file != nil {
+		if err := s.file.Truncate(0); err == nil {
+			s.size = 0
+			s.dead = 0
+		}
+	}
+}
+
+// compact moves the sources left to a new temp file and removes
+// the old one. The sources are copied without holding s.mu, only the
+// sources added meanwhile are copied while it is held.
+func (s *sourceStore) compact() error {
+	defer func() {
+		s.mu.Lock()
+		s.compacting = false
+		s.mu.Unlock()
+	}()
+
+	s.mu.RLock()
+	old := s.file
+	// the blobs only move when the store is compacted,
+	// so their locations stay valid without the lock
+	locs := make(map[*blob]blob, len(s.blobs))
+	for b := range s.blobs {
+		locs[b] = *b
+	}
+	s.mu.RUnlock()
+
+	if old == nil {
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "goimgpack-store-*")
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	// the new temp file is removed unless it replaces the old one
+	replaced := false
+	defer func() {
+		if !replaced {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	var size int64
+	offs := make(map[*blob]int64, len(locs))
+	copyBlob := func(b *blob, loc blob) error {
+		bs := make([]byte, loc.size)
+		if _, err := loc.file.ReadAt(bs, loc.off); err != nil {
+			return util.Errorf("%w", err)
+		}
+		if _, err := f.WriteAt(bs, size); err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		offs[b] = size
+		size += loc.size
+		return nil
+	}
+
+	for b, loc := range locs {
+		if err := copyBlob(b, loc); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the store was closed meanwhile
+	if s.file != old {
+		return nil
+	}
+
+	var live int64
+	for b := range s.blobs {
+		if _, ok := offs[b]; !ok {
+			if err := copyBlob(b, *b); err != nil {
+				return err
+			}
+		}
+		live += b.size
+	}
+
+	for b, off := range offs {
+		b.file = f
+		b.off = off
+	}
+
+	old.Close()
+	os.Remove(old.Name())
+
+	s.file = f
+	s.size = size
+	// the sources freed meanwhile are left in the new temp file
+	s.dead = size - live
+	replaced = true
+
+	return nil
+}
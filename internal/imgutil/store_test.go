@@ -0,0 +1,195 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// useTestDiskStore keeps the sources of the test in a temp file
+func useTestDiskStore(t *testing.T) {
+	UseDiskStore(true)
+	t.Cleanup(func() {
+		UseDiskStore(false)
+		if err := CloseStore(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// newTestSource stores data and checks that it is kept in the temp file
+func newTestSource(t *testing.T, data []byte) *source {
+	t.Helper()
+
+	src, err := newSource(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.blob == nil {
+		t.Fatal("the source is kept in memory")
+	}
+
+	return src
+}
+
+// dropTestSource frees the source as its finalizer does
+func dropTestSource(src *source) {
+	runtime.SetFinalizer(src, nil)
+	store.free(src.blob)
+}
+
+// testSourceData returns distinct data of the i-th source
+func testSourceData(i int) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("source %d;", i)), 100+i)
+}
+
+func checkTestSource(t *testing.T, src *source, want []byte) {
+	t.Helper()
+
+	got, err := src.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %d bytes, want %d bytes", len(got), len(want))
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	useTestDiskStore(t)
+
+	srcs := make([]*source, 10)
+	for i := range srcs {
+		srcs[i] = newTestSource(t, testSourceData(i))
+	}
+
+	var live int64
+	for i, src := range srcs {
+		if i%3 == 0 {
+			live += src.blob.size
+		} else {
+			dropTestSource(src)
+		}
+	}
+
+	oldName := store.file.Name()
+	if err := store.compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(oldName); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the old temp file is left: %v", err)
+	}
+	if store.size != live || store.dead != 0 || store.compacting {
+		t.Errorf("got size %d and dead %d, want size %d", store.size, store.dead, live)
+	}
+
+	for i := 0; i < len(srcs); i += 3 {
+		checkTestSource(t, srcs[i], testSourceData(i))
+	}
+
+	// the sources are appended after the compacted ones
+	src := newTestSource(t, testSourceData(10))
+	if src.blob.off != live {
+		t.Errorf("the new source is at %d, want %d", src.blob.off, live)
+	}
+	checkTestSource(t, src, testSourceData(10))
+}
+
+func TestStoreFreeAll(t *testing.T) {
+	useTestDiskStore(t)
+
+	srcs := []*source{newTestSource(t, testSourceData(0)), newTestSource(t, testSourceData(1))}
+	for _, src := range srcs {
+		dropTestSource(src)
+	}
+
+	// the temp file is emptied once nothing is left in it
+	info, err := store.file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || store.size != 0 || store.dead != 0 {
+		t.Errorf("got a temp file of %d bytes, size %d and dead %d", info.Size(), store.size, store.dead)
+	}
+
+	if err := store.compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkTestSource(t, newTestSource(t, testSourceData(2)), testSourceData(2))
+}
+
+func TestStoreCompactConcurrent(t *testing.T) {
+	useTestDiskStore(t)
+
+	var srcs []*source
+	for i := range 20 {
+		src := newTestSource(t, testSourceData(i))
+		if i%2 == 0 {
+			srcs = append(srcs, src)
+		} else {
+			dropTestSource(src)
+		}
+	}
+
+	// the sources are read and added while the store is compacted
+	var wg sync.WaitGroup
+	added := make([]*source, 20)
+	for i := range added {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			bs, err := srcs[i%len(srcs)].bytes()
+			if err != nil || !bytes.Equal(bs, testSourceData(i%len(srcs)*2)) {
+				t.Errorf("the source %d is not read back: %v", i%len(srcs), err)
+			}
+
+			src, err := newSource(testSourceData(100 + i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			added[i] = src
+		}()
+	}
+
+	if err := store.compact(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	for i, src := range srcs {
+		checkTestSource(t, src, testSourceData(i*2))
+	}
+	for i, src := range added {
+		checkTestSource(t, src, testSourceData(100+i))
+	}
+}
+
+func TestStoreImage(t *testing.T) {
+	useTestDiskStore(t)
+
+	bs := encodeTestJPEG(t)
+	img, err := newImgFromBytes(bs, "page.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropTestSource(newTestSource(t, testSourceData(0)))
+
+	if err := store.compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the image is decoded again from the compacted temp file
+	RetainCache(nil)
+	checkTestSource(t, img.src, bs)
+	if _, err := img.Img(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,120 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+var SupportedVectorExts = []string{".svg"}
+
+const (
+	// svgBaseDPI is the resolution of the user units of SVG (CSS pixels)
+	svgBaseDPI = 96
+
+	// svgDefaultDPI is used when SVGOptions has neither a DPI nor a width
+	svgDefaultDPI = 150
+
+	// svgMaxSize bounds the sides of a rasterized SVG image
+	svgMaxSize = 20000
+
+	// svgSniffLen is the length of the head of a file searched for an svg tag
+	svgSniffLen = 1024
+)
+
+var errSVGSize = errors.New("SVG image has no size or is too large")
+
+// SVGOptions stores how SVG images are rasterized on import
+type SVGOptions struct {
+	// DPI is the resolution of the rasterized image
+	DPI float64
+
+	// Width is the width of the rasterized image, keeping the aspect ratio,
+	// it takes precedence over DPI when it is not zero
+	Width int
+
+	// Background fills the image before the SVG is drawn,
+	// nil keeps the background transparent
+	Background color.Color
+}
+
+// size returns the size of the rasterized image of an SVG of w x h user units
+func (o SVGOptions) size(w, h float64) (int, int) {
+	if o.Width > 0 {
+		return o.Width, int(math.Round(float64(o.Width) * h / w))
+	}
+
+	dpi := o.DPI
+	if dpi <= 0 {
+		dpi = svgDefaultDPI
+	}
+
+	scale := dpi / svgBaseDPI
+	return int(math.Round(w * scale)), int(math.Round(h * scale))
+}
+
+// isSVG reports whether the file is an SVG image by its extension
+// or by an svg root element at its head, after the XML declaration,
+// the comments and the doctype
+func isSVG(filename string, bs []byte) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".svg") {
+		return true
+	}
+
+	head := bs[:min(len(bs), svgSniffLen)]
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+
+	for {
+		head = bytes.TrimLeft(head, " \t\r\n")
+
+		end := []byte(">")
+		switch {
+		case bytes.HasPrefix(head, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(head, []byte("<?")), bytes.HasPrefix(head, []byte("<!")):
+		default:
+			return bytes.HasPrefix(head, []byte("<svg"))
+		}
+
+		i := bytes.Index(head, end)
+		if i < 0 {
+			return false
+		}
+		head = head[i+len(end):]
+	}
+}
+
+// rasterizeSVG draws the SVG image bs with opts
+func rasterizeSVG(bs []byte, opts SVGOptions) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(bs), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, util.Errorf("%w", err)
+	}
+
+	w, h := opts.size(icon.ViewBox.W, icon.ViewBox.H)
+	if w <= 0 || h <= 0 || w > svgMaxSize || h > svgMaxSize {
+		return nil, util.Errorf("%w: %dx%d", errSVGSize, w, h)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if opts.Background != nil {
+		draw.Draw(img, img.Rect, image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	icon.SetTarget(0, 0, float64(w), float64(h))
+
+	scanner := rasterx.NewScannerGV(w, h, img, img.Rect)
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+
+	return img, nil
+}
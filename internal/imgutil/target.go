@@ -0,0 +1,144 @@
+package imgutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+const (
+	// targetMinQuality is the lowest JPEG quality tried to reach a target size
+	targetMinQuality = 10
+
+	// targetMinScale is the smallest scale tried to reach a target size
+	targetMinScale = 0.2
+
+	// targetLimitSteps is the number of image size limits tried per scale
+	targetLimitSteps = 8
+)
+
+// ErrTargetSizeNotReached is returned when the output cannot fit the target
+// size even with the lowest quality and scale.
+var ErrTargetSizeNotReached = errors.New("target size not reached")
+
+// Saver writes images with the given options.
+// It is used to measure the output size when searching for a target size.
+type Saver func(ctx context.Context, w io.Writer, opts SaveOptions) error
+
+// countWriter counts the bytes written to it
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// measure returns the size of the output of save with opts
+func measure(ctx context.Context, save Saver, opts SaveOptions) (int64, error) {
+	w := &countWriter{}
+	if err := save(ctx, w, opts); err != nil {
+		return 0, util.Errorf("%w", err)
+	}
+
+	return w.n, nil
+}
+
+// FitTargetSize fits the output of save in target bytes by lowering the
+// JPEG quality of the largest images first: the images keep opts.Quality
+// unless they are larger than the opts.MaxImageSize found, so the easy
+// pages stay sharp while the large ones are squeezed.
+// If allowDownscale is set and the lowest quality does not fit,
+// the images are downscaled step by step until the output fits.
+// The options found and the size of the output are returned.
+// If the output never fits, the smallest options tried are returned
+// with ErrTargetSizeNotReached.
+// Each try is reported to opts.Progress with a total of zero.
+// The sources are never copied as is, so every image follows the quality.
+func FitTargetSize(ctx context.Context, save Saver, opts SaveOptions,
+	target int64, allowDownscale bool) (SaveOptions, int64, error) {
+
+	maxQuality := max(opts.Quality, targetMinQuality)
+	opts.Scale = 1
+	opts.Passthrough = false
+
+	// the progress of a single try is meaningless for the caller,
+	// the tries are reported with an unknown total instead
+	progress := opts.Progress
+	opts.Progress = nil
+	result := func() SaveOptions {
+		opts.Progress = progress
+		return opts
+	}
+
+	tries := 0
+	try := func() (int64, error) {
+		size, err := measure(ctx, save, opts)
+		if err != nil {
+			return 0, util.Errorf("%w", err)
+		}
+
+		tries++
+		progress.report(tries, 0)
+		return size, nil
+	}
+
+	for {
+		// every image in the highest quality
+		opts.Quality, opts.MaxImageSize = maxQuality, 0
+		fullSize, err := try()
+		if err != nil {
+			return result(), 0, err
+		}
+		if fullSize <= target {
+			return result(), fullSize, nil
+		}
+
+		// every image in the lowest quality
+		opts.Quality = targetMinQuality
+		minSize, err := try()
+		if err != nil {
+			return result(), 0, err
+		}
+
+		if minSize <= target {
+			// binary search the largest image size limit which fits,
+			// lo always fits and hi never does
+			opts.Quality = maxQuality
+			lo, hi := int64(1), fullSize
+			bestSize := minSize
+			for range targetLimitSteps {
+				if hi-lo <= 1 {
+					break
+				}
+
+				opts.MaxImageSize = lo + (hi-lo)/2
+				size, err := try()
+				if err != nil {
+					return result(), 0, err
+				}
+
+				if size <= target {
+					lo, bestSize = opts.MaxImageSize, size
+				} else {
+					hi = opts.MaxImageSize
+				}
+			}
+
+			opts.MaxImageSize = lo
+			return result(), bestSize, nil
+		}
+
+		if !allowDownscale || opts.Scale <= targetMinScale {
+			return result(), minSize, util.Errorf("%w", ErrTargetSizeNotReached)
+		}
+
+		// the size is roughly proportional to the pixel count
+		ratio := math.Sqrt(float64(target)/float64(minSize)) * 0.95
+		opts.Scale = max(opts.Scale*min(max(ratio, 0.5), 0.9), targetMinScale)
+	}
+}
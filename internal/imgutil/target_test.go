@@ -0,0 +1,180 @@
+package imgutil
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"testing"
+)
+
+// fakePages simulates the output of images whose encoded size is
+// proportional to the quality and to the pixel count
+type fakePages struct {
+	// sizes are the sizes of the images in the quality 100
+	sizes []int64
+
+	// qualities are the qualities of the images of the last save
+	qualities []int
+}
+
+func (p *fakePages) imageSize(i, quality int, scale float64) int64 {
+	return int64(float64(p.sizes[i]*int64(quality)/100) * scale * scale)
+}
+
+func (p *fakePages) save(ctx context.Context, w io.Writer, opts SaveOptions) error {
+	if opts.Passthrough {
+		return errors.New("the sources are copied")
+	}
+
+	p.qualities = make([]int, len(p.sizes))
+	for i := range p.sizes {
+		// the quality is lowered like encodePrepared does
+		quality := opts.Quality
+		for opts.MaxImageSize > 0 && quality > targetMinQuality &&
+			p.imageSize(i, quality, opts.Scale) > opts.MaxImageSize {
+			quality--
+		}
+		p.qualities[i] = quality
+
+		if _, err := w.Write(make([]byte, p.imageSize(i, quality, opts.Scale))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestFitTargetSize(t *testing.T) {
+	pages := &fakePages{sizes: []int64{10_000, 12_000, 8_000, 200_000}}
+
+	tests := []struct {
+		name           string
+		target         int64
+		allowDownscale bool
+		err            error
+		scaled         bool
+		// squeezed is set if only the large image loses quality
+		squeezed bool
+	}{
+		{name: "fits", target: 1_000_000},
+		{name: "large image squeezed", target: 120_000, squeezed: true},
+		{name: "not reached", target: 20_000, err: ErrTargetSizeNotReached},
+		{name: "downscaled", target: 20_000, allowDownscale: true, scaled: true},
+		{name: "not reached downscaled", target: 100, allowDownscale: true,
+			err: ErrTargetSizeNotReached, scaled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tries := 0
+			opts := SaveOptions{Quality: 90, Passthrough: true,
+				Progress: func(done, total int) { tries++ }}
+
+			got, size, err := FitTargetSize(context.Background(), pages.save, opts, tt.target, tt.allowDownscale)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if got.Passthrough || got.Progress == nil {
+				t.Errorf("got passthrough %v and progress %v", got.Passthrough, got.Progress != nil)
+			}
+			if tries == 0 {
+				t.Errorf("the tries are not reported")
+			}
+
+			// the options returned give the size returned
+			w := &countWriter{}
+			if err := pages.save(context.Background(), w, got); err != nil {
+				t.Fatal(err)
+			}
+			if w.n != size {
+				t.Errorf("got size %d, the options give %d", size, w.n)
+			}
+
+			if tt.scaled != (got.Scale < 1) {
+				t.Errorf("got scale %v", got.Scale)
+			}
+
+			if tt.err != nil {
+				if size <= tt.target {
+					t.Errorf("got size %d in the target %d", size, tt.target)
+				}
+				if tt.scaled && got.Scale != targetMinScale {
+					t.Errorf("got scale %v, want the smallest scale", got.Scale)
+				}
+				return
+			}
+
+			if size > tt.target {
+				t.Errorf("got size %d over the target %d", size, tt.target)
+			}
+
+			if tt.squeezed {
+				for i, q := range pages.qualities[:3] {
+					if q != 90 {
+						t.Errorf("the small image %d has quality %d", i, q)
+					}
+				}
+				if q := pages.qualities[3]; q >= 90 || q <= targetMinQuality {
+					t.Errorf("the large image has quality %d", q)
+				}
+
+				// the search converges close to the target
+				if size < tt.target*98/100 {
+					t.Errorf("got size %d far below the target %d", size, tt.target)
+				}
+			}
+		})
+	}
+}
+
+func TestFitTargetSizeError(t *testing.T) {
+	errSave := errors.New("save failed")
+	save := func(ctx context.Context, w io.Writer, opts SaveOptions) error {
+		return errSave
+	}
+
+	_, _, err := FitTargetSize(context.Background(), save, SaveOptions{Quality: 90}, 100, true)
+	if !errors.Is(err, errSave) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestEncodePreparedMaxImageSize(t *testing.T) {
+	// a busy pattern whose size depends much on the quality
+	img, err := newTestImg(t, 64, 64, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x * y * 37), uint8(x*13 + y*y), uint8(x ^ y*7), 255}
+	}).Img()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := encodePrepared(img, SaveOptions{Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowest, err := encodePrepared(img, SaveOptions{Quality: targetMinQuality})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := int64(len(full)+len(lowest)) / 2
+	bs, err := encodePrepared(img, SaveOptions{Quality: 95, MaxImageSize: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(bs)) > limit || len(bs) <= len(lowest) {
+		t.Errorf("got %d bytes for the limit %d, the lowest quality gives %d",
+			len(bs), limit, len(lowest))
+	}
+
+	// the lowest quality is used if nothing fits
+	bs, err = encodePrepared(img, SaveOptions{Quality: 95, MaxImageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != len(lowest) {
+		t.Errorf("got %d bytes, want the lowest quality of %d", len(bs), len(lowest))
+	}
+}
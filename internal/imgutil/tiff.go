@@ -0,0 +1,444 @@
+package imgutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"github.com/hhrutter/lzw"
+	"golang.org/x/image/tiff"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+)
+
+// TIFF tags used to walk and write the pages
+const (
+	tiffTagNewSubfileType  = 254
+	tiffTagImageWidth      = 256
+	tiffTagImageLength     = 257
+	tiffTagBitsPerSample   = 258
+	tiffTagCompression     = 259
+	tiffTagPhotometric     = 262
+	tiffTagStripOffsets    = 273
+	tiffTagSamplesPerPixel = 277
+	tiffTagRowsPerStrip    = 278
+	tiffTagStripByteCounts = 279
+	tiffTagXResolution     = 282
+	tiffTagYResolution     = 283
+	tiffTagPlanarConfig    = 284
+	tiffTagResolutionUnit  = 296
+	tiffTagPageNumber      = 297
+	tiffTagPredictor       = 317
+)
+
+// TIFF field types
+const (
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+)
+
+// errTIFFTooLarge is returned when the pages do not fit in the 4 GB
+// addressable by a TIFF file
+var errTIFFTooLarge = errors.New("TIFF file larger than 4 GB")
+
+// tiffMaxPages bounds the number of IFDs walked, so a looping chain of IFDs
+// in a broken file does not hang the import
+const tiffMaxPages = 10000
+
+// TIFFCompression is the compression of the pages of a TIFF export
+type TIFFCompression int
+
+const (
+	TIFFCompressionLZW TIFFCompression = iota
+	TIFFCompressionDeflate
+	TIFFCompressionNone
+)
+
+// TIFFCompressionNames are the names of TIFFCompression indexed by the value
+var TIFFCompressionNames = []string{"LZW", "Deflate", "None"}
+
+// tiffCompressionTags are the values of the compression tag
+// indexed by TIFFCompression
+var tiffCompressionTags = []uint32{5, 8, 1}
+
+// tiffHeaderLen is the length of the header of a TIFF image,
+// which ends with the offset of the first IFD
+const tiffHeaderLen = 8
+
+// isTIFF reports whether bs is a TIFF image with a complete header
+func isTIFF(bs []byte) bool {
+	if len(bs) < tiffHeaderLen {
+		return false
+	}
+
+	return bytes.HasPrefix(bs, []byte("II*\x00")) || bytes.HasPrefix(bs, []byte("MM\x00*"))
+}
+
+// tiffByteOrder returns the byte order of a TIFF image
+func tiffByteOrder(bs []byte) byteOrder {
+	if bs[0] == 'M' {
+		return binary.BigEndian
+	}
+
+	return binary.LittleEndian
+}
+
+// tiffPageOffsets returns the offsets of the IFDs of the pages of a TIFF
+// image, the reduced-resolution images such as thumbnails are skipped.
+func tiffPageOffsets(bs []byte) []uint32 {
+	if len(bs) < tiffHeaderLen {
+		return nil
+	}
+
+	order := tiffByteOrder(bs)
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+
+	offset := order.Uint32(bs[4:])
+	for offset != 0 && !seen[offset] && len(seen) < tiffMaxPages {
+		seen[offset] = true
+
+		if int64(offset)+2 > int64(len(bs)) {
+			break
+		}
+
+		count := int(order.Uint16(bs[offset:]))
+		entries := int(offset) + 2
+		next := entries + count*12
+		if next+4 > len(bs) {
+			break
+		}
+
+		reduced := false
+		for i := range count {
+			entry := bs[entries+i*12:]
+			if order.Uint16(entry) == tiffTagNewSubfileType {
+				reduced = order.Uint32(entry[8:])&1 != 0
+			}
+		}
+
+		if !reduced {
+			offsets = append(offsets, offset)
+		}
+
+		offset = order.Uint32(bs[next:])
+	}
+
+	return offsets
+}
+
+// tiffPageReader reads a TIFF image as if its first IFD was another one,
+// so that a single-page decoder can decode any page
+type tiffPageReader struct {
+	bs     []byte
+	header [8]byte
+}
+
+func newTIFFPageReader(bs []byte, offset uint32) *io.SectionReader {
+	r := &tiffPageReader{bs: bs}
+	copy(r.header[:], bs)
+	tiffByteOrder(bs).PutUint32(r.header[4:], offset)
+
+	return io.NewSectionReader(r, 0, int64(len(bs)))
+}
+
+func (r *tiffPageReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(r.bs).ReadAt(p, off)
+	for i := off; i < int64(len(r.header)) && i < off+int64(n); i++ {
+		p[i-off] = r.header[i]
+	}
+
+	return n, err
+}
+
+// decodeTIFFPages decodes every page of a TIFF image,
+// the descriptions of the color conversions of the pages are returned too
+func decodeTIFFPages(bs []byte) ([]image.Image, []string, error) {
+	offsets := tiffPageOffsets(bs)
+
+	pages := make([]image.Image, len(offsets))
+	conversions := make([]string, len(offsets))
+	for i, offset := range offsets {
+		page, err := tiff.Decode(newTIFFPageReader(bs, offset))
+		if err != nil {
+			return nil, nil, util.Errorf("page %d: %w", i+1, err)
+		}
+
+		ifd := tiffPageIFD(bs, offset)
+		page, conversions[i] = toSRGB(page, ifd.iccProfile())
+
+		// the decoder ignores the orientation of the page
+		pages[i] = orientImage(page, ifd.orientation())
+	}
+
+	return pages, conversions, nil
+}
+
+// tiffPageIFD reads the IFD of the page at offset,
+// nil is returned if it is broken
+func tiffPageIFD(bs []byte, offset uint32) *exifData {
+	d := &exifData{order: tiffByteOrder(bs)}
+
+	ifd, err := d.readIFD(bs, offset)
+	if err != nil {
+		return nil
+	}
+
+	d.ifd0 = ifd
+	return d
+}
+
+// tiffPage is an encoded page of a TIFF export
+type tiffPage struct {
+	width, height int
+
+	// samples is 1 for grayscale pages and 3 for RGB pages
+	samples int
+
+	// data is the compressed strip of the page
+	data []byte
+}
+
+// encodeTIFFPage encodes the image as a single-strip page,
+// the grayscale images are stored with a single sample per pixel
+func encodeTIFFPage(img *Image, opts SaveOptions, compression TIFFCompression) (tiffPage, error) {
+	decoded, err := img.Img()
+	if err != nil {
+		return tiffPage{}, util.Errorf("%w", err)
+	}
+
+	flat := flattenOnWhite(opts.prepare(decoded))
+	page := tiffPage{
+		width:   flat.Rect.Dx(),
+		height:  flat.Rect.Dy(),
+		samples: 3,
+	}
+	if isGrayNRGBA(flat) {
+		page.samples = 1
+	}
+
+	raw := make([]byte, 0, page.width*page.height*page.samples)
+	for i := 0; i < len(flat.Pix); i += 4 {
+		raw = append(raw, flat.Pix[i:i+page.samples]...)
+	}
+
+	if compression == TIFFCompressionNone {
+		page.data = raw
+		return page, nil
+	}
+
+	// the horizontal differencing predictor helps the compression of scans
+	rowSize := page.width * page.samples
+	for y := range page.height {
+		row := raw[y*rowSize : (y+1)*rowSize]
+		for x := rowSize - 1; x >= page.samples; x-- {
+			row[x] -= row[x-page.samples]
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	if compression == TIFFCompressionDeflate {
+		w = zlib.NewWriter(buf)
+	} else {
+		w = lzw.NewWriter(buf, true)
+	}
+
+	if _, err := w.Write(raw); err != nil {
+		return tiffPage{}, util.Errorf("%w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return tiffPage{}, util.Errorf("%w", err)
+	}
+
+	page.data = buf.Bytes()
+	return page, nil
+}
+
+// isGrayNRGBA reports whether every pixel of the image is gray
+func isGrayNRGBA(img *image.NRGBA) bool {
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != img.Pix[i+1] || img.Pix[i] != img.Pix[i+2] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tiffEntry is an entry of an IFD,
+// value is the value itself if it fits in 4 bytes or its offset
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    uint32
+}
+
+// writeTIFFPage writes the IFD of the page at offset followed by
+// its values and its strip, and returns the offset after the page.
+// The IFD points to the next page unless it is the last one.
+// The ICC profile is embedded in the page if it is not nil.
+func writeTIFFPage(w io.Writer, page tiffPage, offset uint32,
+	pageNum, pageCount int, compression TIFFCompression, profile []byte) (uint32, error) {
+
+	order := binary.LittleEndian
+	compressed := compression != TIFFCompressionNone
+
+	// an RGB profile does not apply to the grayscale pages
+	if page.samples != 3 {
+		profile = nil
+	}
+
+	entryCount := 15
+	if compressed {
+		entryCount++
+	}
+	if profile != nil {
+		entryCount++
+	}
+
+	ifdSize := uint32(2 + entryCount*12 + 4)
+	valuesOffset := offset + ifdSize
+
+	// the values which do not fit in the entries:
+	// the bits per sample of RGB and the resolutions
+	var values []byte
+	bitsPerSample := uint32(8)
+	if page.samples == 3 {
+		bitsPerSample = valuesOffset
+		values = order.AppendUint16(values, 8)
+		values = order.AppendUint16(values, 8)
+		values = order.AppendUint16(values, 8)
+		values = order.AppendUint16(values, 0)
+	}
+
+	resolution := valuesOffset + uint32(len(values))
+	values = order.AppendUint32(values, 72)
+	values = order.AppendUint32(values, 1)
+
+	profileOffset := valuesOffset + uint32(len(values))
+	values = append(values, profile...)
+	if len(values)%2 != 0 {
+		values = append(values, 0)
+	}
+
+	dataOffset := valuesOffset + uint32(len(values))
+	if uint64(dataOffset)+uint64(len(page.data))+1 > math.MaxUint32 {
+		return 0, util.Errorf("%w", errTIFFTooLarge)
+	}
+
+	dataSize := uint32(len(page.data))
+	end := dataOffset + dataSize + dataSize%2
+
+	photometric := uint32(2)
+	if page.samples == 1 {
+		photometric = 1
+	}
+
+	entries := []tiffEntry{
+		// the file is a multi-page document
+		{tiffTagNewSubfileType, tiffLong, 1, 2},
+		{tiffTagImageWidth, tiffLong, 1, uint32(page.width)},
+		{tiffTagImageLength, tiffLong, 1, uint32(page.height)},
+		{tiffTagBitsPerSample, tiffShort, uint32(page.samples), bitsPerSample},
+		{tiffTagCompression, tiffShort, 1, tiffCompressionTags[compression]},
+		{tiffTagPhotometric, tiffShort, 1, photometric},
+		{tiffTagStripOffsets, tiffLong, 1, dataOffset},
+		{tiffTagSamplesPerPixel, tiffShort, 1, uint32(page.samples)},
+		{tiffTagRowsPerStrip, tiffLong, 1, uint32(page.height)},
+		{tiffTagStripByteCounts, tiffLong, 1, dataSize},
+		{tiffTagXResolution, tiffRational, 1, resolution},
+		{tiffTagYResolution, tiffRational, 1, resolution},
+		{tiffTagPlanarConfig, tiffShort, 1, 1},
+		{tiffTagResolutionUnit, tiffShort, 1, 2},
+		{tiffTagPageNumber, tiffShort, 2, uint32(pageNum) | uint32(pageCount)<<16},
+	}
+	if compressed {
+		entries = append(entries, tiffEntry{tiffTagPredictor, tiffShort, 1, 2})
+	}
+	if profile != nil {
+		entries = append(entries, tiffEntry{tiffTagICCProfile, tiffUndefined, uint32(len(profile)), profileOffset})
+	}
+
+	next := uint32(0)
+	if pageNum+1 < pageCount {
+		next = end
+	}
+
+	buf := make([]byte, 0, ifdSize+uint32(len(values)))
+	buf = order.AppendUint16(buf, uint16(len(entries)))
+	for _, entry := range entries {
+		buf = order.AppendUint16(buf, entry.tag)
+		buf = order.AppendUint16(buf, entry.typ)
+		buf = order.AppendUint32(buf, entry.count)
+		buf = order.AppendUint32(buf, entry.value)
+	}
+	buf = order.AppendUint32(buf, next)
+	buf = append(buf, values...)
+
+	if _, err := w.Write(buf); err != nil {
+		return 0, util.Errorf("%w", err)
+	}
+
+	if _, err := w.Write(page.data); err != nil {
+		return 0, util.Errorf("%w", err)
+	}
+
+	// the next IFD starts on a word boundary
+	if dataSize%2 != 0 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return 0, util.Errorf("%w", err)
+		}
+	}
+
+	return end, nil
+}
+
+// SaveImgsAsTIFF saves images as the pages of a multi-page TIFF file
+func SaveImgsAsTIFF(imgs []*Image, f io.Writer, opts SaveOptions, compression TIFFCompression) error {
+	return SaveImgsAsTIFFContext(context.Background(), imgs, f, opts, compression)
+}
+
+// SaveImgsAsTIFFContext saves images as the pages of a multi-page TIFF file,
+// it stops when ctx is done and reports the progress to opts.Progress
+func SaveImgsAsTIFFContext(ctx context.Context, imgs []*Image, f io.Writer,
+	opts SaveOptions, compression TIFFCompression) error {
+
+	if _, err := f.Write([]byte("II*\x00\x08\x00\x00\x00")); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	encode := func(img *Image, opts SaveOptions) (tiffPage, error) {
+		return encodeTIFFPage(img, opts, compression)
+	}
+
+	var profile []byte
+	if opts.EmbedSRGB {
+		profile = srgbProfile()
+	}
+
+	offset := uint32(8)
+	err := encodeOrdered(ctx, imgs, opts, encode, func(i int, page tiffPage) error {
+		end, err := writeTIFFPage(f, page, offset, i, len(imgs), compression, profile)
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		offset = end
+		return nil
+	})
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+// newTestImg creates an opaque image of size w x h colored by fill
+func newTestImg(t *testing.T, w, h int, fill func(x, y int) color.NRGBA) *Image {
+	t.Helper()
+
+	decoded := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			decoded.SetNRGBA(x, y, fill(x, y))
+		}
+	}
+
+	img, err := newImgFromImage(decoded, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+// newTestTIFFPages creates the pages of a test TIFF:
+// a grayscale page, an RGB page and an RGB page of odd size
+func newTestTIFFPages(t *testing.T) []*Image {
+	return []*Image{
+		newTestImg(t, 16, 8, func(x, y int) color.NRGBA {
+			v := uint8(x * 16)
+			return color.NRGBA{v, v, v, 255}
+		}),
+		newTestImg(t, 12, 10, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 20), uint8(y * 25), 128, 255}
+		}),
+		newTestImg(t, 7, 5, func(x, y int) color.NRGBA {
+			return color.NRGBA{255, uint8(x * 30), uint8(y * 50), 255}
+		}),
+	}
+}
+
+// encodeTestTIFF saves the images as a TIFF file
+func encodeTestTIFF(t *testing.T, imgs []*Image, compression TIFFCompression) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := SaveImgsAsTIFF(imgs, buf, SaveOptions{}, compression); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// tiffNextOffset returns the position of the offset of the next IFD
+// of the IFD at offset in a little-endian TIFF file
+func tiffNextOffset(bs []byte, offset uint32) uint32 {
+	count := uint32(binary.LittleEndian.Uint16(bs[offset:]))
+	return offset + 2 + count*12
+}
+
+func TestTIFFRoundTrip(t *testing.T) {
+	for compression, name := range TIFFCompressionNames {
+		t.Run(name, func(t *testing.T) {
+			imgs := newTestTIFFPages(t)
+			bs := encodeTestTIFF(t, imgs, TIFFCompression(compression))
+
+			pages, _, err := decodeTIFFPages(bs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(pages) != len(imgs) {
+				t.Fatalf("got %d pages, want %d", len(pages), len(imgs))
+			}
+
+			for i, page := range pages {
+				want, err := imgs[i].Img()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if page.Bounds().Size() != want.Bounds().Size() {
+					t.Fatalf("page %d: got size %v, want %v",
+						i+1, page.Bounds().Size(), want.Bounds().Size())
+				}
+
+				bounds := want.Bounds()
+				for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+					for x := bounds.Min.X; x < bounds.Max.X; x++ {
+						got := color.NRGBAModel.Convert(page.At(x, y))
+						exp := color.NRGBAModel.Convert(want.At(x, y))
+						if got != exp {
+							t.Fatalf("page %d: pixel (%d, %d) is %v, want %v", i+1, x, y, got, exp)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadMultiPageTIFF(t *testing.T) {
+	bs := encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionLZW)
+
+	imgs, err := newImgs(bytes.NewReader(bs), "book.tif", ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imgs) != 3 {
+		t.Fatalf("got %d images, want 3", len(imgs))
+	}
+
+	for i, img := range imgs {
+		if img.Provenance.Page != i+1 {
+			t.Errorf("image %d: got page %d, want %d", i, img.Provenance.Page, i+1)
+		}
+	}
+}
+
+func TestTIFFPageOffsetsTruncated(t *testing.T) {
+	bs := encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionNone)
+	offsets := tiffPageOffsets(bs)
+	if len(offsets) != 3 {
+		t.Fatalf("got %d pages, want 3", len(offsets))
+	}
+
+	// the IFD of the second page is cut
+	truncated := bs[:offsets[1]+5]
+	if got := tiffPageOffsets(truncated); !slices.Equal(got, offsets[:1]) {
+		t.Errorf("got offsets %v, want %v", got, offsets[:1])
+	}
+
+	pages, _, err := decodeTIFFPages(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Errorf("got %d pages, want 1", len(pages))
+	}
+
+	// the first IFD is past the end of the file
+	broken := slices.Clone(bs)
+	binary.LittleEndian.PutUint32(broken[4:], uint32(len(bs)+100))
+	if got := tiffPageOffsets(broken); len(got) != 0 {
+		t.Errorf("got offsets %v, want none", got)
+	}
+}
+
+func TestTIFFPageOffsetsLoop(t *testing.T) {
+	bs := encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionNone)
+	offsets := tiffPageOffsets(bs)
+
+	tests := []struct {
+		name string
+		from int
+		to   int
+		want []uint32
+	}{
+		{"back to first", 2, 0, offsets},
+		{"to itself", 0, 0, offsets[:1]},
+		{"back to second", 1, 1, offsets[:2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			looped := slices.Clone(bs)
+			next := tiffNextOffset(looped, offsets[tt.from])
+			binary.LittleEndian.PutUint32(looped[next:], offsets[tt.to])
+
+			if got := tiffPageOffsets(looped); !slices.Equal(got, tt.want) {
+				t.Errorf("got offsets %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTIFFPageOffsetsSkipReduced(t *testing.T) {
+	bs := encodeTestTIFF(t, newTestTIFFPages(t), TIFFCompressionNone)
+	offsets := tiffPageOffsets(bs)
+
+	// mark the second page as a reduced-resolution image
+	ifd := offsets[1]
+	count := uint32(binary.LittleEndian.Uint16(bs[ifd:]))
+	found := false
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if binary.LittleEndian.Uint16(bs[entry:]) == tiffTagNewSubfileType {
+			binary.LittleEndian.PutUint32(bs[entry+8:], 1)
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("no NewSubfileType entry in the IFD")
+	}
+
+	want := []uint32{offsets[0], offsets[2]}
+	if got := tiffPageOffsets(bs); !slices.Equal(got, want) {
+		t.Errorf("got offsets %v, want %v", got, want)
+	}
+}
+
+func TestNewImgsTruncatedTIFF(t *testing.T) {
+	for _, data := range []string{
+		"II*\x00",
+		"II*\x00ab",
+		"MM\x00*\x00\x00\x00",
+		"II*\x00\xff\xff\xff\xff",
+	} {
+		_, err := newImgs(bytes.NewReader([]byte(data)), "x.tif", ReadOptions{})
+		if err == nil {
+			t.Errorf("newImgs(%q) succeeded, want an error", data)
+		}
+	}
+}
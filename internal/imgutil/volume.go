@@ -0,0 +1,291 @@
+package imgutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VoileLab/goimgpack/internal/util"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// SplitMode is how the images are split into volumes
+type SplitMode int
+
+const (
+	// SplitPages starts a volume every SplitOptions.Pages images
+	SplitPages SplitMode = iota
+
+	// SplitSize starts a volume before the file exceeds SplitOptions.MaxSize
+	SplitSize
+
+	// SplitMarkers starts a volume at the images with Image.SplitBefore set
+	SplitMarkers
+)
+
+// SplitModeNames are the names of SplitMode indexed by the value
+var SplitModeNames = []string{"Page Count", "Max File Size", "Split Markers"}
+
+// VolumeFormat is the format of the volume files
+type VolumeFormat int
+
+const (
+	// VolumeCBZ saves the volumes as comic book archives
+	VolumeCBZ VolumeFormat = iota
+
+	// VolumePDF saves the volumes as PDF files
+	VolumePDF
+)
+
+// VolumeFormatNames are the names of VolumeFormat indexed by the value
+var VolumeFormatNames = []string{"CBZ", "PDF"}
+
+// volumeFormatExts are the extensions of VolumeFormat indexed by the value
+var volumeFormatExts = []string{".cbz", ".pdf"}
+
+const (
+	// volumeEntryOverhead is the estimated size added by the archive
+	// or the PDF to each image, on top of the encoded image
+	volumeEntryOverhead = 1024
+
+	// minVolumeDigits is the least number of digits of the volume numbers
+	minVolumeDigits = 2
+)
+
+// SplitOptions stores how the images are split into volumes
+type SplitOptions struct {
+	// Mode is how the images are split
+	Mode SplitMode
+
+	// Pages is the number of images of a volume of SplitPages
+	Pages int
+
+	// MaxSize is the largest size in bytes of a volume of SplitSize,
+	// it is estimated from the encoded images. A single image larger
+	// than MaxSize makes a volume on its own.
+	MaxSize int64
+
+	// Format is the format of the volume files
+	Format VolumeFormat
+
+	// PrependDigit prefixes the entry names of a CBZ volume
+	// with the index of the images in the volume
+	PrependDigit bool
+
+	// Existing is what is done when a volume file already exists
+	Existing ExistingPolicy
+}
+
+// VolumeResult reports what an export as volumes did
+type VolumeResult struct {
+	// Paths are the paths of the volume files written
+	Paths []string
+
+	// Skipped is the number of volumes not written since their file existed
+	Skipped int
+
+	// Renamed is the number of volumes written with another name
+	// since their file existed
+	Renamed int
+}
+
+// startsVolume reports whether img starts a new volume after a volume
+// of count images, whose size would be size with img
+func (o SplitOptions) startsVolume(img *Image, count int, size int64) bool {
+	if count == 0 {
+		return false
+	}
+
+	switch o.Mode {
+	case SplitPages:
+		return o.Pages > 0 && count >= o.Pages
+	case SplitSize:
+		return o.MaxSize > 0 && size > o.MaxSize
+	case SplitMarkers:
+		return img.SplitBefore
+	}
+
+	return false
+}
+
+// volumeDigits returns the number of digits of the volume numbers,
+// which fits the number of volumes when it is known in advance
+func (o SplitOptions) volumeDigits(imgs []*Image) int {
+	count := 0
+	switch o.Mode {
+	case SplitPages:
+		if o.Pages > 0 {
+			count = (len(imgs) + o.Pages - 1) / o.Pages
+		}
+	case SplitMarkers:
+		for i, img := range imgs {
+			if i == 0 || img.SplitBefore {
+				count++
+			}
+		}
+	}
+
+	return max(util.CountDigits(count), minVolumeDigits)
+}
+
+// VolumeName returns the filename of the volume n of the book name,
+// e.g. book_v01.cbz. The path separators and the characters invalid
+// on Windows are replaced so that the volume stays in its folder.
+func VolumeName(name string, n, digits int, format VolumeFormat) string {
+	return fmt.Sprintf("%s_v%s%s", nameReplacer.Replace(name),
+		util.PaddingZero(n, digits), volumeFormatExts[format])
+}
+
+// SaveImgsAsVolumesContext saves the images as volume files named by
+// VolumeName in the directory dir. The existing files are handled by
+// splitOpts.Existing. It stops when ctx is done and reports the progress
+// to opts.Progress, the volumes written are removed if it fails.
+func SaveImgsAsVolumesContext(ctx context.Context, imgs []*Image, dir, name string,
+	opts SaveOptions, splitOpts SplitOptions) (VolumeResult, error) {
+
+	digits := splitOpts.volumeDigits(imgs)
+
+	var result VolumeResult
+	var volCount int
+	var volImgs []*Image
+	var volData [][]byte
+	var volSize int64
+
+	// the names of this export, which the renamed volumes must not take
+	taken := make(map[string]bool)
+
+	// the encoded images of a volume are kept until the volume is written
+	flush := func() error {
+		if len(volImgs) == 0 {
+			return nil
+		}
+
+		volCount++
+		volName := VolumeName(name, volCount, digits, splitOpts.Format)
+		taken[strings.ToLower(volName)] = true
+
+		volOpts := opts
+		volOpts.Book = strings.TrimSuffix(volName, filepath.Ext(volName))
+		writeImgs, writeData := volImgs, volData
+		volImgs, volData, volSize = nil, nil, 0
+
+		p := filepath.Join(dir, volName)
+		exists, err := fileExists(p)
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		if exists {
+			switch splitOpts.Existing {
+			case ExistingSkip:
+				result.Skipped++
+				return nil
+			case ExistingRename:
+				p, err = freePath(dir, volName, taken)
+				if err != nil {
+					return util.Errorf("%w", err)
+				}
+				result.Renamed++
+			}
+		}
+
+		overwrite := splitOpts.Existing == ExistingOverwrite
+		if err := writeVolume(p, writeImgs, writeData, volOpts, splitOpts, overwrite); err != nil {
+			return util.Errorf("%w", err)
+		}
+		result.Paths = append(result.Paths, p)
+
+		return nil
+	}
+
+	err := encodeOrdered(ctx, imgs, opts, encodeJPEGBytes, func(i int, bs []byte) error {
+		size := int64(len(bs)) + volumeEntryOverhead
+		if splitOpts.startsVolume(imgs[i], len(volImgs), volSize+size) {
+			if err := flush(); err != nil {
+				return util.Errorf("%w", err)
+			}
+		}
+
+		volImgs = append(volImgs, imgs[i])
+		volData = append(volData, bs)
+		volSize += size
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		for _, p := range result.Paths {
+			os.Remove(p)
+		}
+		return VolumeResult{}, util.Errorf("%w", err)
+	}
+
+	return result, nil
+}
+
+// writeVolume writes the encoded images of a volume as the file p,
+// an existing file is replaced only if overwrite is set.
+// The file is removed if it cannot be written.
+func writeVolume(p string, imgs []*Image, data [][]byte,
+	opts SaveOptions, splitOpts SplitOptions, overwrite bool) error {
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flag |= os.O_EXCL
+	}
+
+	f, err := os.OpenFile(p, flag, 0o644)
+	if err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	switch splitOpts.Format {
+	case VolumePDF:
+		readers := make([]io.Reader, len(data))
+		for i, bs := range data {
+			readers[i] = bytes.NewReader(bs)
+		}
+		err = api.ImportImages(nil, f, readers, nil, nil)
+	default:
+		names, _ := ZipEntryNames(imgs, splitOpts.PrependDigit, opts)
+		err = writeZipEntries(f, names, data)
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(p)
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// writeZipEntries writes the data as the entries names of a zip file
+func writeZipEntries(w io.Writer, names []string, data [][]byte) error {
+	zipWriter := zip.NewWriter(w)
+	for i, bs := range data {
+		entry, err := zipWriter.Create(names[i])
+		if err != nil {
+			return util.Errorf("%w", err)
+		}
+
+		if _, err := entry.Write(bs); err != nil {
+			return util.Errorf("%w", err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return util.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package imgutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSaveImgsAsVolumesExisting(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   ExistingPolicy
+		wantFiles  []string
+		wantKept   bool
+		wantPaths  int
+		wantResult [2]int // skipped and renamed
+	}{
+		{"default keeps both", 0,
+			[]string{"book_v01.cbz", "book_v01_2.cbz", "book_v02.cbz", "book_v03.cbz"},
+			true, 3, [2]int{0, 1}},
+		{"skip", ExistingSkip,
+			[]string{"book_v01.cbz", "book_v02.cbz", "book_v03.cbz"},
+			true, 2, [2]int{1, 0}},
+		{"overwrite", ExistingOverwrite,
+			[]string{"book_v01.cbz", "book_v02.cbz", "book_v03.cbz"},
+			false, 3, [2]int{0, 0}},
+	}
+
+	old := []byte("existing volume")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "book_v01.cbz"), old, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			imgs := newTestNamedImgs(t, 5, func(i int) string { return fmt.Sprint(i) })
+			result, err := SaveImgsAsVolumesContext(context.Background(), imgs, dir, "book",
+				SaveOptions{}, SplitOptions{Mode: SplitPages, Pages: 2, Existing: tt.existing})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Paths) != tt.wantPaths ||
+				result.Skipped != tt.wantResult[0] || result.Renamed != tt.wantResult[1] {
+				t.Errorf("got result %+v", result)
+			}
+
+			if got := folderFiles(t, dir); !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("got files %v, want %v", got, tt.wantFiles)
+			}
+
+			bs, err := os.ReadFile(filepath.Join(dir, "book_v01.cbz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := string(bs) == string(old); kept != tt.wantKept {
+				t.Errorf("existing volume kept is %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestSaveImgsAsVolumesName(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	imgs := newTestNamedImgs(t, 3, func(i int) string { return fmt.Sprint(i) })
+	for _, name := range []string{"../up", `..\up`, "a/b"} {
+		_, err := SaveImgsAsVolumesContext(context.Background(), imgs, dir, name,
+			SaveOptions{}, SplitOptions{Mode: SplitPages, Pages: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"out/.._up_v01.cbz", "out/.._up_v01_2.cbz", "out/.._up_v02.cbz", "out/.._up_v02_2.cbz",
+		"out/a_b_v01.cbz", "out/a_b_v02.cbz",
+	}
+	if got := folderFiles(t, root); !slices.Equal(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
+
+func TestWriteVolumeRemovesBrokenFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "book_v01.pdf")
+
+	data := [][]byte{[]byte("not an image")}
+	err := writeVolume(p, nil, data, SaveOptions{}, SplitOptions{Format: VolumePDF}, false)
+	if err == nil {
+		t.Fatal("writeVolume succeeded, want an error")
+	}
+
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("the broken volume is left: %v", err)
+	}
+}
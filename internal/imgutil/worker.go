@@ -0,0 +1,67 @@
+package imgutil
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelDo calls fn for every index in [0, n) on at most workers
+// goroutines, workers <= 0 means one worker per CPU.
+// Indexes are handed out in order. After a call fails, the following
+// indexes are skipped and the error of the lowest failing index is
+// returned, the same error a sequential loop would return.
+// The remaining indexes are skipped when ctx is done.
+func parallelDo(ctx context.Context, n, workers int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	errs := make([]error, n)
+
+	var next atomic.Int64
+	var failed atomic.Int64
+	failed.Store(int64(n))
+
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				i := next.Add(1) - 1
+				if i >= int64(n) || i > failed.Load() {
+					return
+				}
+
+				err := ctx.Err()
+				if err == nil {
+					err = fn(int(i))
+				}
+
+				if err == nil {
+					continue
+				}
+
+				errs[i] = err
+				for {
+					f := failed.Load()
+					if i >= f || failed.CompareAndSwap(f, i) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
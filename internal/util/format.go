@@ -7,3 +7,19 @@ func PaddingZero(n int, width int) string {
 	format := "%0" + fmt.Sprint(width) + "d"
 	return fmt.Sprintf(format, n)
 }
+
+// FormatSize formats a size in bytes in a human readable way, e.g. "24.5 MB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}